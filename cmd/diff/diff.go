@@ -0,0 +1,394 @@
+package diff
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/bundle"
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/fsys"
+	"github.com/bhangun/coto/pkg/runlog"
+	"github.com/bhangun/coto/pkg/textdiff"
+	"github.com/fatih/color"
+)
+
+// File statuses
+const (
+	StatusAdded    = "added"
+	StatusRemoved  = "removed"
+	StatusModified = "modified"
+)
+
+// ErrDiffer is returned by Run with -exit-code when the sides differ. Like
+// git diff --exit-code, it sets the exit status without being printed.
+var ErrDiffer = errors.New("files differ")
+
+// DiffCommand handles the diff subcommand
+type DiffCommand struct {
+	// Flags
+	context        int
+	summary        bool
+	jsonOutput     bool
+	exitCode       bool
+	extensions     string
+	excludePattern string
+	all            bool
+	passphraseEnv  string
+
+	// Internal fields
+	files      fsys.FS // holds the directories being compared
+	passphrase func() ([]byte, error)
+	exclude    *regexp.Regexp
+	cyan       func(...interface{}) string
+	green      func(...interface{}) string
+	yellow     func(...interface{}) string
+	red        func(...interface{}) string
+}
+
+// FileDiff is the difference of one path
+type FileDiff struct {
+	Path     string          `json:"path"`
+	Status   string          `json:"status"`
+	Added    int             `json:"added_lines"`
+	Removed  int             `json:"removed_lines"`
+	Hunks    []textdiff.Hunk `json:"hunks,omitempty"`
+	oldLabel string
+	newLabel string
+}
+
+// Summary counts the files by status
+type Summary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Modified  int `json:"modified"`
+	Unchanged int `json:"unchanged"`
+	Skipped   int `json:"skipped"`
+}
+
+// Result is the machine readable diff
+type Result struct {
+	Old     string     `json:"old"`
+	New     string     `json:"new"`
+	Summary Summary    `json:"summary"`
+	Files   []FileDiff `json:"files"`
+	Skipped []string   `json:"skipped,omitempty"` // partial on one side only
+}
+
+// Changed reports whether any file differs
+func (r Result) Changed() bool {
+	return len(r.Files) > 0
+}
+
+// side is one argument: a bundle or a directory
+type side struct {
+	path    string
+	dir     bool
+	files   map[string]string
+	partial map[string]bool
+}
+
+// NewDiffCommand creates a new diff command instance
+func NewDiffCommand() *DiffCommand {
+	return &DiffCommand{
+		files:  fsys.OS,
+		cyan:   color.New(color.FgCyan).SprintFunc(),
+		green:  color.New(color.FgGreen).SprintFunc(),
+		yellow: color.New(color.FgYellow).SprintFunc(),
+		red:    color.New(color.FgRed).SprintFunc(),
+	}
+}
+
+// Run executes the diff command
+func (c *DiffCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.IntVar(&c.context, "U", 3, "Lines of context around each change")
+	fs.IntVar(&c.context, "context", 3, "Lines of context around each change")
+	fs.BoolVar(&c.summary, "summary", false, "Only list changed files and line counts")
+	fs.BoolVar(&c.jsonOutput, "json", false, "Write the diff as JSON")
+	fs.BoolVar(&c.exitCode, "exit-code", false, "Exit with status 1 when there are differences")
+	fs.StringVar(&c.extensions, "ext", "", "Extensions read from directories (default: those in the bundle)")
+	fs.StringVar(&c.excludePattern, "exclude", "", "Regex of paths to ignore")
+	fs.BoolVar(&c.all, "all", false, "Read every non-hidden file of directories")
+	fs.StringVar(&c.passphraseEnv, "passphrase-env", encryption.PassphraseEnv, "Environment variable holding the passphrase of encrypted bundles")
+
+	// Help flag
+	help := fs.Bool("help", false, "Show help")
+	h := fs.Bool("h", false, "Show help (shorthand)")
+	fs.Usage = c.printHelp
+	fs.SetOutput(io.Discard) // the error is reported once, by the caller
+
+	if err := fs.Parse(args); err != nil {
+		return runlog.Usage(err)
+	}
+	if *help || *h {
+		c.printHelp()
+		return nil
+	}
+	if fs.NArg() != 2 {
+		c.printHelp()
+		return runlog.Usage(fmt.Errorf("expected two bundles, or a bundle and a directory"))
+	}
+
+	if c.excludePattern != "" {
+		var err error
+		if c.exclude, err = regexp.Compile(c.excludePattern); err != nil {
+			return fmt.Errorf("invalid exclude pattern: %v", err)
+		}
+	}
+	c.passphrase = encryption.CachedPassphrase(c.passphraseEnv)
+
+	var sides [2]*side
+	for i, path := range fs.Args() {
+		s, err := c.open(path)
+		if err != nil {
+			return err
+		}
+		sides[i] = s
+	}
+	if sides[0].dir && sides[1].dir {
+		return fmt.Errorf("at least one side must be a bundle")
+	}
+	for i, s := range sides {
+		if s.dir {
+			if err := c.readDir(s, sides[1-i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	result := compare(sides[0].path, sides[1].path, sides[0], sides[1], c.context)
+
+	if c.jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		c.printResult(result)
+	}
+
+	if c.exitCode && result.Changed() {
+		return ErrDiffer
+	}
+	return nil
+}
+
+// open loads a bundle or records a directory to be read later
+func (c *DiffCommand) open(path string) (*side, error) {
+	info, err := c.files.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &side{path: path, dir: true, files: map[string]string{}, partial: map[string]bool{}}, nil
+	}
+
+	b, err := bundle.Open(path, c.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	s := &side{path: path, files: map[string]string{}, partial: map[string]bool{}}
+	for _, f := range b.Files {
+		// Aliases of deduplicated files have the same content
+		for _, p := range append([]string{f.RelativePath}, f.Aliases...) {
+			s.files[p] = f.Content
+			s.partial[p] = f.Partial
+		}
+	}
+	return s, nil
+}
+
+// readDir reads the files of a directory that the bundle on the other side
+// has, plus files with the same extensions so additions show up
+func (c *DiffCommand) readDir(s, other *side) error {
+	exts := make(map[string]bool)
+	for p := range other.files {
+		exts[strings.ToLower(filepath.Ext(p))] = true
+	}
+	if c.extensions != "" {
+		exts = make(map[string]bool)
+		for _, ext := range strings.Split(c.extensions, ",") {
+			exts[strings.ToLower(strings.TrimSpace(ext))] = true
+		}
+	}
+
+	otherAbs, _ := filepath.Abs(other.path)
+	return fs.WalkDir(c.files, s.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != s.path && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.path, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if c.exclude != nil && c.exclude.MatchString(rel) {
+			return nil
+		}
+		if _, known := other.files[rel]; !known {
+			if !c.all && !exts[strings.ToLower(filepath.Ext(rel))] {
+				return nil
+			}
+			if abs, _ := filepath.Abs(path); abs == otherAbs || bundle.IsBundleFS(c.files, path) {
+				return nil
+			}
+		}
+
+		data, err := c.files.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		s.files[rel] = string(data)
+		return nil
+	})
+}
+
+// compare diffs every path of the two sides
+func compare(oldName, newName string, a, b *side, context int) Result {
+	result := Result{Old: oldName, New: newName, Files: []FileDiff{}}
+
+	paths := make(map[string]bool)
+	for p := range a.files {
+		paths[p] = true
+	}
+	for p := range b.files {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	for _, p := range sorted {
+		oldContent, inOld := a.files[p]
+		newContent, inNew := b.files[p]
+		if inOld && inNew && oldContent == newContent {
+			result.Summary.Unchanged++
+			continue
+		}
+		// A line selection cannot be compared with a whole file
+		if inOld && inNew && a.partial[p] != b.partial[p] {
+			result.Skipped = append(result.Skipped, p)
+			result.Summary.Skipped++
+			continue
+		}
+
+		fd := FileDiff{Path: p, Status: StatusModified, oldLabel: "a/" + p, newLabel: "b/" + p}
+		switch {
+		case !inOld:
+			fd.Status, fd.oldLabel = StatusAdded, "/dev/null"
+			result.Summary.Added++
+		case !inNew:
+			fd.Status, fd.newLabel = StatusRemoved, "/dev/null"
+			result.Summary.Removed++
+		default:
+			result.Summary.Modified++
+		}
+
+		edits := textdiff.Lines(textdiff.SplitLines(oldContent), textdiff.SplitLines(newContent))
+		fd.Removed, fd.Added = textdiff.Count(edits)
+		fd.Hunks = textdiff.Hunks(edits, context)
+		result.Files = append(result.Files, fd)
+	}
+	return result
+}
+
+func (c *DiffCommand) printResult(result Result) {
+	if !c.summary {
+		for _, fd := range result.Files {
+			fmt.Printf("%s\n%s\n", c.red("--- "+fd.oldLabel), c.green("+++ "+fd.newLabel))
+			for _, h := range fd.Hunks {
+				fmt.Println(c.cyan(h.Header()))
+				for _, line := range h.Lines {
+					switch {
+					case strings.HasPrefix(line, "-"):
+						line = c.red(line)
+					case strings.HasPrefix(line, "+"):
+						line = c.green(line)
+					}
+					fmt.Println(line)
+				}
+			}
+		}
+		if len(result.Files) > 0 {
+			fmt.Println()
+		}
+	}
+
+	for _, fd := range result.Files {
+		mark := c.yellow("~")
+		switch fd.Status {
+		case StatusAdded:
+			mark = c.green("+")
+		case StatusRemoved:
+			mark = c.red("-")
+		}
+		fmt.Printf("  %s %-9s %s (%s, %s)\n", mark, fd.Status, fd.Path,
+			c.green("+"+strconv.Itoa(fd.Added)), c.red("-"+strconv.Itoa(fd.Removed)))
+	}
+	for _, p := range result.Skipped {
+		fmt.Printf("  %s skipped   %s (partial content on one side)\n", c.yellow("?"), p)
+	}
+
+	s := result.Summary
+	fmt.Printf("\n%s %s\n", c.cyan("┌"), strings.Repeat("─", 50))
+	fmt.Printf("%s Diff Summary\n", c.cyan("│"))
+	fmt.Printf("%s %s\n", c.cyan("├"), strings.Repeat("─", 50))
+	fmt.Printf("%s Added:      %s\n", c.cyan("│"), c.green(strconv.Itoa(s.Added)))
+	fmt.Printf("%s Removed:    %s\n", c.cyan("│"), c.red(strconv.Itoa(s.Removed)))
+	fmt.Printf("%s Modified:   %s\n", c.cyan("│"), c.yellow(strconv.Itoa(s.Modified)))
+	fmt.Printf("%s Unchanged:  %s\n", c.cyan("│"), strconv.Itoa(s.Unchanged))
+	if s.Skipped > 0 {
+		fmt.Printf("%s Skipped:    %s\n", c.cyan("│"), c.yellow(strconv.Itoa(s.Skipped)))
+	}
+	fmt.Printf("%s %s\n", c.cyan("└"), strings.Repeat("─", 50))
+
+	if !result.Changed() {
+		fmt.Printf("\n%s No differences\n", c.green("✓"))
+	}
+}
+
+func (c *DiffCommand) printHelp() {
+	fmt.Fprintf(os.Stderr, "%s Coto Diff - Compare two bundles or a bundle and a directory\n\n", c.cyan("🔀"))
+	fmt.Fprintf(os.Stderr, "Usage: coto diff [options] <old> <new>\n\n")
+	fmt.Fprintf(os.Stderr, "Either side may be a bundle in any format, compressed or encrypted, or a directory.\n\n")
+
+	fmt.Fprintf(os.Stderr, "%s Options:\n", c.cyan("📋"))
+	fmt.Fprintf(os.Stderr, "  -U, -context int        Lines of context around each change (default 3)\n")
+	fmt.Fprintf(os.Stderr, "  -summary                Only list changed files and line counts\n")
+	fmt.Fprintf(os.Stderr, "  -json                   Write the diff as JSON\n")
+	fmt.Fprintf(os.Stderr, "  -exit-code              Exit with status 1 when there are differences\n")
+	fmt.Fprintf(os.Stderr, "  -ext string             Extensions read from directories (default: those in the bundle)\n")
+	fmt.Fprintf(os.Stderr, "  -exclude string         Regex of paths to ignore in directories\n")
+	fmt.Fprintf(os.Stderr, "  -all                    Read every non-hidden file of directories\n")
+	fmt.Fprintf(os.Stderr, "  -passphrase-env string  Variable holding the passphrase of encrypted bundles\n")
+	fmt.Fprintf(os.Stderr, "  -h, -help               Show this help message\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Examples:\n", c.cyan("🚀"))
+	fmt.Fprintf(os.Stderr, "  coto diff before.json after.json\n")
+	fmt.Fprintf(os.Stderr, "  coto diff reviewed.md.gz ./src\n")
+	fmt.Fprintf(os.Stderr, "  coto diff -summary -exit-code bundle.txt ./src\n")
+	fmt.Fprintf(os.Stderr, "  coto diff -json a.txt b.xml > changes.json\n")
+}
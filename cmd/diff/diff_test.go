@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bhangun/coto/pkg/combine"
+	"github.com/bhangun/coto/pkg/fsys"
+	"github.com/bhangun/coto/pkg/runlog"
+)
+
+func TestCompare(t *testing.T) {
+	old := &side{
+		files:   map[string]string{"same.go": "x\n", "edit.go": "a\nb\n", "gone.go": "g\n", "part.go": "1\n"},
+		partial: map[string]bool{"part.go": true},
+	}
+	cur := &side{
+		files:   map[string]string{"same.go": "x\n", "edit.go": "a\nc\n", "new.go": "n\n", "part.go": "1\n2\n"},
+		partial: map[string]bool{},
+	}
+
+	result := compare("old", "new", old, cur, 3)
+	want := Summary{Added: 1, Removed: 1, Modified: 1, Unchanged: 1, Skipped: 1}
+	if result.Summary != want {
+		t.Fatalf("Summary = %+v, want %+v", result.Summary, want)
+	}
+
+	statuses := map[string]string{}
+	for _, fd := range result.Files {
+		statuses[fd.Path] = fd.Status
+	}
+	if statuses["edit.go"] != StatusModified || statuses["gone.go"] != StatusRemoved || statuses["new.go"] != StatusAdded {
+		t.Errorf("Unexpected statuses %v", statuses)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != "part.go" {
+		t.Errorf("Expected part.go to be skipped, got %v", result.Skipped)
+	}
+
+	edit := result.Files[0]
+	if edit.Path != "edit.go" || edit.Added != 1 || edit.Removed != 1 || len(edit.Hunks) != 1 {
+		t.Errorf("Unexpected diff for edit.go: %+v", edit)
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	c := &DiffCommand{files: fsys.FromFS(fstest.MapFS{
+		"proj/a.go":          {Data: []byte("a\n")},
+		"proj/sub/b.go":      {Data: []byte("b\n")},
+		"proj/.git/x.go":     {Data: []byte("x\n")},
+		"proj/README.md":     {Data: []byte("# r\n")},
+		"proj/combined.go":   {Data: []byte("Coto Output\nGenerated: 2024-01-01\n")},
+		"proj/notes/todo.go": {Data: []byte("t\n")},
+	})}
+	s := &side{path: "proj", dir: true, files: map[string]string{}, partial: map[string]bool{}}
+	other := &side{path: "bundle.txt", files: map[string]string{"a.go": "a\n"}}
+
+	if err := c.readDir(s, other); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for p := range s.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if strings.Join(paths, ",") != "a.go,notes/todo.go,sub/b.go" {
+		t.Errorf("Unexpected files %v", paths)
+	}
+}
+
+func TestRun_UsageErrors(t *testing.T) {
+	for _, args := range [][]string{{"-bogus"}, {"one.txt"}} {
+		err := NewDiffCommand().Run(args)
+		if code := runlog.ExitCode(err); code != runlog.ExitUsage {
+			t.Errorf("%v: expected exit code %d, got %d (%v)", args, runlog.ExitUsage, code, err)
+		}
+	}
+}
+
+func TestRun_ExitCode(t *testing.T) {
+	dir := t.TempDir()
+	bundlePath := filepath.Join(t.TempDir(), "bundle.txt")
+	files := []combine.FileInfo{{Path: "a.go", RelativePath: "a.go", Content: "package a\n"}}
+	if _, err := combine.WriteFile(context.Background(), files, bundlePath, combine.WriteOptions{Format: "text"}, combine.Stats{FilesProcessed: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDiffCommand().Run([]string{"-summary", "-exit-code", bundlePath, dir}); err != nil {
+		t.Errorf("Expected no error without differences, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := NewDiffCommand().Run([]string{"-summary", "-exit-code", bundlePath, dir})
+	if !errors.Is(err, ErrDiffer) || runlog.ExitCode(err) != 1 {
+		t.Errorf("Expected ErrDiffer with exit code 1, got %v", err)
+	}
+}
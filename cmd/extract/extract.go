@@ -5,13 +5,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
-	"sync"
-
+	"time"
+
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/extract"
+	"github.com/bhangun/coto/pkg/interrupt"
+	"github.com/bhangun/coto/pkg/prompt"
+	"github.com/bhangun/coto/pkg/runlog"
+	"github.com/bhangun/coto/pkg/settings"
 	"github.com/fatih/color"
-	"github.com/bhangun/coto/pkg/extractor"
 )
 
 // ExtractCommand handles the extract subcommand
@@ -21,6 +26,7 @@ type ExtractCommand struct {
 	outputDir     string
 	language      string
 	parallel      int
+	timeout       time.Duration
 	verbose       bool
 	quiet         bool
 	dryRun        bool
@@ -28,12 +34,20 @@ type ExtractCommand struct {
 	listPlugins   bool
 	pluginDir     string
 	configFile    string
+	profile       string
+	passphraseEnv string
+	logFormat     string
+	logFile       string
+	outputJSON    bool
+	help          bool
 
 	// Internal fields
-	cyan   func(...interface{}) string
-	green  func(...interface{}) string
-	yellow func(...interface{}) string
-	red    func(...interface{}) string
+	log        *runlog.Logger
+	passphrase func() ([]byte, error)
+	cyan       func(...interface{}) string
+	green      func(...interface{}) string
+	yellow     func(...interface{}) string
+	red        func(...interface{}) string
 }
 
 // NewExtractCommand creates a new extract command instance
@@ -48,44 +62,151 @@ func NewExtractCommand() *ExtractCommand {
 
 // Run executes the extract command
 func (c *ExtractCommand) Run(args []string) error {
-	// Define flags
+	fs := c.flags()
+
+	// Parse flags
+	if err := fs.Parse(args); err != nil {
+		return runlog.Usage(err)
+	}
+
+	// Check for help
+	if c.help {
+		c.printHelp()
+		return nil
+	}
+
+	// Fill the flags that were not given from the configuration files and
+	// COTO_EXTRACT_* variables
+	if _, err := c.loadSettings(fs); err != nil {
+		return err
+	}
+
+	c.passphrase = encryption.CachedPassphrase(c.passphraseEnv)
+
+	// Check for list-plugins
+	if c.listPlugins {
+		c.listAvailablePlugins()
+		return nil
+	}
+
+	if c.outputJSON {
+		c.logFormat = runlog.FormatJSON
+	}
+	log, err := runlog.Open("extract", c.logFormat, c.logFile)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+	c.log = log
+
+	// When no flag, argument or configuration names the input files, they
+	// are asked for on a terminal together with the language and the names
+	// of the blocks
+	var result *extract.Result
+	if c.inputFiles == "" && fs.NArg() == 0 && prompt.Terminal() {
+		result, err = c.interactive(prompt.Stdio(), os.Stdout)
+		if result == nil && err == nil {
+			fmt.Printf("%s Canceled, nothing was written\n", c.yellow("⚠"))
+		}
+	} else {
+		result, err = c.run(fs.Args())
+	}
+	var stats any
+	if result != nil {
+		stats = extractStats{
+			FilesProcessed: len(result.Files),
+			Blocks:         result.Blocks(),
+			FilesWritten:   result.Written(),
+			Errors:         result.Errors(),
+			DryRun:         c.dryRun,
+		}
+	}
+	return log.Finish(err, stats)
+}
+
+// flags defines the flags of the command
+func (c *ExtractCommand) flags() *flag.FlagSet {
 	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
 	fs.StringVar(&c.inputFiles, "input", "", "Comma-separated input files")
 	fs.StringVar(&c.outputDir, "output", "extracted", "Output directory")
 	fs.StringVar(&c.language, "language", "", "Target language (auto-detected)")
 	fs.IntVar(&c.parallel, "parallel", 1, "Parallel processing")
+	fs.DurationVar(&c.timeout, "timeout", 0, "Stop after this long, e.g. 90s or 5m (0 = no limit)")
 	fs.BoolVar(&c.verbose, "verbose", false, "Show detailed progress")
 	fs.BoolVar(&c.quiet, "quiet", false, "Suppress non-essential output")
 	fs.BoolVar(&c.dryRun, "dry-run", false, "Show what would be extracted")
 	fs.BoolVar(&c.report, "report", false, "Generate detailed report")
 	fs.BoolVar(&c.listPlugins, "list-plugins", false, "List available plugins")
 	fs.StringVar(&c.pluginDir, "plugin-dir", "", "Directory containing plugins")
-	fs.StringVar(&c.configFile, "config", "", "Configuration file path")
+	fs.StringVar(&c.configFile, "config", "", "Load configuration from a JSON, YAML or TOML file")
+	fs.StringVar(&c.profile, "profile", "", "Apply a named profile from the configuration files")
+	fs.StringVar(&c.passphraseEnv, "passphrase-env", encryption.PassphraseEnv, "Environment variable holding the passphrase of encrypted bundles")
+	fs.StringVar(&c.logFormat, "log-format", runlog.FormatText, "Log format: text or json")
+	fs.BoolVar(&c.outputJSON, "output-json", false, "Log JSON events and a summary (same as -log-format json)")
+	fs.StringVar(&c.logFile, "log-file", "", "Write the JSON log to a file instead of stderr")
 
 	// Help flag
-	help := fs.Bool("help", false, "Show help")
-	h := fs.Bool("h", false, "Show help (shorthand)")
+	fs.BoolVar(&c.help, "help", false, "Show help")
+	fs.BoolVar(&c.help, "h", false, "Show help (shorthand)")
+	return fs
+}
 
-	// Parse flags
-	if err := fs.Parse(args); err != nil {
-		return err
+// loadSettings merges the configuration layers into the flags that were not
+// given on the command line
+func (c *ExtractCommand) loadSettings(fs *flag.FlagSet) (*settings.Settings, error) {
+	bindings, schema := settings.FlagBindings(fs, "help", "h", "config", "profile", "list-plugins")
+	loader := settings.Loader{Section: "extract", Schema: schema, Dir: c.projectDir(fs.Args()), File: c.configFile, Profile: c.profile}
+	s, err := loader.Load(fs, bindings)
+	if err == nil {
+		err = s.Apply(fs, bindings)
 	}
+	if err != nil {
+		return nil, runlog.Usage(fmt.Errorf("error loading config: %w", err))
+	}
+	return s, nil
+}
 
-	// Check for help
-	if *help || *h {
-		c.printHelp()
-		return nil
+// projectDir returns the directory of the first input, where the search for
+// a project file starts
+func (c *ExtractCommand) projectDir(args []string) string {
+	inputs := args
+	if c.inputFiles != "" {
+		inputs = strings.Split(c.inputFiles, ",")
 	}
+	if len(inputs) == 0 {
+		return "."
+	}
+	return filepath.Dir(strings.TrimSpace(inputs[0]))
+}
 
-	// Check for list-plugins
-	if c.listPlugins {
-		c.listAvailablePlugins()
-		return nil
+// ShowConfig prints the merged configuration of the command and where each
+// value came from
+func (c *ExtractCommand) ShowConfig(args []string) error {
+	fs := c.flags()
+	if err := fs.Parse(args); err != nil {
+		return runlog.Usage(err)
 	}
+	s, err := c.loadSettings(fs)
+	if err != nil {
+		return err
+	}
+	return s.Fprint(os.Stdout)
+}
 
+// extractStats is the summary of a run in the JSON log
+type extractStats struct {
+	FilesProcessed int  `json:"files_processed"`
+	Blocks         int  `json:"code_blocks"`
+	FilesWritten   int  `json:"files_written"`
+	Errors         int  `json:"errors"`
+	DryRun         bool `json:"dry_run,omitempty"`
+}
+
+// run extracts the code blocks of the input files given by -input or args
+func (c *ExtractCommand) run(args []string) (*extract.Result, error) {
 	// Validate required arguments
-	if c.inputFiles == "" && len(fs.Args()) == 0 {
-		return fmt.Errorf("input files are required")
+	if c.inputFiles == "" && len(args) == 0 {
+		return nil, runlog.Usage(fmt.Errorf("input files are required"))
 	}
 
 	// Prepare input files
@@ -98,36 +219,13 @@ func (c *ExtractCommand) Run(args []string) error {
 		}
 	} else {
 		// Use remaining arguments as input files
-		inputPaths = fs.Args()
+		inputPaths = args
 	}
 
-	// Expand glob patterns
-	var expandedPaths []string
-	for _, path := range inputPaths {
-		matches, err := filepath.Glob(path)
-		if err != nil {
-			return fmt.Errorf("invalid glob pattern: %s", path)
-		}
-		if matches != nil {
-			expandedPaths = append(expandedPaths, matches...)
-		} else {
-			// If no matches, treat as literal path
-			expandedPaths = append(expandedPaths, path)
-		}
-	}
-
-	// Validate input files exist
-	for _, path := range expandedPaths {
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			return fmt.Errorf("input file does not exist: %s", path)
-		}
-	}
-
-	// Create output directory if not in dry-run mode
-	if !c.dryRun {
-		if err := os.MkdirAll(c.outputDir, 0755); err != nil {
-			return fmt.Errorf("failed to create output directory: %v", err)
-		}
+	// Expand glob patterns and make sure every input exists
+	expandedPaths, err := extract.ExpandInputs(nil, inputPaths)
+	if err != nil {
+		return nil, err
 	}
 
 	if !c.quiet {
@@ -139,24 +237,36 @@ func (c *ExtractCommand) Run(args []string) error {
 		}
 	}
 
-	// Initialize plugin registry
-	registry := NewPluginRegistry()
-
-	// Register built-in plugins
-	registerBuiltInPlugins(registry)
+	// Initialize plugin registry with the built-in plugins
+	registry := extract.NewBuiltinRegistry()
 
 	// Load custom plugins if plugin directory is specified
 	if c.pluginDir != "" {
 		if err := c.loadCustomPlugins(registry); err != nil {
-			return fmt.Errorf("failed to load custom plugins: %v", err)
+			return nil, fmt.Errorf("failed to load custom plugins: %v", err)
 		}
 	}
 
+	// Ctrl-C, SIGTERM and -timeout stop the run once the files being
+	// extracted are written
+	ctx, stop := interrupt.Context(c.timeout)
+	defer stop()
+
 	// Process files
-	results, err := c.processFiles(expandedPaths, registry)
-	if err != nil {
-		return err
-	}
+	result, err := extract.Run(ctx, extract.Options{
+		Inputs:     expandedPaths,
+		OutputDir:  c.outputDir,
+		Language:   c.language,
+		Parallel:   c.parallel,
+		DryRun:     c.dryRun,
+		Registry:   registry,
+		Passphrase: c.passphrase,
+		OnEvent:    c.log.Handler(c.printEvent),
+	})
+	if err != nil && !interrupt.Canceled(err) {
+		return nil, err
+	}
+	results := result.Files
 
 	// Generate report if requested
 	if c.report {
@@ -164,13 +274,8 @@ func (c *ExtractCommand) Run(args []string) error {
 	}
 
 	if !c.quiet {
-		// Calculate statistics
-		totalBlocks := 0
-		totalFilesWritten := 0
-		for _, result := range results {
-			totalBlocks += len(result.CodeBlocks)
-			totalFilesWritten += len(result.WrittenFiles)
-		}
+		totalBlocks := result.Blocks()
+		totalFilesWritten := result.Written()
 
 		fmt.Printf("\n%s %s\n", c.cyan("┌"), strings.Repeat("─", 50))
 		fmt.Printf("%s Extraction Summary\n", c.cyan("│"))
@@ -183,22 +288,35 @@ func (c *ExtractCommand) Run(args []string) error {
 		}
 		fmt.Printf("%s %s\n", c.cyan("└"), strings.Repeat("─", 50))
 
-		fmt.Printf("\n%s Extraction completed successfully!\n", c.green("✓"))
+		if err == nil && result.Errors() > 0 {
+			fmt.Printf("\n%s Extraction finished with %d errors\n", c.yellow("⚠"), result.Errors())
+		} else if err == nil {
+			fmt.Printf("\n%s Extraction completed successfully!\n", c.green("✓"))
+		}
 	}
 
-	return nil
+	if err != nil {
+		done := len(result.Files) + len(result.Failed)
+		return result, fmt.Errorf("%s after %d of %d files: %w", strings.ToLower(interrupt.Reason(ctx)), done, len(expandedPaths), err)
+	}
+	return result, runlog.Partial(result.Errors())
 }
 
 // printHelp prints the help message
 func (c *ExtractCommand) printHelp() {
 	fmt.Fprintf(os.Stderr, "%s Coto Extract v1.0.0 - Extract code blocks\n\n", c.cyan("📁"))
-	fmt.Fprintf(os.Stderr, "Usage: coto extract [options]\n\n")
+	fmt.Fprintf(os.Stderr, "Usage: coto extract [options]\n")
+	fmt.Fprintf(os.Stderr, "Without input files in a terminal, the files, language and block names are asked for.\n\n")
 
 	fmt.Fprintf(os.Stderr, "%s Basic Options:\n", c.cyan("📋"))
 	fmt.Fprintf(os.Stderr, "  -input string        Comma-separated input files\n")
 	fmt.Fprintf(os.Stderr, "  -output string       Output directory (default \"extracted\")\n")
 	fmt.Fprintf(os.Stderr, "  -language string     Target language (auto-detected)\n")
 	fmt.Fprintf(os.Stderr, "  -parallel int        Parallel processing (default 1)\n")
+	fmt.Fprintf(os.Stderr, "  -timeout duration    Stop after this long, e.g. 90s or 5m (0 = no limit)\n")
+	fmt.Fprintf(os.Stderr, "  -passphrase-env string  Variable holding the passphrase of encrypted bundles\n")
+	fmt.Fprintf(os.Stderr, "  -config string       Load configuration from a JSON, YAML or TOML file\n")
+	fmt.Fprintf(os.Stderr, "  -profile string      Apply a named profile from the configuration files\n")
 
 	fmt.Fprintf(os.Stderr, "\n%s Mode Options:\n", c.cyan("🎯"))
 	fmt.Fprintf(os.Stderr, "  -dry-run             Show what would be extracted\n")
@@ -206,6 +324,11 @@ func (c *ExtractCommand) printHelp() {
 	fmt.Fprintf(os.Stderr, "  -quiet               Suppress non-essential output\n")
 	fmt.Fprintf(os.Stderr, "  -report              Generate detailed report\n")
 
+	fmt.Fprintf(os.Stderr, "\n%s Logging Options:\n", c.cyan("📝"))
+	fmt.Fprintf(os.Stderr, "  -log-format string   Log format: text or json (default \"text\")\n")
+	fmt.Fprintf(os.Stderr, "  -output-json         Same as -log-format json\n")
+	fmt.Fprintf(os.Stderr, "  -log-file string     Write the JSON log to a file instead of stderr\n")
+
 	fmt.Fprintf(os.Stderr, "\n%s Plugin Options:\n", c.cyan("🔌"))
 	fmt.Fprintf(os.Stderr, "  -list-plugins        List available plugins\n")
 	fmt.Fprintf(os.Stderr, "  -plugin-dir string   Directory containing plugins\n")
@@ -223,11 +346,10 @@ func (c *ExtractCommand) printHelp() {
 
 // listAvailablePlugins lists all registered plugins
 func (c *ExtractCommand) listAvailablePlugins() {
-	registry := NewPluginRegistry()
-	registerBuiltInPlugins(registry)
+	registry := extract.NewBuiltinRegistry()
 
 	fmt.Printf("%s Available Extractor Plugins:\n", c.cyan("🔌"))
-	
+
 	plugins := registry.GetAllPlugins()
 	for _, plugin := range plugins {
 		fmt.Printf("  %s: %s\n", c.green(plugin.Name()), strings.Join(plugin.Extensions(), ", "))
@@ -235,7 +357,7 @@ func (c *ExtractCommand) listAvailablePlugins() {
 }
 
 // loadCustomPlugins loads plugins from the specified directory
-func (c *ExtractCommand) loadCustomPlugins(registry *PluginRegistry) error {
+func (c *ExtractCommand) loadCustomPlugins(registry *extract.PluginRegistry) error {
 	// For now, we'll just scan the directory for plugin files
 	// In a real implementation, this would dynamically load Go plugins
 	files, err := os.ReadDir(c.pluginDir)
@@ -255,236 +377,38 @@ func (c *ExtractCommand) loadCustomPlugins(registry *PluginRegistry) error {
 	return nil
 }
 
-// processFiles processes the input files with the given registry
-func (c *ExtractCommand) processFiles(inputPaths []string, registry *PluginRegistry) ([]ExtractionResult, error) {
-	var results []ExtractionResult
-
-	if c.parallel > 1 {
-		// Use parallel processing
-		results = c.processFilesParallel(inputPaths, registry)
-	} else {
-		// Use sequential processing
-		results = c.processFilesSequential(inputPaths, registry)
+// printEvent reports the progress of the extraction
+func (c *ExtractCommand) printEvent(e event.Event) {
+	if c.quiet {
+		return
 	}
-
-	return results, nil
-}
-
-// processFilesSequential processes files sequentially
-func (c *ExtractCommand) processFilesSequential(inputPaths []string, registry *PluginRegistry) []ExtractionResult {
-	var results []ExtractionResult
-
-	for i, path := range inputPaths {
-		if c.verbose && !c.quiet {
-			fmt.Printf("%s Processing file %d/%d: %s\n",
-				c.cyan("↳"), i+1, len(inputPaths), path)
+	switch e.Kind {
+	case event.Processed:
+		if c.verbose {
+			fmt.Printf("%s Processed file %d/%d: %s (%s)\n", c.cyan("↳"), e.Done, e.Total, e.Path, e.Reason)
 		}
-
-		result, err := c.processSingleFile(path, registry)
-		if err != nil {
-			if !c.quiet {
-				fmt.Printf("%s Error processing %s: %v\n", c.red("✗"), path, err)
+	case event.Written:
+		if c.verbose {
+			name, err := filepath.Rel(c.outputDir, e.Target)
+			if err != nil {
+				name = e.Target
 			}
-			continue
-		}
-
-		results = append(results, result)
-
-		if c.verbose && !c.quiet && (i+1)%10 == 0 {
-			fmt.Printf("%s Processed %d/%d files\n", c.cyan("→"), i+1, len(inputPaths))
-		}
-	}
-
-	return results
-}
-
-// processFilesParallel processes files in parallel
-func (c *ExtractCommand) processFilesParallel(inputPaths []string, registry *PluginRegistry) []ExtractionResult {
-	var wg sync.WaitGroup
-	fileChan := make(chan string, len(inputPaths))
-	resultChan := make(chan ExtractionResult, len(inputPaths))
-	errorChan := make(chan error, len(inputPaths))
-
-	var results []ExtractionResult
-
-	// Start worker goroutines
-	numWorkers := c.parallel
-	if numWorkers > runtime.NumCPU() {
-		numWorkers = runtime.NumCPU()
-	}
-
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for path := range fileChan {
-				result, err := c.processSingleFile(path, registry)
-				if err != nil {
-					errorChan <- fmt.Errorf("%s: %v", path, err)
-					continue
-				}
-				resultChan <- result
-			}
-		}(i)
-	}
-
-	// Send files to workers
-	for _, path := range inputPaths {
-		fileChan <- path
-	}
-	close(fileChan)
-
-	// Wait for workers to finish
-	go func() {
-		wg.Wait()
-		close(resultChan)
-		close(errorChan)
-	}()
-
-	// Collect results
-	for result := range resultChan {
-		results = append(results, result)
-	}
-
-	// Report errors
-	if !c.quiet {
-		for err := range errorChan {
-			fmt.Printf("%s %v\n", c.red("✗"), err)
+			fmt.Printf("%s Wrote %s (%d bytes)\n", c.cyan("→"), name, e.Size)
 		}
-	}
-
-	return results
-}
-
-// processSingleFile processes a single file
-func (c *ExtractCommand) processSingleFile(path string, registry *PluginRegistry) (ExtractionResult, error) {
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return ExtractionResult{}, fmt.Errorf("failed to read file: %v", err)
-	}
-
-	// Determine the appropriate extractor based on file extension or language flag
-	var extractor extractor.ExtractorPlugin
-	
-	if c.language != "" {
-		// Use the specified language
-		extractor = registry.GetExtractorByLanguage(c.language)
-	} else {
-		// Auto-detect based on file extension
-		ext := strings.ToLower(filepath.Ext(path))
-		extractor = registry.GetExtractorByExtension(ext)
-	}
-
-	if extractor == nil {
-		// Use generic extractor as fallback
-		extractor = registry.GetExtractorByLanguage("generic")
-	}
-
-	if extractor == nil {
-		return ExtractionResult{}, fmt.Errorf("no suitable extractor found for file: %s", path)
-	}
-
-	// Initialize the extractor
-	if err := extractor.Initialize(); err != nil {
-		return ExtractionResult{}, fmt.Errorf("failed to initialize extractor: %v", err)
-	}
-	defer extractor.Cleanup()
-
-	// Extract code blocks
-	blocks := extractor.Extract(string(content))
-
-	// Write extracted blocks to output directory
-	var writtenFiles []string
-	if !c.dryRun {
-		writtenFiles = c.writeExtractedBlocks(blocks, path)
-	}
-
-	return ExtractionResult{
-		SourceFile:    path,
-		ExtractorName: extractor.Name(),
-		CodeBlocks:    blocks,
-		WrittenFiles:  writtenFiles,
-	}, nil
-}
-
-// writeExtractedBlocks writes the extracted code blocks to the output directory
-func (c *ExtractCommand) writeExtractedBlocks(blocks []extractor.CodeBlock, sourceFile string) []string {
-	var writtenFiles []string
-
-	for i, block := range blocks {
-		// Create a unique filename based on the source file and block type
-		baseName := strings.TrimSuffix(filepath.Base(sourceFile), filepath.Ext(sourceFile))
-		var fileName string
-		
-		if block.Filename != "" {
-			fileName = block.Filename
+	case event.Error:
+		if e.Target != "" {
+			fmt.Printf("%s Failed to write file %s: %v\n", c.red("✗"), e.Target, e.Err)
 		} else {
-			fileName = fmt.Sprintf("%s_%s_%d%s", baseName, block.Type, i, getExtensionForLanguage(block.Language))
-		}
-
-		// Create output path
-		outputPath := filepath.Join(c.outputDir, fileName)
-
-		// Ensure the directory exists
-		dir := filepath.Dir(outputPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			fmt.Printf("%s Failed to create directory %s: %v\n", c.red("✗"), dir, err)
-			continue
-		}
-
-		// Write the content
-		if err := os.WriteFile(outputPath, []byte(block.Content), 0644); err != nil {
-			fmt.Printf("%s Failed to write file %s: %v\n", c.red("✗"), outputPath, err)
-			continue
+			fmt.Printf("%s Error processing %s: %v\n", c.red("✗"), e.Path, e.Err)
 		}
-
-		writtenFiles = append(writtenFiles, outputPath)
-		
-		if c.verbose && !c.quiet {
-			fmt.Printf("%s Wrote %s (%d bytes)\n", c.cyan("→"), fileName, len(block.Content))
-		}
-	}
-
-	return writtenFiles
-}
-
-// getExtensionForLanguage returns the appropriate file extension for a language
-func getExtensionForLanguage(lang string) string {
-	switch strings.ToLower(lang) {
-	case "go", "golang":
-		return ".go"
-	case "java":
-		return ".java"
-	case "python":
-		return ".py"
-	case "javascript", "js":
-		return ".js"
-	case "typescript", "ts":
-		return ".ts"
-	case "rust":
-		return ".rs"
-	case "dart":
-		return ".dart"
-	case "json":
-		return ".json"
-	case "xml":
-		return ".xml"
-	case "yaml", "yml":
-		return ".yaml"
-	case "markdown", "md":
-		return ".md"
-	case "text", "txt":
-		return ".txt"
-	default:
-		return ".txt"
 	}
 }
 
 // generateReport generates a detailed report of the extraction
-func (c *ExtractCommand) generateReport(results []ExtractionResult) {
+func (c *ExtractCommand) generateReport(results []extract.ExtractionResult) {
 	fmt.Printf("\n%s Extraction Report\n", c.cyan("📊"))
 	fmt.Printf("%s %s\n", c.cyan("┌"), strings.Repeat("─", 50))
-	
+
 	totalBlocks := 0
 	totalFiles := 0
 	for _, result := range results {
@@ -499,21 +423,13 @@ func (c *ExtractCommand) generateReport(results []ExtractionResult) {
 	if c.verbose {
 		fmt.Printf("%s Detailed breakdown:\n", c.cyan("│"))
 		for _, result := range results {
-			fmt.Printf("%s   %s: %d blocks -> %d files\n", 
-				c.cyan("│"), 
-				result.SourceFile, 
-				len(result.CodeBlocks), 
+			fmt.Printf("%s   %s: %d blocks -> %d files\n",
+				c.cyan("│"),
+				result.SourceFile,
+				len(result.CodeBlocks),
 				len(result.WrittenFiles))
 		}
 	}
 
 	fmt.Printf("%s %s\n", c.cyan("└"), strings.Repeat("─", 50))
 }
-
-// ExtractionResult holds the result of extracting code blocks from a file
-type ExtractionResult struct {
-	SourceFile    string
-	ExtractorName string
-	CodeBlocks    []extractor.CodeBlock
-	WrittenFiles  []string
-}
\ No newline at end of file
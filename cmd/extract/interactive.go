@@ -0,0 +1,181 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/extract"
+	"github.com/bhangun/coto/pkg/interrupt"
+	"github.com/bhangun/coto/pkg/prompt"
+	"github.com/bhangun/coto/pkg/runlog"
+)
+
+// autoLanguage is the language choice that detects the language per file
+const autoLanguage = "auto"
+
+// interactive asks for the input files and the language, lists the code
+// blocks found and asks for the output directory and the name of each
+// block before writing them. A nil result means the user canceled.
+func (c *ExtractCommand) interactive(p *prompt.Prompter, w io.Writer) (*extract.Result, error) {
+	fmt.Fprintf(w, "%s Coto Extract - Interactive Mode\n\n", c.cyan("📁"))
+
+	answer := p.String("Files to extract code from (comma-separated, globs allowed)", c.inputFiles, func(s string) error {
+		if len(splitInputs(s)) == 0 {
+			return fmt.Errorf("no input files given")
+		}
+		_, err := extract.ExpandInputs(nil, splitInputs(s))
+		return err
+	})
+	if len(splitInputs(answer)) == 0 {
+		return nil, runlog.Usage(fmt.Errorf("input files are required"))
+	}
+	inputs, err := extract.ExpandInputs(nil, splitInputs(answer))
+	if err != nil {
+		return nil, err
+	}
+
+	registry := extract.NewBuiltinRegistry()
+	var languages []string
+	for _, plugin := range registry.GetAllPlugins() {
+		languages = append(languages, plugin.Name())
+	}
+	sort.Strings(languages)
+	language := c.language
+	if language == "" {
+		language = autoLanguage
+	}
+	c.language = p.Select("Language of the code blocks", append([]string{autoLanguage}, languages...), language)
+	if c.language == autoLanguage {
+		c.language = ""
+	}
+
+	// A dry run finds the blocks; they are written once named. Ctrl-C,
+	// SIGTERM and -timeout stop it like a run with flags.
+	ctx, stop := interrupt.Context(c.timeout)
+	defer stop()
+	result, err := extract.Run(ctx, extract.Options{
+		Inputs:     inputs,
+		Language:   c.language,
+		Parallel:   c.parallel,
+		DryRun:     true,
+		Registry:   registry,
+		Passphrase: c.passphrase,
+		OnEvent:    c.log.Handler(c.printEvent),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Blocks() == 0 {
+		fmt.Fprintf(w, "%s No code blocks found\n", c.yellow("⚠"))
+		return result, runlog.Partial(result.Errors())
+	}
+	c.printBlocks(w, result)
+
+	c.outputDir = p.String("Output directory", c.outputDir, nil)
+	if p.Bool("Choose the file name of each block", false) {
+		c.nameBlocks(p, result)
+	}
+
+	blocks := result.Blocks()
+	if blocks == 0 {
+		fmt.Fprintf(w, "%s Every block was skipped\n", c.yellow("⚠"))
+		return nil, nil
+	}
+	if c.dryRun {
+		fmt.Fprintf(w, "%s DRY RUN MODE - No files will be written\n", c.yellow("⚠"))
+		return result, runlog.Partial(result.Errors())
+	}
+	if !p.Bool(fmt.Sprintf("Write %d files to %s", blocks, c.outputDir), true) || p.Done() {
+		return nil, nil
+	}
+
+	if err := result.Write(nil, c.outputDir, c.log.Handler(c.printEvent)); err != nil {
+		return nil, err
+	}
+	for _, f := range result.Files {
+		for _, written := range f.WrittenFiles {
+			fmt.Fprintf(w, "%s %s\n", c.green("✓"), written)
+		}
+	}
+	fmt.Fprintf(w, "\n%s Wrote %d of %d code blocks to %s\n", c.green("✓"), result.Written(), blocks, c.outputDir)
+	return result, runlog.Partial(result.Errors())
+}
+
+// printBlocks lists the code blocks found with the file each is written to
+func (c *ExtractCommand) printBlocks(w io.Writer, result *extract.Result) {
+	fmt.Fprintf(w, "\n%s %s\n", c.cyan("┌"), strings.Repeat("─", 50))
+	fmt.Fprintf(w, "%s Found %d code blocks in %d files\n", c.cyan("│"), result.Blocks(), len(result.Files))
+	fmt.Fprintf(w, "%s %s\n", c.cyan("├"), strings.Repeat("─", 50))
+	n := 0
+	for _, f := range result.Files {
+		for i, block := range f.CodeBlocks {
+			n++
+			lines := strings.Count(strings.TrimRight(block.Content, "\n"), "\n") + 1
+			fmt.Fprintf(w, "%s %3d  %-20s %-12s %-10s %5d lines  %s %s\n", c.cyan("│"), n, filepath.Base(f.SourceFile),
+				block.Type, block.Language, lines, c.cyan("→"), c.green(f.FileName(i)))
+		}
+	}
+	fmt.Fprintf(w, "%s %s\n", c.cyan("└"), strings.Repeat("─", 50))
+}
+
+// nameBlocks asks for the file name of every block. Answering - skips the
+// block.
+func (c *ExtractCommand) nameBlocks(p *prompt.Prompter, result *extract.Result) {
+	used := map[string]bool{}
+	n := 0
+	for i := range result.Files {
+		f := &result.Files[i]
+		// Names are fixed before blocks are dropped, as the default names
+		// depend on the position of the block
+		names := make([]string, len(f.CodeBlocks))
+		for j, block := range f.CodeBlocks {
+			n++
+			question := fmt.Sprintf("File of block %d (%s %s, - skips it)", n, block.Language, block.Type)
+			names[j] = p.String(question, f.FileName(j), func(name string) error {
+				return validateBlockName(name, used)
+			})
+			used[filepath.Clean(names[j])] = names[j] != "-"
+		}
+
+		kept := f.CodeBlocks[:0]
+		for j, block := range f.CodeBlocks {
+			if names[j] != "-" {
+				block.Filename = names[j]
+				kept = append(kept, block)
+			}
+		}
+		f.CodeBlocks = kept
+	}
+}
+
+// validateBlockName accepts names relative to the output directory that no
+// other block uses
+func validateBlockName(name string, used map[string]bool) error {
+	if name == "-" {
+		return nil
+	}
+	clean := filepath.Clean(name)
+	switch {
+	case filepath.IsAbs(name):
+		return fmt.Errorf("%s must be relative to the output directory", name)
+	case clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)):
+		return fmt.Errorf("%s is outside the output directory", name)
+	case used[clean]:
+		return fmt.Errorf("%s is already used by another block", name)
+	}
+	return nil
+}
+
+// splitInputs splits comma-separated input files
+func splitInputs(s string) []string {
+	var inputs []string
+	for _, input := range strings.Split(s, ",") {
+		if input = strings.TrimSpace(input); input != "" {
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs
+}
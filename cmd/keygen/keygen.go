@@ -0,0 +1,88 @@
+package keygen
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bhangun/coto/pkg/signing"
+	"github.com/fatih/color"
+)
+
+// KeygenCommand handles the keygen subcommand
+type KeygenCommand struct {
+	// Flags
+	output string
+	force  bool
+
+	// Internal fields
+	cyan   func(...interface{}) string
+	green  func(...interface{}) string
+	yellow func(...interface{}) string
+}
+
+// NewKeygenCommand creates a new keygen command instance
+func NewKeygenCommand() *KeygenCommand {
+	return &KeygenCommand{
+		cyan:   color.New(color.FgCyan).SprintFunc(),
+		green:  color.New(color.FgGreen).SprintFunc(),
+		yellow: color.New(color.FgYellow).SprintFunc(),
+	}
+}
+
+// Run executes the keygen command
+func (c *KeygenCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	fs.StringVar(&c.output, "output", "coto_ed25519", "Private key path; the public key gets a .pub suffix")
+	fs.StringVar(&c.output, "o", "coto_ed25519", "Private key path (shorthand)")
+	fs.BoolVar(&c.force, "force", false, "Overwrite existing key files")
+
+	// Help flag
+	help := fs.Bool("help", false, "Show help")
+	h := fs.Bool("h", false, "Show help (shorthand)")
+	fs.Usage = c.printHelp
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *help || *h {
+		c.printHelp()
+		return nil
+	}
+
+	if !c.force {
+		for _, path := range []string{c.output, c.output + ".pub"} {
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%s already exists (use -force to overwrite)", path)
+			}
+		}
+	}
+
+	pub, priv, err := signing.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := signing.WriteKeyPair(c.output, pub, priv); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s Private key: %s %s\n", c.green("✓"), c.output, c.yellow("(keep this secret)"))
+	fmt.Printf("%s Public key:  %s.pub\n", c.green("✓"), c.output)
+	fmt.Printf("%s Key id:      %s\n", c.cyan("→"), signing.KeyID(pub))
+	return nil
+}
+
+func (c *KeygenCommand) printHelp() {
+	fmt.Fprintf(os.Stderr, "%s Coto Keygen - Create an ed25519 key pair for signing bundles\n\n", c.cyan("🔑"))
+	fmt.Fprintf(os.Stderr, "Usage: coto keygen [options]\n\n")
+
+	fmt.Fprintf(os.Stderr, "%s Options:\n", c.cyan("📋"))
+	fmt.Fprintf(os.Stderr, "  -o, -output string   Private key path (default \"coto_ed25519\")\n")
+	fmt.Fprintf(os.Stderr, "  -force               Overwrite existing key files\n")
+	fmt.Fprintf(os.Stderr, "  -h, -help            Show this help message\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Examples:\n", c.cyan("🚀"))
+	fmt.Fprintf(os.Stderr, "  coto keygen -o team.key\n")
+	fmt.Fprintf(os.Stderr, "  coto -sign team.key -o bundle.txt ./src\n")
+	fmt.Fprintf(os.Stderr, "  coto verify -pubkey team.key.pub bundle.txt\n")
+}
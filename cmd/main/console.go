@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/bhangun/coto/pkg/combine"
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/interrupt"
+)
+
+// printEvents returns the handler that prints combine events to the terminal
+func printEvents(explain, verbose, quiet bool) event.Handler {
+	return func(e event.Event) {
+		switch e.Kind {
+		case event.Included, event.Skipped:
+			if explain {
+				printExplanation(e.Path, e.Dir, e.Kind == event.Included, e.Reason)
+			}
+		case event.Warning:
+			if !quiet {
+				fmt.Printf("%s %s: %s -> %s\n", yellow("⚠"), linkLabel(e.Reason), e.Path, e.Target)
+			}
+		case event.Error:
+			if !quiet {
+				fmt.Printf("%s Error %s %s: %v\n", red("✗"), e.Reason, e.Path, e.Err)
+			}
+		case event.Processed:
+			if quiet {
+				return
+			}
+			if verbose {
+				fmt.Printf("%s Processed file %d/%d: %s\n", cyan("↳"), e.Done, e.Total, e.Path)
+			} else if e.Total > 10 && e.Done%(e.Total/10+1) == 0 {
+				// Show progress for larger operations
+				progress := float64(e.Done) / float64(e.Total) * 100
+				fmt.Printf("%s Progress: %d/%d files (%.1f%%)\n", cyan("→"), e.Done, e.Total, progress)
+			}
+		}
+	}
+}
+
+func linkLabel(reason string) string {
+	if reason == combine.ReasonSymlinkCycle {
+		return "Skipping symlink cycle"
+	}
+	return "Broken symlink"
+}
+
+// printExplanation prints a single --explain line
+func printExplanation(path string, isDir, included bool, reason string) {
+	if isDir {
+		path += string(filepath.Separator)
+	}
+	if included {
+		fmt.Printf("  %s %s  %s\n", green("+"), path, reason)
+	} else {
+		fmt.Printf("  %s %s  %s\n", red("-"), path, yellow(reason))
+	}
+}
+
+// printCanceled reports how far a canceled run got and what happened to the output
+func printCanceled(ctx context.Context, result *combine.Result, total int, dryRun bool) {
+	fmt.Printf("\n%s %s after %d of %d files\n", yellow("⚠"), interrupt.Reason(ctx), result.Stats.FilesProcessed, total)
+	switch {
+	case dryRun:
+	case result.OutputFile != "":
+		fmt.Printf("%s Partial bundle written to %s\n", cyan("→"), result.OutputFile)
+	default:
+		fmt.Printf("%s No output written (use -keep-partial to keep the files processed so far)\n", cyan("→"))
+	}
+}
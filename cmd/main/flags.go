@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+// stringListFlag collects every occurrence of a repeatable flag
+type stringListFlag struct {
+	values []string
+}
+
+func (f *stringListFlag) String() string {
+	return strings.Join(f.values, ",")
+}
+
+func (f *stringListFlag) Set(s string) error {
+	f.values = append(f.values, s)
+	return nil
+}
+
+func (f *stringListFlag) Get() any {
+	return f.values
+}
+
+// parseInterspersed parses flags that may be mixed with positional arguments
+// and returns the positional ones. Everything after "--" is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		consumed := args[:len(args)-len(rest)]
+		if len(consumed) > 0 && consumed[len(consumed)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// ruleListFlag appends "+ pattern" or "- pattern" rules to a shared list so
+// that -include-glob, -exclude-glob and -rules keep their command line order
+type ruleListFlag struct {
+	rules  *[]string
+	prefix string
+}
+
+func (f *ruleListFlag) String() string {
+	if f.rules == nil {
+		return ""
+	}
+	return strings.Join(*f.rules, "; ")
+}
+
+func (f *ruleListFlag) Set(s string) error {
+	*f.rules = append(*f.rules, f.prefix+s)
+	return nil
+}
+
+func (f *ruleListFlag) Get() any {
+	if f.rules == nil {
+		return []string(nil)
+	}
+	return *f.rules
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestCompressionFlags(t *testing.T) {
+	parse := func(args ...string) (*compressFlag, *codecFlag, []string) {
+		t.Helper()
+		fs := flag.NewFlagSet("coto", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		compress, codec := &compressFlag{}, &codecFlag{}
+		fs.Var(compress, "compress", "")
+		fs.Var(codec, "compression", "")
+		if err := fs.Parse(args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return compress, codec, fs.Args()
+	}
+
+	if compress, _, rest := parse("-compress", "src"); compress.value != "true" || len(rest) != 1 || rest[0] != "src" {
+		t.Errorf("Expected bare -compress to leave src as an input, got %q and %v", compress.value, rest)
+	}
+	if compress, _, _ := parse("-compress=zstd"); compress.value != "zstd" {
+		t.Errorf("Expected -compress=zstd to select zstd, got %q", compress.value)
+	}
+	if _, codec, rest := parse("-compression", "xz", "src"); codec.value != "xz" || len(rest) != 1 {
+		t.Errorf("Expected -compression xz to take the codec, got %q and %v", codec.value, rest)
+	}
+
+	fs := flag.NewFlagSet("coto", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&codecFlag{}, "compression", "")
+	if err := fs.Parse([]string{"-compression", "rar"}); err == nil {
+		t.Error("Expected an unknown codec to be rejected")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/combine"
+	"github.com/bhangun/coto/pkg/compression"
+	"github.com/bhangun/coto/pkg/picker"
+	promptpkg "github.com/bhangun/coto/pkg/prompt"
+	"github.com/bhangun/coto/pkg/settings"
+)
+
+// unsavedKeys are answers that only make sense for the current run
+var unsavedKeys = map[string]bool{"dry_run": true}
+
+// promptProfile offers the profiles of the user and project files and
+// returns the chosen one, or "" when there are none or none was chosen
+func promptProfile() string {
+	profiles, err := settings.Loader{Schema: settings.StructSchema(Config{})}.Profiles()
+	if err != nil {
+		fmt.Printf("%s Cannot read profiles: %v\n", yellow("⚠"), err)
+		return ""
+	}
+	if len(profiles) == 0 {
+		return ""
+	}
+
+	const none = "none"
+	options := []string{none}
+	for _, p := range profiles {
+		label := p.Name
+		if p.Description != "" {
+			label += " - " + p.Description
+		}
+		options = append(options, label)
+	}
+	choice := promptSelect("Select a profile", options, none)
+	for i, option := range options[1:] {
+		if option == choice {
+			return profiles[i].Name
+		}
+	}
+	return ""
+}
+
+// promptDefaults returns the configuration the answers start from: the
+// user and project files of the working directory and the chosen profile
+func promptDefaults(profile string) Config {
+	layers, err := loadSettings("", profile, nil)
+	var config Config
+	if err == nil {
+		err = layers.Decode(&config)
+	}
+	if err != nil {
+		fmt.Printf("%s Cannot prefill answers from the configuration: %v\n", yellow("⚠"), err)
+		return Config{OutputFile: "combined.txt", ExcludeHidden: true, OutputFormat: "text", Parallel: 1}
+	}
+	return config
+}
+
+// promptCombineFlags asks for the main combine options, suggesting the
+// values of defaults, and sets the answers as flags. The extension and
+// pattern questions are left to the file picker when it is used.
+func promptCombineFlags(defaults Config, usePicker bool) {
+	// Prompt for input directory with validation
+	input := "."
+	if roots := defaults.Roots(); len(roots) == 1 {
+		input = roots[0]
+	}
+	flag.Set("input", promptUserWithValidation("Enter input directory path", input, validateDirectory))
+
+	// Prompt for output file with validation
+	flag.Set("output", promptUserWithValidation("Enter output file path", defaults.OutputFile, validateFilePath))
+
+	// Prompt for output format
+	formats := []string{"text", "json", "xml", "markdown"}
+	flag.Set("format", promptSelect("Select output format", formats, defaults.OutputFormat))
+
+	// Prompt for excluding hidden files
+	flag.Set("exclude-hidden", strconv.FormatBool(promptBool("Exclude hidden files and directories", defaults.ExcludeHidden)))
+
+	// Prompt for compression
+	codec, _ := compression.Parse(defaults.Compression)
+	codecs := append([]string{"none"}, compression.Names()...)
+	if answer := promptSelect("Select output compression", codecs, codec.String()); answer != "none" || codec != compression.None {
+		flag.Set("compress", answer)
+	}
+
+	// Prompt for max file size
+	maxSizeStr := promptUser("Maximum file size in bytes (0 for unlimited)", strconv.FormatInt(defaults.MaxFileSize, 10))
+	if val, err := strconv.ParseInt(maxSizeStr, 10, 64); err == nil && val >= 0 {
+		flag.Set("max-size", maxSizeStr)
+	}
+
+	// Without the picker, files are selected by extension and patterns
+	if !usePicker {
+		// Prompt for file extensions with validation
+		extInput := promptOptional("Enter file extensions to include (comma-separated, e.g., .go,.js,.py)",
+			strings.Join(defaults.Extensions, ","), validateExtensions)
+		if extInput != "" || len(defaults.Extensions) > 0 {
+			flag.Set("ext", extInput)
+		}
+
+		// Prompt for exclude pattern
+		if excludePat := promptOptional("Regex pattern to exclude files", defaults.ExcludePattern, nil); excludePat != "" || defaults.ExcludePattern != "" {
+			flag.Set("exclude", excludePat)
+		}
+
+		// Prompt for include pattern
+		if includePat := promptOptional("Regex pattern to include files", defaults.IncludePattern, nil); includePat != "" || defaults.IncludePattern != "" {
+			flag.Set("include", includePat)
+		}
+	}
+
+	// Prompt for parallel processing with validation
+	parallel := "1"
+	if defaults.Parallel > 0 {
+		parallel = strconv.Itoa(defaults.Parallel)
+	}
+	for {
+		parallelStr := promptUser("Number of files to process in parallel", parallel)
+		if val, err := strconv.Atoi(parallelStr); err == nil && val > 0 {
+			flag.Set("parallel", parallelStr)
+			break
+		}
+		fmt.Printf("%s Parallel value must be a positive integer\n", red("✗"))
+	}
+
+	// Prompt for verbose mode
+	flag.Set("verbose", strconv.FormatBool(promptBool("Enable verbose output", defaults.Verbose)))
+
+	// Prompt for dry run
+	flag.Set("dry-run", strconv.FormatBool(promptBool("Perform dry run (show what would be processed without writing)", defaults.DryRun)))
+}
+
+// promptOptional asks for a value that may be left empty. A suggested
+// value is cleared by answering "-".
+func promptOptional(prompt, defaultValue string, validator func(string) error) string {
+	return promptpkg.Stdio().Optional(prompt, defaultValue, validator)
+}
+
+// pickFiles lets the user check and uncheck the collected files in the
+// full-screen picker and keeps the selected ones
+func pickFiles(collected *combine.Collection) error {
+	files := make([]picker.File, 0, len(collected.Paths))
+	for _, path := range collected.Paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		name := path
+		if rel, err := filepath.Rel(collected.BaseDir, path); err == nil && collected.BaseDir != "" {
+			name = rel
+		}
+		files = append(files, picker.File{Path: path, Name: filepath.ToSlash(name), Size: info.Size()})
+	}
+	paths, err := picker.Run("Select files", files, os.Stdin, os.Stdout)
+	if err != nil {
+		return err
+	}
+	collected.Paths = paths
+	return nil
+}
+
+// confirmInteractive previews the selected files, offers to save the
+// answers and asks whether to go on
+func confirmInteractive(layers *settings.Settings, collected *combine.Collection, config Config) bool {
+	printPreview(collected, config)
+
+	answers := make(map[string]any)
+	for _, v := range layers.Values() {
+		if v.Source == settings.Flag && !unsavedKeys[v.Key] {
+			answers[v.Key] = v.Value
+		}
+	}
+	if len(answers) > 0 {
+		promptSave(answers)
+	}
+
+	fmt.Println()
+	return promptBool("Start processing", true)
+}
+
+// printPreview shows what a run would bundle. Sizes come from the file
+// system and tokens are estimated as in coto tree.
+func printPreview(collected *combine.Collection, config Config) {
+	fmt.Printf("\n%s %s\n", cyan("┌"), strings.Repeat("─", 50))
+	fmt.Printf("%s Preview\n", cyan("│"))
+	fmt.Printf("%s %s\n", cyan("├"), strings.Repeat("─", 50))
+	root, err := buildTree(collected.Paths, collected.BaseDir)
+	if err != nil {
+		fmt.Printf("%s Files:               %s\n", cyan("│"), green(strconv.Itoa(len(collected.Paths))))
+		fmt.Printf("%s %s\n", cyan("│"), yellow(err.Error()))
+	} else {
+		fmt.Printf("%s Files:               %s\n", cyan("│"), green(strconv.Itoa(root.Files)))
+		fmt.Printf("%s Total size:          %s\n", cyan("│"), green(combine.FormatBytes(root.Size)))
+		fmt.Printf("%s Estimated tokens:    ~%s\n", cyan("│"), green(combine.FormatCount(root.Tokens)))
+	}
+	output := config.OutputPath()
+	if config.DryRun {
+		output = "none (dry run)"
+	}
+	fmt.Printf("%s Output:              %s (%s)\n", cyan("│"), green(output), config.OutputFormat)
+	fmt.Printf("%s %s\n", cyan("└"), strings.Repeat("─", 50))
+}
+
+// promptSave offers to save the answers in the project file, either at its
+// top level or as a profile
+func promptSave(answers map[string]any) {
+	const (
+		no      = "no"
+		project = "project config"
+		profile = "profile"
+	)
+	choice := promptSelect("Save these answers for the next run", []string{no, project, profile}, no)
+	if choice == no {
+		return
+	}
+
+	path, _ := settings.FindProject(".")
+	if path == "" {
+		path = settings.ProjectFiles[1]
+	} else if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			path = rel
+		}
+	}
+	path = promptUserWithValidation("Save to file", path, settings.CanSave)
+
+	name := ""
+	if choice == profile {
+		name = promptUserWithValidation("Profile name", "", validateProfileName)
+	}
+	if err := settings.Save(path, name, answers); err != nil {
+		fmt.Printf("%s Could not save the answers: %v\n", red("✗"), err)
+		return
+	}
+	if name != "" {
+		fmt.Printf("%s Saved profile %s to %s, use it with -profile %s\n", green("✓"), name, path, name)
+	} else {
+		fmt.Printf("%s Saved the answers to %s\n", green("✓"), path)
+	}
+}
+
+// validateProfileName accepts names that can be given to -profile
+func validateProfileName(name string) error {
+	if name == "" || name == "none" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("profile names must be non-empty, not \"none\" and without spaces")
+	}
+	return nil
+}
@@ -1,63 +1,37 @@
 package main
 
 import (
-	"bufio"
-	"compress/gzip"
-	"encoding/json"
-	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
-	"time"
 
-	"github.com/fatih/color"
+	"github.com/bhangun/coto/cmd/diff"
 	"github.com/bhangun/coto/cmd/extract"
+	"github.com/bhangun/coto/cmd/keygen"
 	"github.com/bhangun/coto/cmd/rename"
+	"github.com/bhangun/coto/cmd/unpack"
+	"github.com/bhangun/coto/cmd/verify"
+	"github.com/bhangun/coto/pkg/combine"
+	"github.com/bhangun/coto/pkg/compression"
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/interrupt"
+	"github.com/bhangun/coto/pkg/picker"
+	promptpkg "github.com/bhangun/coto/pkg/prompt"
+	"github.com/bhangun/coto/pkg/runlog"
+	"github.com/bhangun/coto/pkg/signing"
+	"github.com/fatih/color"
 )
 
 const (
-	version = "0.1.1"
+	version = combine.Version
 )
 
-type Config struct {
-	InputDir       string   `json:"input_dir"`
-	OutputFile     string   `json:"output_file"`
-	Extensions     []string `json:"extensions"`
-	ExcludeHidden  bool     `json:"exclude_hidden"`
-	MaxFileSize    int64    `json:"max_file_size"`
-	MinFileSize    int64    `json:"min_file_size"`
-	ExcludePattern string   `json:"exclude_pattern"`
-	IncludePattern string   `json:"include_pattern"`
-	OutputFormat   string   `json:"output_format"`
-	Compress       bool     `json:"compress"`
-	Parallel       int      `json:"parallel"`
-	Quiet          bool     `json:"quiet"`
-	Verbose        bool     `json:"verbose"`
-	DryRun         bool     `json:"dry_run"`
-}
-
-type FileInfo struct {
-	Path         string `json:"path" xml:"path"`
-	Size         int64  `json:"size" xml:"size"`
-	Modified     string `json:"modified" xml:"modified"`
-	Content      string `json:"content,omitempty" xml:"content,omitempty"`
-	RelativePath string `json:"relative_path" xml:"relative_path"`
-}
-
-type Stats struct {
-	FilesProcessed int     `json:"files_processed"`
-	Directories    int     `json:"directories"`
-	TotalBytes     int64   `json:"total_bytes"`
-	Duration       float64 `json:"duration_seconds"`
-	OutputSize     int64   `json:"output_size"`
-}
+// Config is the combine configuration, loaded from -config files and flags
+type Config = combine.Options
 
 var (
 	cyan   = color.New(color.FgCyan).SprintFunc()
@@ -66,6 +40,38 @@ var (
 	red    = color.New(color.FgRed).SprintFunc()
 )
 
+// codecFlag takes a compression codec name (-compression zstd)
+type codecFlag struct {
+	value string
+}
+
+func (f *codecFlag) String() string {
+	return f.value
+}
+
+func (f *codecFlag) Set(s string) error {
+	if _, err := compression.Parse(s); err != nil {
+		return err
+	}
+	f.value = s
+	return nil
+}
+
+func (f *codecFlag) Get() any {
+	return f.value
+}
+
+// compressFlag accepts both the legacy boolean form (-compress) and a codec
+// name (-compress=zstd). Being boolean, -compress zstd leaves zstd as an
+// input; -compression zstd takes the codec as the next argument.
+type compressFlag struct {
+	codecFlag
+}
+
+func (f *compressFlag) IsBoolFlag() bool {
+	return true
+}
+
 // Function to check if any flags were provided
 func hasFlagsProvided() bool {
 	return len(os.Args) > 1
@@ -111,44 +117,12 @@ func validateExtensions(extStr string) error {
 	if extStr == "" {
 		return nil
 	}
-
-	extensions := strings.Split(extStr, ",")
-	for _, ext := range extensions {
-		ext = strings.TrimSpace(ext)
-		if !strings.HasPrefix(ext, ".") && ext != "*" {
-			return fmt.Errorf("extension '%s' should start with a dot (.) or be '*' for all files", ext)
-		}
-	}
-	return nil
+	return combine.ValidateExtensions(strings.Split(extStr, ","))
 }
 
 // Function to prompt user for input with validation
 func promptUserWithValidation(prompt string, defaultValue string, validator func(string) error) string {
-	for {
-		fmt.Printf("%s %s", cyan("?"), prompt)
-
-		if defaultValue != "" {
-			fmt.Printf(" (default: %s)", defaultValue)
-		}
-		fmt.Print(": ")
-
-		reader := bufio.NewReader(os.Stdin)
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
-
-		if input == "" {
-			input = defaultValue
-		}
-
-		if validator != nil {
-			if err := validator(input); err != nil {
-				fmt.Printf("%s %s\n", red("✗"), err.Error())
-				continue
-			}
-		}
-
-		return input
-	}
+	return promptpkg.Stdio().String(prompt, defaultValue, validator)
 }
 
 // Function to prompt user for input
@@ -158,58 +132,12 @@ func promptUser(prompt string, defaultValue string) string {
 
 // Function to prompt user for boolean input
 func promptBool(prompt string, defaultValue bool) bool {
-	fmt.Printf("%s %s (Y/n)", cyan("?"), prompt)
-	if defaultValue {
-		fmt.Print(" [Y]: ")
-	} else {
-		fmt.Print(" [n]: ")
-	}
-
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(strings.ToLower(input))
-
-	if input == "" {
-		return defaultValue
-	}
-
-	return input == "y" || input == "yes" || input == "true" || input == "1"
+	return promptpkg.Stdio().Bool(prompt, defaultValue)
 }
 
 // Function to prompt user for selection from options
 func promptSelect(prompt string, options []string, defaultValue string) string {
-	fmt.Printf("%s %s\n", cyan("?"), prompt)
-	for i, option := range options {
-		fmt.Printf("  %d) %s", i+1, option)
-		if option == defaultValue {
-			fmt.Print(" (default)")
-		}
-		fmt.Println()
-	}
-	fmt.Print(": ")
-
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
-
-	if input == "" {
-		return defaultValue
-	}
-
-	// Try to parse as number
-	if num, err := strconv.Atoi(input); err == nil && num >= 1 && num <= len(options) {
-		return options[num-1]
-	}
-
-	// Check if input matches any option
-	for _, option := range options {
-		if strings.EqualFold(option, input) {
-			return option
-		}
-	}
-
-	// Return default if input doesn't match
-	return defaultValue
+	return promptpkg.Stdio().Select(prompt, options, defaultValue)
 }
 
 func printMainHelp() {
@@ -219,11 +147,25 @@ func printMainHelp() {
 	fmt.Println("  coto [options]                  # Combine files (default)")
 	fmt.Println("  coto extract [options]          # Extract code blocks")
 	fmt.Println("  coto rename [options]           # Rename files based on patterns")
+	fmt.Println("  coto verify <bundle> [dir]      # Check a bundle against a directory")
+	fmt.Println("  coto stats [options] [path ...] # Language and line statistics")
+	fmt.Println("  coto diff <old> <new>           # Diff bundles or a bundle and a directory")
+	fmt.Println("  coto tree [options] [path ...]  # Show the files a filter set selects")
+	fmt.Println("  coto keygen [options]           # Create an ed25519 signing key pair")
+	fmt.Println("  coto unpack [options] <bundle>  # Restore files from a bundle")
+	fmt.Println("  coto config show [command]      # Show the merged configuration")
+	fmt.Println("  coto profiles [dir]             # List the configured profiles")
 	fmt.Println("  coto version                    # Show version")
 	fmt.Println("  coto help                       # Show this help")
 	fmt.Println("\nFor command-specific help:")
 	fmt.Println("  coto extract --help")
 	fmt.Println("  coto rename --help")
+	fmt.Println("  coto verify --help")
+	fmt.Println("  coto stats --help")
+	fmt.Println("  coto diff --help")
+	fmt.Println("  coto tree --help")
+	fmt.Println("  coto config --help")
+	fmt.Println("  coto profiles --help")
 	fmt.Println()
 }
 
@@ -235,13 +177,67 @@ func main() {
 			// Run extract subcommand
 			cmd := extract.NewExtractCommand()
 			if err := cmd.Run(os.Args[2:]); err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				if !runlog.Logged(err) {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				}
+				os.Exit(runlog.ExitCode(err))
 			}
 			return
 		case "rename":
 			// Run rename subcommand
 			cmd := rename.NewRenameCommand()
+			if err := cmd.Run(os.Args[2:]); err != nil {
+				if !runlog.Logged(err) {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				}
+				os.Exit(runlog.ExitCode(err))
+			}
+			return
+		case "verify":
+			// Run verify subcommand
+			cmd := verify.NewVerifyCommand()
+			if err := cmd.Run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "unpack":
+			// Run unpack subcommand
+			cmd := unpack.NewUnpackCommand()
+			if err := cmd.Run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "diff":
+			// Run diff subcommand
+			cmd := diff.NewDiffCommand()
+			if err := cmd.Run(os.Args[2:]); err != nil {
+				if !errors.Is(err, diff.ErrDiffer) {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				}
+				os.Exit(runlog.ExitCode(err))
+			}
+			return
+		case "stats":
+			// Stats reuse the combine flags and filters
+			runCombineCommand(os.Args[2:], modeStats)
+			return
+		case "tree":
+			// Tree applies the combine filters without reading or writing files
+			runCombineCommand(os.Args[2:], modeTree)
+			return
+		case "config":
+			// Show the merged configuration and where each value came from
+			runConfigCommand(os.Args[2:])
+			return
+		case "profiles":
+			// List the named profiles of the configuration files
+			runProfilesCommand(os.Args[2:])
+			return
+		case "keygen":
+			// Run keygen subcommand
+			cmd := keygen.NewKeygenCommand()
 			if err := cmd.Run(os.Args[2:]); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
@@ -257,659 +253,389 @@ func main() {
 	}
 
 	// Default: run file combiner (backward compatibility)
-	runCombineCommand()
+	runCombineCommand(os.Args[1:], modeCombine)
+}
+
+// runMode selects what runCombineCommand does with the selected files.
+// Every mode shares the combine flags, config file and filters.
+type runMode int
+
+const (
+	modeCombine runMode = iota
+	modeStats
+	modeTree
+	modeConfig
+)
+
+// String returns the command name of the mode
+func (m runMode) String() string {
+	switch m {
+	case modeStats:
+		return "stats"
+	case modeTree:
+		return "tree"
+	case modeConfig:
+		return "config"
+	}
+	return "combine"
 }
 
-func runCombineCommand() {
-	// Define command line flags with short versions
-	inputDir := flag.String("input", ".", "Input directory path")
-	inputShort := flag.String("i", "", "Input directory path (shorthand)")
-	outputFile := flag.String("output", "combined.txt", "Output file path")
-	outputShort := flag.String("o", "", "Output file path (shorthand)")
-	extensions := flag.String("ext", "", "Comma-separated list of file extensions to include")
-	excludeHidden := flag.Bool("exclude-hidden", true, "Exclude hidden files and directories")
-	excludeShort := flag.Bool("eh", true, "Exclude hidden files (shorthand)")
-	maxFileSize := flag.Int64("max-size", 0, "Maximum file size in bytes (0 = unlimited)")
-	minFileSize := flag.Int64("min-size", 0, "Minimum file size in bytes")
-	excludePattern := flag.String("exclude", "", "Regex pattern to exclude files")
-	includePattern := flag.String("include", "", "Regex pattern to include files")
+func runCombineCommand(args []string, mode runMode) {
+	// Define command line flags with short versions. Their values are read
+	// through the merged configuration, see combineBindings.
+	inputs := &stringListFlag{}
+	flag.Var(inputs, "input", "Input directory, file or glob (repeatable)")
+	flag.Var(inputs, "i", "Input directory, file or glob (shorthand)")
+	flag.String("files-from", "", "Read paths to include from a file (\"-\" for stdin)")
+	flag.Bool("0", false, "Paths in -files-from are NUL-separated")
+	flag.String("output", "combined.txt", "Output file path")
+	flag.String("o", "", "Output file path (shorthand)")
+	flag.String("ext", "", "Comma-separated list of file extensions to include")
+	flag.Bool("exclude-hidden", true, "Exclude hidden files and directories")
+	flag.Bool("eh", true, "Exclude hidden files (shorthand)")
+	flag.Int64("max-size", 0, "Maximum file size in bytes (0 = unlimited)")
+	flag.Int64("min-size", 0, "Minimum file size in bytes")
+	flag.String("exclude", "", "Regex pattern to exclude files")
+	flag.String("include", "", "Regex pattern to include files")
+	var rules []string
+	flag.Var(&ruleListFlag{rules: &rules, prefix: "+ "}, "include-glob", "Glob of files to include, ** matches any depth (repeatable)")
+	flag.Var(&ruleListFlag{rules: &rules, prefix: "- "}, "exclude-glob", "Glob of files to exclude (repeatable)")
+	flag.Var(&ruleListFlag{rules: &rules, prefix: ". "}, "rules", "Load ordered +/- glob rules from a file (repeatable)")
+	flag.Bool("no-ignore", false, "Do not read .cotoignore files")
+	flag.Bool("gitignore", false, "Also honor .gitignore files")
+	flag.String("contains", "", "Only include files whose contents match this regex")
+	flag.String("not-contains", "", "Exclude files whose contents match this regex")
+	flag.Bool("exclude-generated", false, "Exclude generated, minified and vendored files")
+	flag.String("modified-after", "", "Only include files modified after a date or age (e.g. 2024-05-01, 7d)")
+	flag.String("modified-before", "", "Only include files modified before a date or age (e.g. 2024-05-01, 7d)")
+	flag.Int("max-depth", 0, "Maximum directory depth below each input root (0 = unlimited)")
+	flag.Int("min-depth", 0, "Minimum directory depth below each input root")
+	flag.Int("newest", 0, "Only include the N most recently modified files")
+	flag.Bool("follow-symlinks", false, "Follow symlinked directories (cycles are detected)")
+	flag.Bool("checksum", false, "Record a SHA-256 per file and a root hash for coto verify")
+	flag.String("sign", "", "Sign the bundle with an ed25519 private key (see coto keygen)")
+	flag.Bool("sig-file", false, "Write the signature to <output>.sig instead of embedding it")
+	flag.Bool("encrypt", false, "Encrypt the output with a passphrase (AES-256-GCM, scrypt)")
+	flag.String("passphrase-env", encryption.PassphraseEnv, "Environment variable holding the passphrase")
+	flag.Bool("dedupe", false, "Emit identical file contents once, listing other paths as aliases")
+	flag.Bool("dedupe-links", false, "Include files reachable through several paths only once")
+	flag.Int("max-lines", 0, "Truncate files longer than N lines (0 = unlimited)")
+	flag.String("truncate", "head", "Lines kept by -max-lines: head, tail or head+tail")
+	flag.String("prompt-header", "", "Write the instructions of a file before the files of the bundle")
+	flag.String("prompt-footer", "", "Write the text of a file after the files of the bundle")
+	flag.String("prompt", "", "Write this text, such as a question, at the end of the bundle")
+	flag.Bool("explain", false, "Explain why each path is included or excluded (implies -dry-run)")
 	outputFormat := flag.String("format", "text", "Output format: text, json, xml, markdown")
-	compress := flag.Bool("compress", false, "Compress output with gzip")
-	dryRun := flag.Bool("dry-run", false, "Show what would be processed without writing")
-	quiet := flag.Bool("quiet", false, "Suppress non-essential output")
-	verbose := flag.Bool("verbose", false, "Show detailed progress")
-	parallel := flag.Int("parallel", 1, "Number of files to process in parallel")
+	flag.Var(&compressFlag{}, "compress", "Compress output: gzip, zstd or xz (bare -compress means gzip)")
+	flag.Var(&codecFlag{}, "compression", "Compress output with this codec: gzip, zstd or xz")
+	flag.Int("compress-level", 0, "Compression level (0 = codec default)")
+	flag.Bool("dry-run", false, "Show what would be processed without writing")
+	flag.Bool("quiet", false, "Suppress non-essential output")
+	flag.Bool("verbose", false, "Show detailed progress")
+	flag.Int("parallel", 1, "Number of files to process in parallel")
+	timeout := flag.Duration("timeout", 0, "Stop after this long, e.g. 90s or 5m (0 = no limit)")
+	flag.Bool("keep-partial", false, "On Ctrl-C or timeout, write the files processed so far")
 	versionFlag := flag.Bool("version", false, "Show version information")
 	versionShort := flag.Bool("v", false, "Show version information (shorthand)")
-	configFile := flag.String("config", "", "Load configuration from JSON file")
-
-	// Parse flags early to check if any were provided
-	flag.Parse()
-
-	// Handle short flag overrides
-	if *inputShort != "" {
-		*inputDir = *inputShort
-	}
-	if *outputShort != "" {
-		*outputFile = *outputShort
-	}
-	if !*excludeShort {
-		*excludeHidden = false
+	configFile := flag.String("config", "", "Load configuration from a JSON, YAML or TOML file")
+	profile := flag.String("profile", "", "Apply a named profile from the configuration files (see coto profiles)")
+	logFormat := flag.String("log-format", runlog.FormatText, "Log format: text or json")
+	outputJSON := flag.Bool("output-json", false, "Log JSON events and a summary (same as -log-format json)")
+	logFile := flag.String("log-file", "", "Write the JSON log to a file instead of stderr")
+	var top *int
+	var showTokens *bool
+	switch mode {
+	case modeStats:
+		top = flag.Int("top", 10, "Number of largest files to list")
+		flag.Usage = printStatsHelp
+	case modeTree:
+		showTokens = flag.Bool("tokens", false, "Show estimated tokens per file and directory")
+		flag.Usage = printTreeHelp
+	case modeConfig:
+		flag.Usage = printConfigHelp
+	}
+
+	// Parse flags early to check if any were provided. Positional arguments
+	// may appear between flags and are additional input roots or globs.
+	positional, err := parseInterspersed(flag.CommandLine, args)
+	if err != nil {
+		os.Exit(runlog.ExitUsage)
 	}
-	if *versionShort {
-		*versionFlag = true
+	for _, path := range positional {
+		flag.Set("input", path)
 	}
 
-	if *versionFlag {
+	if *versionFlag || *versionShort {
 		fmt.Printf("coto v%s\n", version)
 		os.Exit(0)
 	}
 
-	// Check if no flags were provided and enter interactive mode
-	if !hasAnyFlagSet() && len(os.Args) == 1 {
-		fmt.Printf("%s Welcome to Coto v%s - Interactive Mode\n\n", cyan("→"), version)
-
-		// Prompt for input directory with validation
-		*inputDir = promptUserWithValidation("Enter input directory path", ".", validateDirectory)
-
-		// Prompt for output file with validation
-		*outputFile = promptUserWithValidation("Enter output file path", "combined.txt", validateFilePath)
-
-		// Prompt for file extensions with validation
-		extInput := promptUserWithValidation("Enter file extensions to include (comma-separated, e.g., .go,.js,.py)", "", validateExtensions)
-		if extInput != "" {
-			*extensions = extInput
-		}
-
-		// Prompt for output format
-		formats := []string{"text", "json", "xml", "markdown"}
-		*outputFormat = promptSelect("Select output format", formats, "text")
-
-		// Prompt for excluding hidden files
-		*excludeHidden = promptBool("Exclude hidden files and directories", true)
+	// Structured events and the final summary go to stderr or -log-file
+	if *outputJSON {
+		*logFormat = runlog.FormatJSON
+	}
+	log, err := runlog.Open(mode.String(), *logFormat, *logFile)
+	if err != nil {
+		fmt.Printf("%s %v\n", red("✗"), err)
+		os.Exit(runlog.ExitCode(err))
+	}
+	defer log.Close()
 
-		// Prompt for compression
-		*compress = promptBool("Compress output with gzip", false)
+	// Check if no flags were provided and enter interactive mode. Answers
+	// start from the configuration files and are set as flags, so they
+	// override them.
+	interactive := mode == modeCombine && !hasAnyFlagSet() && len(args) == 0
+	usePicker := interactive && picker.Available(os.Stdin, os.Stdout)
+	if interactive {
+		fmt.Printf("%s Welcome to Coto v%s - Interactive Mode\n\n", cyan("→"), version)
 
-		// Prompt for max file size
-		maxSizeStr := promptUser("Maximum file size in bytes (0 for unlimited)", "0")
-		if val, err := strconv.ParseInt(maxSizeStr, 10, 64); err == nil && val >= 0 {
-			*maxFileSize = val
-		} else {
-			*maxFileSize = 0
+		// Profiles answer the remaining questions unless they are adjusted
+		adjust := true
+		if name := promptProfile(); name != "" {
+			flag.Set("profile", name)
+			adjust = promptBool("Adjust the settings of the profile", false)
 		}
-
-		// Prompt for exclude pattern
-		excludePat := promptUser("Regex pattern to exclude files (optional)", "")
-		*excludePattern = excludePat
-
-		// Prompt for include pattern
-		includePat := promptUser("Regex pattern to include files (optional)", "")
-		*includePattern = includePat
-
-		// Prompt for parallel processing with validation
-		for {
-			parallelStr := promptUser("Number of files to process in parallel", "1")
-			if val, err := strconv.Atoi(parallelStr); err == nil && val > 0 {
-				*parallel = val
-				break
-			} else if err != nil || val <= 0 {
-				fmt.Printf("%s Parallel value must be a positive integer\n", red("✗"))
-				continue
-			}
+		if adjust {
+			promptCombineFlags(promptDefaults(*profile), usePicker)
 		}
-
-		// Prompt for verbose mode
-		*verbose = promptBool("Enable verbose output", false)
-
-		// Prompt for dry run
-		*dryRun = promptBool("Perform dry run (show what would be processed without writing)", false)
-
 		fmt.Println()
-		fmt.Printf("%s Starting processing with your selections...\n\n", green("✓"))
 	}
 
-	// Load config file if specified
+	// Merge the defaults, the user, project and -config files, COTO_*
+	// variables and the flags
+	layers, err := loadSettings(*configFile, *profile, inputs.values)
 	var config Config
-	if *configFile != "" {
-		cfg, err := loadConfig(*configFile)
-		if err != nil {
-			fmt.Printf("%s Error loading config: %v\n", red("✗"), err)
-			os.Exit(1)
-		}
-		config = cfg
-		// Override with command line flags if provided
-		if *inputDir != "." {
-			config.InputDir = *inputDir
-		}
-		if *outputFile != "combined.txt" {
-			config.OutputFile = *outputFile
-		}
-		if *extensions != "" {
-			config.Extensions = strings.Split(*extensions, ",")
-		}
-		// Check if the exclude-hidden flag was explicitly set
-		if isFlagSet("exclude-hidden") {
-			config.ExcludeHidden = *excludeHidden
-		}
-		if *excludePattern != "" {
-			config.ExcludePattern = *excludePattern
-		}
-		if *includePattern != "" {
-			config.IncludePattern = *includePattern
-		}
-		if *outputFormat != "text" {
-			config.OutputFormat = *outputFormat
-		}
-		if *compress {
-			config.Compress = *compress
-		}
-		if *parallel != 1 {
-			config.Parallel = *parallel
-		}
-		if *quiet {
-			config.Quiet = *quiet
-		}
-		if *verbose {
-			config.Verbose = *verbose
-		}
-		if *dryRun {
-			config.DryRun = *dryRun
-		}
-	} else {
-		config = Config{
-			InputDir:       *inputDir,
-			OutputFile:     *outputFile,
-			ExcludeHidden:  *excludeHidden,
-			MaxFileSize:    *maxFileSize,
-			MinFileSize:    *minFileSize,
-			ExcludePattern: *excludePattern,
-			IncludePattern: *includePattern,
-			OutputFormat:   *outputFormat,
-			Compress:       *compress,
-			Parallel:       *parallel,
-			Quiet:          *quiet,
-			Verbose:        *verbose,
-			DryRun:         *dryRun,
-		}
-		if *extensions != "" {
-			config.Extensions = strings.Split(*extensions, ",")
-		}
+	if err == nil {
+		err = layers.Decode(&config)
 	}
-
-	// Validate input directory exists
-	if err := validateDirectory(config.InputDir); err != nil {
-		fmt.Printf("%s %v\n", red("✗"), err)
-		os.Exit(1)
+	if err != nil {
+		fmt.Printf("%s Error loading config: %v\n", red("✗"), err)
+		exitWith(log, runlog.Usage(fmt.Errorf("error loading config: %w", err)), nil)
 	}
 
-	// Validate output file path
-	if err := validateFilePath(config.OutputFile); err != nil {
-		fmt.Printf("%s %v\n", red("✗"), err)
-		os.Exit(1)
+	if mode == modeConfig {
+		if err := layers.Fprint(os.Stdout); err != nil {
+			exitWith(log, err, nil)
+		}
+		return
 	}
 
-	// Validate extensions
-	if err := validateExtensions(strings.Join(config.Extensions, ",")); err != nil {
-		fmt.Printf("%s %v\n", red("✗"), err)
-		os.Exit(1)
+	// The report is the only output of stats and tree
+	if mode != modeCombine {
+		config.Quiet = true
 	}
 
-	startTime := time.Now()
-
-	// Validate patterns
-	var excludeRegex, includeRegex *regexp.Regexp
-	if *excludePattern != "" {
-		re, err := regexp.Compile(*excludePattern)
-		if err != nil {
-			fmt.Printf("%s Invalid exclude pattern: %v\n", red("✗"), err)
-			os.Exit(1)
-		}
-		excludeRegex = re
+	// Explaining filter decisions never writes output
+	if config.Explain {
+		config.DryRun = true
 	}
-	if *includePattern != "" {
-		re, err := regexp.Compile(*includePattern)
-		if err != nil {
-			fmt.Printf("%s Invalid include pattern: %v\n", red("✗"), err)
-			os.Exit(1)
-		}
-		includeRegex = re
+	// Only bundles are signed, encrypted and wrapped in a prompt
+	if mode != modeCombine {
+		config.SignKey = ""
+		config.Encrypt = false
+		config.PromptHeader, config.PromptFooter, config.Prompt = "", "", ""
 	}
 
-	if !*quiet {
-		fmt.Printf("%s Starting Coto v%s\n", cyan("→"), version)
-		fmt.Printf("%s Input directory: %s\n", cyan("→"), config.InputDir)
-		fmt.Printf("%s Output file: %s\n", cyan("→"), config.OutputFile)
-		if *dryRun {
-			fmt.Printf("%s DRY RUN MODE - No files will be written\n", yellow("⚠"))
+	// Validate inputs, output path, extensions, compression, patterns and the
+	// signing key so mistakes fail before any work is done
+	if err := config.Validate(); err != nil {
+		fmt.Printf("%s %v\n", red("✗"), err)
+		var optionErr *combine.OptionError
+		if errors.As(err, &optionErr) {
+			err = runlog.Usage(err)
 		}
+		exitWith(log, err, nil)
 	}
+	codec, _ := config.Codec()
+	outputPath := config.OutputPath()
 
-	// Collect file information
-	var fileInfos []FileInfo
-	var filePaths []string
-	var stats Stats
+	if config.SignKey != "" && !config.SignatureFile && strings.EqualFold(config.OutputFormat, "json") && !config.Quiet {
+		fmt.Printf("%s JSON bundles cannot embed a signature, writing %s\n",
+			yellow("⚠"), outputPath+signing.SignatureExtension)
+	}
 
-	// Walk directory to collect files
-	err := filepath.Walk(config.InputDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			if !*quiet {
-				fmt.Printf("%s Error accessing %s: %v\n", red("✗"), path, err)
-			}
-			return nil
+	// Ask for the passphrase before the walk so a typo does not waste a long run
+	if config.Encrypt && !config.DryRun {
+		if config.Passphrase, err = encryption.Passphrase(config.PassphraseEnv, true); err != nil {
+			fmt.Printf("%s %v\n", red("✗"), err)
+			exitWith(log, err, nil)
 		}
+	}
 
-		if info.IsDir() {
-			stats.Directories++
-			if config.ExcludeHidden && isHidden(info.Name()) {
-				return filepath.SkipDir
-			}
-			return nil
+	if !config.Quiet {
+		fmt.Printf("%s Starting Coto v%s\n", cyan("→"), version)
+		for _, root := range config.Roots() {
+			fmt.Printf("%s Input: %s\n", cyan("→"), root)
 		}
-
-		// Apply filters
-		if !shouldProcessFile(path, info, config, excludeRegex, includeRegex) {
-			return nil
+		if config.FilesFrom != "" {
+			fmt.Printf("%s Files from: %s\n", cyan("→"), config.FilesFrom)
 		}
-
-		filePaths = append(filePaths, path)
-		return nil
-	})
-
-	if err != nil {
-		fmt.Printf("%s Error walking directory: %v\n", red("✗"), err)
-		os.Exit(1)
-	}
-
-	if !*quiet {
-		fmt.Printf("%s Found %d files to process\n", cyan("→"), len(filePaths))
-	}
-
-	// Process files
-	if *parallel > 1 {
-		fileInfos = processFilesParallel(filePaths, config.InputDir, *parallel, *verbose, *quiet, &stats)
-	} else {
-		fileInfos = processFilesSequential(filePaths, config.InputDir, *verbose, *quiet, &stats)
-	}
-
-	stats.Duration = time.Since(startTime).Seconds()
-
-	// Generate output
-	if !*dryRun {
-		outputSize, err := writeOutput(fileInfos, config.OutputFile, *outputFormat, *compress, stats)
-		if err != nil {
-			fmt.Printf("%s Error writing output: %v\n", red("✗"), err)
-			os.Exit(1)
+		fmt.Printf("%s Output file: %s\n", cyan("→"), outputPath)
+		if config.DryRun {
+			fmt.Printf("%s DRY RUN MODE - No files will be written\n", yellow("⚠"))
 		}
-		stats.OutputSize = outputSize
 	}
 
-	// Print summary
-	printSummary(stats, *outputFormat, *compress, *dryRun)
+	// Ctrl-C, SIGTERM and -timeout cancel the walk, the readers and the writer
+	ctx, stop := interrupt.Context(*timeout)
+	defer stop()
 
-	if *dryRun {
-		fmt.Printf("\n%s Dry run completed. %d files would be processed.\n",
-			green("✓"), stats.FilesProcessed)
-	} else {
-		fmt.Printf("\n%s Processing completed successfully!\n", green("✓"))
+	// Walk input roots and file lists to collect files
+	config.OnEvent = log.Handler(printEvents(config.Explain, config.Verbose, config.Quiet))
+	collected, err := combine.Collect(ctx, config)
+	if interrupt.Canceled(err) {
+		fmt.Printf("\n%s %s while collecting files, nothing was written\n", yellow("⚠"), interrupt.Reason(ctx))
+		exitWith(log, ctx.Err(), nil)
 	}
-}
-
-func shouldProcessFile(path string, info os.FileInfo, config Config,
-	excludeRegex, includeRegex *regexp.Regexp) bool {
-
-	// Skip hidden files
-	if config.ExcludeHidden && isHidden(info.Name()) {
-		return false
+	if err != nil {
+		fmt.Printf("%s %v\n", red("✗"), err)
+		exitWith(log, err, nil)
 	}
 
-	// Check file size limits
-	if config.MaxFileSize > 0 && info.Size() > config.MaxFileSize {
-		return false
-	}
-	if config.MinFileSize > 0 && info.Size() < config.MinFileSize {
-		return false
+	if !config.Quiet {
+		fmt.Printf("%s Found %d files to process\n", cyan("→"), len(collected.Paths))
 	}
 
-	// Check extensions
-	if len(config.Extensions) > 0 {
-		ext := filepath.Ext(path)
-		found := false
-		for _, allowedExt := range config.Extensions {
-			if strings.EqualFold(ext, allowedExt) {
-				found = true
-				break
-			}
+	// Interactive runs pick the files on a terminal, preview the selection
+	// and may save the answers
+	if usePicker && len(collected.Paths) > 0 {
+		if err := pickFiles(collected); errors.Is(err, picker.ErrCanceled) {
+			fmt.Printf("%s Canceled, nothing was written\n", yellow("⚠"))
+			exitWith(log, nil, newSummary(collected.Stats, config, ""))
+		} else if err != nil {
+			fmt.Printf("%s %v\n", red("✗"), err)
+			exitWith(log, err, nil)
 		}
-		if !found {
-			return false
+	}
+	// Interactive runs preview the selection and may save the answers
+	if interactive {
+		if !confirmInteractive(layers, collected, config) {
+			fmt.Printf("%s Canceled, nothing was written\n", yellow("⚠"))
+			exitWith(log, nil, newSummary(collected.Stats, config, ""))
 		}
+		fmt.Printf("%s Starting processing with your selections...\n\n", green("✓"))
 	}
 
-	// Check regex patterns
-	relPath, _ := filepath.Rel(config.InputDir, path)
-	if excludeRegex != nil && excludeRegex.MatchString(relPath) {
-		return false
-	}
-	if includeRegex != nil && !includeRegex.MatchString(relPath) {
-		return false
+	if config.Explain {
+		fmt.Printf("\n%s Explain mode: %d files would be included\n", green("✓"), len(collected.Paths))
+		exitWith(log, runlog.Partial(collected.Stats.Errors), newSummary(collected.Stats, config, ""))
 	}
 
-	return true
-}
-
-func processFilesSequential(paths []string, baseDir string, verbose, quiet bool, stats *Stats) []FileInfo {
-	var fileInfos []FileInfo
-
-	for i, path := range paths {
-		if verbose && !quiet {
-			fmt.Printf("%s Processing file %d/%d: %s\n",
-				cyan("↳"), i+1, len(paths), getRelativePath(path, baseDir))
-		} else if !quiet && len(paths) > 10 && (i+1)%int((len(paths)/10)+1) == 0 {
-			// Show progress for larger operations
-			progress := float64(i+1) / float64(len(paths)) * 100
-			fmt.Printf("%s Progress: %d/%d files (%.1f%%)\n",
-				cyan("→"), i+1, len(paths), progress)
-		}
-
-		info, err := processSingleFile(path, baseDir)
-		if err != nil {
-			if !quiet {
-				fmt.Printf("%s Error processing %s: %v\n", red("✗"), path, err)
+	if mode == modeStats {
+		// Stats always count whole files
+		collected.Ranges = nil
+		infos, err := combine.Load(ctx, collected, Config{Parallel: config.Parallel})
+		if interrupt.Canceled(err) {
+			if !log.Stderr() {
+				fmt.Fprintf(os.Stderr, "\n%s %s after reading %d of %d files\n", yellow("⚠"),
+					interrupt.Reason(ctx), len(infos), len(collected.Paths))
 			}
-			continue
+			exitWith(log, ctx.Err(), newSummary(collected.Stats, config, ""))
 		}
-
-		fileInfos = append(fileInfos, info)
-		stats.FilesProcessed++
-		stats.TotalBytes += info.Size
-
-		if verbose && !quiet && (i+1)%10 == 0 {
-			fmt.Printf("%s Processed %d/%d files\n", cyan("→"), i+1, len(paths))
+		if err == nil {
+			err = printStats(infos, *top, *outputFormat)
 		}
-	}
-
-	return fileInfos
-}
-
-func processFilesParallel(paths []string, baseDir string, workers int, verbose, quiet bool, stats *Stats) []FileInfo {
-	var wg sync.WaitGroup
-	fileChan := make(chan string, len(paths))
-	resultChan := make(chan FileInfo, len(paths))
-	errorChan := make(chan error, len(paths))
-
-	var processed int32
-	totalFiles := len(paths)
-
-	// Start worker goroutines
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for path := range fileChan {
-				info, err := processSingleFile(path, baseDir)
-				if err != nil {
-					errorChan <- fmt.Errorf("%s: %v", path, err)
-					continue
-				}
-				resultChan <- info
-
-				// Update progress
-				curr := atomic.AddInt32(&processed, 1)
-				if verbose && !quiet && curr%10 == 0 {
-					fmt.Printf("%s Worker %d: Processed %d/%d files\n",
-						cyan("→"), workerID, curr, totalFiles)
-				} else if !verbose && !quiet && totalFiles > 10 && int(curr)%((totalFiles/10)+1) == 0 {
-					// Show overall progress for larger operations
-					progress := float64(curr) / float64(totalFiles) * 100
-					fmt.Printf("%s Overall progress: %d/%d files (%.1f%%)\n",
-						cyan("→"), curr, totalFiles, progress)
-				}
-			}
-		}(i)
-	}
-
-	// Send files to workers
-	for _, path := range paths {
-		fileChan <- path
-	}
-	close(fileChan)
-
-	// Wait for workers to finish
-	wg.Wait()
-	close(resultChan)
-	close(errorChan)
-
-	// Collect results
-	var fileInfos []FileInfo
-	for info := range resultChan {
-		fileInfos = append(fileInfos, info)
-		stats.FilesProcessed++
-		stats.TotalBytes += info.Size
-	}
-
-	// Report errors
-	if !quiet {
-		for err := range errorChan {
-			fmt.Printf("%s %v\n", red("✗"), err)
+		if err != nil && !log.Stderr() {
+			fmt.Fprintf(os.Stderr, "%s %v\n", red("✗"), err)
 		}
+		if err == nil {
+			err = runlog.Partial(collected.Stats.Errors)
+		}
+		exitWith(log, err, newSummary(collected.Stats, config, ""))
 	}
 
-	return fileInfos
-}
-
-func processSingleFile(path, baseDir string) (FileInfo, error) {
-	info := FileInfo{
-		Path:         path,
-		RelativePath: getRelativePath(path, baseDir),
-	}
-
-	// Get file stats
-	fileInfo, err := os.Stat(path)
-	if err != nil {
-		return info, err
+	if mode == modeTree {
+		root, err := buildTree(collected.Paths, collected.BaseDir)
+		if err == nil {
+			err = writeTree(os.Stdout, root, *outputFormat, *showTokens)
+		}
+		if err != nil && !log.Stderr() {
+			fmt.Fprintf(os.Stderr, "%s %v\n", red("✗"), err)
+		}
+		if err == nil {
+			err = runlog.Partial(collected.Stats.Errors)
+		}
+		exitWith(log, err, newSummary(collected.Stats, config, ""))
 	}
 
-	info.Size = fileInfo.Size()
-	info.Modified = fileInfo.ModTime().Format("2006-01-02 15:04:05")
-
-	// Read file content
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return info, err
+	// Read the files and write the bundle
+	result, err := combine.Build(ctx, collected, config)
+	if err != nil && !interrupt.Canceled(err) {
+		fmt.Printf("%s %v\n", red("✗"), err)
+		exitWith(log, err, nil)
 	}
-
-	info.Content = string(content)
-	return info, nil
-}
-
-func writeOutput(fileInfos []FileInfo, outputPath, format string, compress bool, stats Stats) (int64, error) {
-	var writer io.Writer
-
-	// Create output file
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return 0, err
+	stats := result.Stats
+	if config.Verbose && !config.Quiet && stats.DuplicateFiles > 0 {
+		fmt.Printf("%s %d duplicate files folded into aliases\n", cyan("→"), stats.DuplicateFiles)
 	}
-	defer file.Close()
-
-	writer = file
 
-	// Add compression if requested
-	if compress {
-		gzWriter := gzip.NewWriter(file)
-		defer gzWriter.Close()
-		writer = gzWriter
-		outputPath += ".gz"
-	}
+	// Print summary
+	printSummary(stats, config.OutputFormat, codec, config.DryRun || result.OutputFile == "")
 
-	// Write based on format
-	switch strings.ToLower(format) {
-	case "json":
-		return writeJSONOutput(fileInfos, writer, stats)
-	case "xml":
-		return writeXMLOutput(fileInfos, writer, stats)
-	case "markdown", "md":
-		return writeMarkdownOutput(fileInfos, writer, stats)
-	default: // text
-		return writeTextOutput(fileInfos, writer, stats)
+	summary := newSummary(stats, config, result.OutputFile)
+	if result.Partial {
+		printCanceled(ctx, result, len(collected.Paths), config.DryRun)
+		summary.Partial = true
+		exitWith(log, ctx.Err(), summary)
 	}
-}
-
-func writeTextOutput(fileInfos []FileInfo, writer io.Writer, stats Stats) (int64, error) {
-	totalBytes := int64(0)
-	bufWriter := bufio.NewWriter(writer)
 
-	header := fmt.Sprintf("Coto Output\n")
-	header += fmt.Sprintf("Generated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	header += fmt.Sprintf("Files: %d | Directories: %d | Total Size: %s\n\n",
-		stats.FilesProcessed, stats.Directories, formatBytes(stats.TotalBytes))
-
-	n, _ := bufWriter.WriteString(header)
-	totalBytes += int64(n)
-
-	for _, info := range fileInfos {
-		section := fmt.Sprintf("\n%s\n%s\n", strings.Repeat("=", 80), info.RelativePath)
-		section += fmt.Sprintf("Size: %s | Modified: %s\n", formatBytes(info.Size), info.Modified)
-		section += fmt.Sprintf("%s\n", strings.Repeat("-", 80))
-		section += info.Content + "\n"
-		section += fmt.Sprintf("%s\n", strings.Repeat("=", 80))
-
-		n, _ := bufWriter.WriteString(section)
-		totalBytes += int64(n)
+	switch {
+	case stats.Errors > 0:
+		fmt.Printf("\n%s Finished with %d files that could not be read\n", yellow("⚠"), stats.Errors)
+	case config.DryRun:
+		fmt.Printf("\n%s Dry run completed. %d files would be processed.\n",
+			green("✓"), stats.FilesProcessed)
+	default:
+		fmt.Printf("\n%s Processing completed successfully!\n", green("✓"))
 	}
-
-	footer := fmt.Sprintf("\n\n=== SUMMARY ===\n")
-	footer += fmt.Sprintf("Files processed: %d\n", stats.FilesProcessed)
-	footer += fmt.Sprintf("Directories scanned: %d\n", stats.Directories)
-	footer += fmt.Sprintf("Total input size: %s\n", formatBytes(stats.TotalBytes))
-	footer += fmt.Sprintf("Output size: %s\n", formatBytes(totalBytes))
-	footer += fmt.Sprintf("Processing time: %.2f seconds\n", stats.Duration)
-
-	n, _ = bufWriter.WriteString(footer)
-	totalBytes += int64(n)
-
-	bufWriter.Flush()
-	return totalBytes, nil
+	exitWith(log, runlog.Partial(stats.Errors), summary)
 }
 
-func writeJSONOutput(fileInfos []FileInfo, writer io.Writer, stats Stats) (int64, error) {
-	output := map[string]interface{}{
-		"metadata": map[string]interface{}{
-			"generated":     time.Now().Format(time.RFC3339),
-			"version":       version,
-			"files_count":   stats.FilesProcessed,
-			"directories":   stats.Directories,
-			"total_size":    stats.TotalBytes,
-			"duration_secs": stats.Duration,
-		},
-		"files": fileInfos,
-	}
-
-	encoder := json.NewEncoder(writer)
-	encoder.SetIndent("", "  ")
-	err := encoder.Encode(output)
-	if err != nil {
-		return 0, err
-	}
-
-	// Estimate size (not exact but good enough)
-	data, _ := json.Marshal(output)
-	return int64(len(data)), nil
+// summary is the final record of a combine, stats or tree run in the JSON log
+type summary struct {
+	combine.Stats
+	Output  string `json:"output,omitempty"`
+	Format  string `json:"format,omitempty"`
+	DryRun  bool   `json:"dry_run,omitempty"`
+	Partial bool   `json:"partial,omitempty"`
 }
 
-func writeXMLOutput(fileInfos []FileInfo, writer io.Writer, stats Stats) (int64, error) {
-	type XMLOutput struct {
-		XMLName   xml.Name `xml:"filecombiner_output"`
-		Version   string   `xml:"version,attr"`
-		Generated string   `xml:"generated,attr"`
-		Metadata  struct {
-			Files       int     `xml:"files"`
-			Directories int     `xml:"directories"`
-			TotalSize   int64   `xml:"total_size"`
-			Duration    float64 `xml:"duration_seconds"`
-		} `xml:"metadata"`
-		Files []FileInfo `xml:"file"`
-	}
-
-	output := XMLOutput{
-		Version:   version,
-		Generated: time.Now().Format(time.RFC3339),
-	}
-	output.Metadata.Files = stats.FilesProcessed
-	output.Metadata.Directories = stats.Directories
-	output.Metadata.TotalSize = stats.TotalBytes
-	output.Metadata.Duration = stats.Duration
-	output.Files = fileInfos
-
-	encoder := xml.NewEncoder(writer)
-	encoder.Indent("", "  ")
-
-	// Write XML header
-	writer.Write([]byte(xml.Header))
-
-	err := encoder.Encode(output)
-	if err != nil {
-		return 0, err
-	}
-
-	// Estimate size
-	data, _ := xml.MarshalIndent(output, "", "  ")
-	return int64(len(data) + len(xml.Header)), nil
+func newSummary(stats combine.Stats, config Config, output string) *summary {
+	return &summary{Stats: stats, Output: output, Format: config.OutputFormat, DryRun: config.DryRun}
 }
 
-func writeMarkdownOutput(fileInfos []FileInfo, writer io.Writer, stats Stats) (int64, error) {
-	totalBytes := int64(0)
-	bufWriter := bufio.NewWriter(writer)
-
-	header := fmt.Sprintf("# Coto Output\n\n")
-	header += fmt.Sprintf("**Generated**: %s  \n", time.Now().Format("2006-01-02 15:04:05"))
-	header += fmt.Sprintf("**Files**: %d | **Directories**: %d | **Total Size**: %s  \n\n",
-		stats.FilesProcessed, stats.Directories, formatBytes(stats.TotalBytes))
-
-	n, _ := bufWriter.WriteString(header)
-	totalBytes += int64(n)
-
-	for i, info := range fileInfos {
-		section := fmt.Sprintf("## File %d: `%s`\n\n", i+1, info.RelativePath)
-		section += fmt.Sprintf("**Size**: %s  \n", formatBytes(info.Size))
-		section += fmt.Sprintf("**Modified**: %s  \n\n", info.Modified)
-		section += "### Content\n```\n"
-		section += info.Content + "\n```\n\n"
-		section += "---\n\n"
-
-		n, _ := bufWriter.WriteString(section)
-		totalBytes += int64(n)
-	}
-
-	footer := fmt.Sprintf("## Summary\n\n")
-	footer += fmt.Sprintf("- **Files processed**: %d\n", stats.FilesProcessed)
-	footer += fmt.Sprintf("- **Directories scanned**: %d\n", stats.Directories)
-	footer += fmt.Sprintf("- **Total input size**: %s\n", formatBytes(stats.TotalBytes))
-	footer += fmt.Sprintf("- **Processing time**: %.2f seconds\n", stats.Duration)
-
-	n, _ = bufWriter.WriteString(footer)
-	totalBytes += int64(n)
-
-	bufWriter.Flush()
-	return totalBytes, nil
+// exitWith writes the summary to the JSON log and exits with the code of err
+func exitWith(log *runlog.Logger, err error, stats any) {
+	log.Finish(err, stats)
+	log.Close()
+	os.Exit(runlog.ExitCode(err))
 }
 
-func printSummary(stats Stats, format string, compress, dryRun bool) {
+func printSummary(stats combine.Stats, format string, codec compression.Codec, dryRun bool) {
 	fmt.Printf("\n%s %s\n", cyan("┌"), strings.Repeat("─", 50))
 	fmt.Printf("%s Processing Summary\n", cyan("│"))
 	fmt.Printf("%s %s\n", cyan("├"), strings.Repeat("─", 50))
 	fmt.Printf("%s Files processed:     %s\n", cyan("│"), green(strconv.Itoa(stats.FilesProcessed)))
 	fmt.Printf("%s Directories scanned: %s\n", cyan("│"), green(strconv.Itoa(stats.Directories)))
-	fmt.Printf("%s Total size:          %s\n", cyan("│"), green(formatBytes(stats.TotalBytes)))
+	fmt.Printf("%s Total size:          %s\n", cyan("│"), green(combine.FormatBytes(stats.TotalBytes)))
+	if stats.BrokenLinks > 0 {
+		fmt.Printf("%s Broken links:        %s\n", cyan("│"), yellow(strconv.Itoa(stats.BrokenLinks)))
+	}
+	if stats.SymlinkCycles > 0 {
+		fmt.Printf("%s Symlink cycles:      %s\n", cyan("│"), yellow(strconv.Itoa(stats.SymlinkCycles)))
+	}
+	if stats.DuplicateFiles > 0 {
+		fmt.Printf("%s Duplicates:          %s (%s saved)\n", cyan("│"),
+			green(strconv.Itoa(stats.DuplicateFiles)), green(combine.FormatBytes(stats.BytesSaved)))
+	}
 	fmt.Printf("%s Processing time:     %.2f seconds\n", cyan("│"), stats.Duration)
 
 	if !dryRun {
 		fmt.Printf("%s Output format:       %s\n", cyan("│"), green(format))
-		if compress {
-			fmt.Printf("%s Compression:         %s\n", cyan("│"), green("gzip"))
+		if codec != compression.None {
+			fmt.Printf("%s Compression:         %s\n", cyan("│"), green(codec.String()))
 		}
-		fmt.Printf("%s Output size:         %s\n", cyan("│"), green(formatBytes(stats.OutputSize)))
+		fmt.Printf("%s Output size:         %s\n", cyan("│"), green(combine.FormatBytes(stats.OutputSize)))
 		if stats.OutputSize > 0 {
 			ratio := float64(stats.OutputSize) / float64(stats.TotalBytes) * 100
 			fmt.Printf("%s Compression ratio:   %.1f%%\n", cyan("│"), ratio)
@@ -918,46 +644,6 @@ func printSummary(stats Stats, format string, compress, dryRun bool) {
 	fmt.Printf("%s %s\n", cyan("└"), strings.Repeat("─", 50))
 }
 
-func loadConfig(filename string) (Config, error) {
-	var config Config
-
-	file, err := os.Open(filename)
-	if err != nil {
-		return config, err
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&config)
-	return config, err
-}
-
-func getRelativePath(path, baseDir string) string {
-	relPath, err := filepath.Rel(baseDir, path)
-	if err != nil {
-		return path
-	}
-	return relPath
-}
-
-func isHidden(name string) bool {
-	return strings.HasPrefix(name, ".") ||
-		(strings.HasPrefix(name, "~") && len(name) > 1)
-}
-
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
-
 // Helper function to check if a flag was explicitly set
 func isFlagSet(name string) bool {
 	found := false
@@ -973,10 +659,12 @@ func isFlagSet(name string) bool {
 func init() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "%s Coto v%s - Combine files recursively\n\n", cyan("📁"), version)
-		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] [path|glob ...]\n\n", os.Args[0])
 
 		fmt.Fprintf(os.Stderr, "%s Basic Options:\n", cyan("📋"))
-		fmt.Fprintf(os.Stderr, "  -i, -input string        Input directory path (default \".\")\n")
+		fmt.Fprintf(os.Stderr, "  -i, -input string        Input directory, file or glob, repeatable (default \".\")\n")
+		fmt.Fprintf(os.Stderr, "  -files-from string       Read paths to include from a file (\"-\" for stdin)\n")
+		fmt.Fprintf(os.Stderr, "  -0                       Paths in -files-from are NUL-separated\n")
 		fmt.Fprintf(os.Stderr, "  -o, -output string       Output file path (default \"combined.txt\")\n")
 		fmt.Fprintf(os.Stderr, "  -ext string              Comma-separated list of file extensions\n")
 		fmt.Fprintf(os.Stderr, "  -eh, -exclude-hidden     Exclude hidden files (default true)\n")
@@ -986,30 +674,73 @@ func init() {
 		fmt.Fprintf(os.Stderr, "  -min-size int            Minimum file size in bytes\n")
 		fmt.Fprintf(os.Stderr, "  -include string          Regex pattern to include files\n")
 		fmt.Fprintf(os.Stderr, "  -exclude string          Regex pattern to exclude files\n")
+		fmt.Fprintf(os.Stderr, "  -include-glob string     Glob to include, ** matches any depth (repeatable)\n")
+		fmt.Fprintf(os.Stderr, "  -exclude-glob string     Glob to exclude (repeatable, order is kept)\n")
+		fmt.Fprintf(os.Stderr, "  -rules string            Load ordered +/- glob rules from a file\n")
+		fmt.Fprintf(os.Stderr, "  -no-ignore               Do not read .cotoignore files\n")
+		fmt.Fprintf(os.Stderr, "  -gitignore               Also honor .gitignore files\n")
+		fmt.Fprintf(os.Stderr, "  -contains string         Only include files whose contents match this regex\n")
+		fmt.Fprintf(os.Stderr, "  -not-contains string     Exclude files whose contents match this regex\n")
+		fmt.Fprintf(os.Stderr, "  -exclude-generated       Exclude generated, minified and vendored files\n")
+		fmt.Fprintf(os.Stderr, "  -modified-after string   Only files modified after a date or age (2024-05-01, 7d)\n")
+		fmt.Fprintf(os.Stderr, "  -modified-before string  Only files modified before a date or age\n")
+		fmt.Fprintf(os.Stderr, "  -max-depth int           Maximum depth below each input root (0 = unlimited)\n")
+		fmt.Fprintf(os.Stderr, "  -min-depth int           Minimum depth below each input root\n")
+		fmt.Fprintf(os.Stderr, "  -newest int              Only include the N most recently modified files\n")
+		fmt.Fprintf(os.Stderr, "  -checksum                Record SHA-256 per file and a root hash (coto verify)\n")
+		fmt.Fprintf(os.Stderr, "  -sign string             Sign the bundle with an ed25519 key (coto keygen)\n")
+		fmt.Fprintf(os.Stderr, "  -sig-file                Write the signature to <output>.sig instead of embedding it\n")
+		fmt.Fprintf(os.Stderr, "  -encrypt                 Encrypt the output with a passphrase (adds .enc)\n")
+		fmt.Fprintf(os.Stderr, "  -passphrase-env string   Variable holding the passphrase (default \"COTO_PASSPHRASE\")\n")
+		fmt.Fprintf(os.Stderr, "  -dedupe                  Emit identical contents once, other paths as aliases\n")
+		fmt.Fprintf(os.Stderr, "  -follow-symlinks         Follow symlinked directories (cycles are detected)\n")
+		fmt.Fprintf(os.Stderr, "  -dedupe-links            Include files reachable through several paths once\n")
+		fmt.Fprintf(os.Stderr, "  -explain                 Explain why each path is included or excluded\n")
 
 		fmt.Fprintf(os.Stderr, "\n%s Output Options:\n", cyan("📄"))
+		fmt.Fprintf(os.Stderr, "  -max-lines int           Truncate files longer than N lines (0 = unlimited)\n")
+		fmt.Fprintf(os.Stderr, "  -truncate string         Lines kept by -max-lines: head, tail, head+tail (default \"head\")\n")
+		fmt.Fprintf(os.Stderr, "  -prompt-header string    Write the instructions of a file before the files\n")
+		fmt.Fprintf(os.Stderr, "  -prompt-footer string    Write the text of a file after the files\n")
+		fmt.Fprintf(os.Stderr, "  -prompt string           Write this text, such as a question, at the end\n")
 		fmt.Fprintf(os.Stderr, "  -format string           Output format: text, json, xml, markdown (default \"text\")\n")
-		fmt.Fprintf(os.Stderr, "  -compress                Compress output with gzip\n")
-		fmt.Fprintf(os.Stderr, "  -config string           Load configuration from JSON file\n")
+		fmt.Fprintf(os.Stderr, "  -compress[=codec]        Compress output: gzip (default), zstd, xz\n")
+		fmt.Fprintf(os.Stderr, "  -compression codec       Compress output with gzip, zstd or xz\n")
+		fmt.Fprintf(os.Stderr, "  -compress-level int      Compression level (0 = codec default)\n")
+		fmt.Fprintf(os.Stderr, "  -config string           Load configuration from a JSON, YAML or TOML file\n")
+		fmt.Fprintf(os.Stderr, "  -profile string          Apply a named profile from the configuration files\n")
 
 		fmt.Fprintf(os.Stderr, "\n%s Performance Options:\n", cyan("⚡"))
 		fmt.Fprintf(os.Stderr, "  -parallel int            Number of files to process in parallel (default 1)\n")
+		fmt.Fprintf(os.Stderr, "  -timeout duration        Stop after this long, e.g. 90s or 5m (0 = no limit)\n")
+		fmt.Fprintf(os.Stderr, "  -keep-partial            On Ctrl-C or timeout, write the files processed so far\n")
 
 		fmt.Fprintf(os.Stderr, "\n%s Mode Options:\n", cyan("🎯"))
 		fmt.Fprintf(os.Stderr, "  -dry-run                 Show what would be processed without writing\n")
 		fmt.Fprintf(os.Stderr, "  -quiet                   Suppress non-essential output\n")
 		fmt.Fprintf(os.Stderr, "  -verbose                 Show detailed progress\n")
 
+		fmt.Fprintf(os.Stderr, "\n%s Logging Options:\n", cyan("📝"))
+		fmt.Fprintf(os.Stderr, "  -log-format string       Log format: text or json (default \"text\")\n")
+		fmt.Fprintf(os.Stderr, "  -output-json             Same as -log-format json\n")
+		fmt.Fprintf(os.Stderr, "  -log-file string         Write the JSON log to a file instead of stderr\n")
+
 		fmt.Fprintf(os.Stderr, "\n%s Information Options:\n", cyan("ℹ️"))
 		fmt.Fprintf(os.Stderr, "  -v, -version             Show version information\n")
 		fmt.Fprintf(os.Stderr, "  -h, -help                Show this help message\n")
 
 		fmt.Fprintf(os.Stderr, "\n%s Examples:\n", cyan("🚀"))
 		fmt.Fprintf(os.Stderr, "  %s -i ./src -o output.txt\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -i ./api -i ./web/src 'docs/**/*.md'\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  git ls-files -z | %s -files-from - -0\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -max-lines 300 -truncate head+tail cmd/main/main.go:120-200 ./docs\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -prompt-header review.md -prompt \"Where could {{project}} panic?\"\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -ext .go,.txt -format json -compress\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -format markdown -compress=zstd -compress-level 19\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -max-size 1000000 -parallel 4 -verbose\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -exclude \"\\.git|node_modules\" -dry-run\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -config config.json\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -profile backend -o backend.txt\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -v\n", os.Args[0])
 	}
 }
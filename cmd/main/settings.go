@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/bhangun/coto/cmd/extract"
+	"github.com/bhangun/coto/cmd/rename"
+	"github.com/bhangun/coto/pkg/runlog"
+	"github.com/bhangun/coto/pkg/settings"
+)
+
+// combineBindings ties the combine flags to the keys of the configuration
+// files. When several flags set a key the first one provides the default.
+var combineBindings = []settings.Binding{
+	{Flag: "input", Key: "input_dirs"},
+	{Flag: "i", Key: "input_dirs"},
+	{Flag: "files-from", Key: "files_from"},
+	{Flag: "0", Key: "null_separated"},
+	{Flag: "output", Key: "output_file"},
+	{Flag: "o", Key: "output_file"},
+	{Flag: "ext", Key: "extensions"},
+	{Flag: "exclude-hidden", Key: "exclude_hidden"},
+	{Flag: "eh", Key: "exclude_hidden"},
+	{Flag: "max-size", Key: "max_file_size"},
+	{Flag: "min-size", Key: "min_file_size"},
+	{Flag: "exclude", Key: "exclude_pattern"},
+	{Flag: "include", Key: "include_pattern"},
+	{Flag: "include-glob", Key: "rules"},
+	{Flag: "exclude-glob", Key: "rules"},
+	{Flag: "rules", Key: "rules"},
+	{Flag: "no-ignore", Key: "no_ignore"},
+	{Flag: "gitignore", Key: "gitignore"},
+	{Flag: "contains", Key: "contains"},
+	{Flag: "not-contains", Key: "not_contains"},
+	{Flag: "exclude-generated", Key: "exclude_generated"},
+	{Flag: "modified-after", Key: "modified_after"},
+	{Flag: "modified-before", Key: "modified_before"},
+	{Flag: "max-depth", Key: "max_depth"},
+	{Flag: "min-depth", Key: "min_depth"},
+	{Flag: "newest", Key: "newest"},
+	{Flag: "follow-symlinks", Key: "follow_symlinks"},
+	{Flag: "dedupe-links", Key: "dedupe_links"},
+	{Flag: "dedupe", Key: "dedupe"},
+	{Flag: "checksum", Key: "checksum"},
+	{Flag: "sign", Key: "sign_key"},
+	{Flag: "sig-file", Key: "signature_file"},
+	{Flag: "encrypt", Key: "encrypt"},
+	{Flag: "passphrase-env", Key: "passphrase_env"},
+	{Flag: "max-lines", Key: "max_lines"},
+	{Flag: "truncate", Key: "truncate"},
+	{Flag: "prompt-header", Key: "prompt_header"},
+	{Flag: "prompt-footer", Key: "prompt_footer"},
+	{Flag: "prompt", Key: "prompt"},
+	{Flag: "explain", Key: "explain"},
+	{Flag: "format", Key: "output_format"},
+	{Flag: "compress", Key: "compression"},
+	{Flag: "compression", Key: "compression"},
+	{Flag: "compress-level", Key: "compression_level"},
+	{Flag: "parallel", Key: "parallel"},
+	{Flag: "quiet", Key: "quiet"},
+	{Flag: "verbose", Key: "verbose"},
+	{Flag: "dry-run", Key: "dry_run"},
+	{Flag: "keep-partial", Key: "keep_partial"},
+}
+
+// loadSettings merges the configuration layers of the combine flags. The
+// project file is looked for from the first input root upward.
+func loadSettings(configFile, profile string, inputs []string) (*settings.Settings, error) {
+	loader := settings.Loader{
+		Schema:  settings.StructSchema(Config{}),
+		Dir:     projectDir(inputs),
+		File:    configFile,
+		Profile: profile,
+	}
+	return loader.Load(flag.CommandLine, combineBindings)
+}
+
+// projectDir returns the directory of the first input root that exists, or
+// the working directory
+func projectDir(inputs []string) string {
+	if len(inputs) == 0 {
+		return "."
+	}
+	path := inputs[0]
+	for path != "." && path != filepath.Dir(path) {
+		if info, err := os.Stat(path); err == nil {
+			if info.IsDir() {
+				return path
+			}
+			return filepath.Dir(path)
+		}
+		path = filepath.Dir(path)
+	}
+	return path
+}
+
+// runConfigCommand shows the merged configuration of a command and where
+// each value came from
+func runConfigCommand(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		printConfigHelp()
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help" || args[0] == "help") {
+			return
+		}
+		os.Exit(runlog.ExitUsage)
+	}
+	args = args[1:]
+
+	var err error
+	switch {
+	case len(args) > 0 && args[0] == "extract":
+		err = extract.NewExtractCommand().ShowConfig(args[1:])
+	case len(args) > 0 && args[0] == "rename":
+		err = rename.NewRenameCommand().ShowConfig(args[1:])
+	default:
+		if len(args) > 0 && args[0] == "combine" {
+			args = args[1:]
+		}
+		runCombineCommand(args, modeConfig)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(runlog.ExitCode(err))
+	}
+}
+
+func printConfigHelp() {
+	fmt.Fprintf(os.Stderr, "%s Coto Config v%s - Show the merged configuration\n\n", cyan("⚙️"), version)
+	fmt.Fprintf(os.Stderr, "Usage: coto config show [combine|extract|rename] [options]\n\n")
+
+	fmt.Fprintf(os.Stderr, "Every value is listed with the layer it came from. From lowest to highest\n")
+	fmt.Fprintf(os.Stderr, "precedence the layers are:\n")
+	fmt.Fprintf(os.Stderr, "  default   built-in defaults\n")
+	fmt.Fprintf(os.Stderr, "  user      ~/.config/coto/config (JSON, YAML or TOML)\n")
+	fmt.Fprintf(os.Stderr, "  project   nearest .coto.json, .coto.yaml or .coto.toml above the input\n")
+	fmt.Fprintf(os.Stderr, "  file      the file given with -config\n")
+	fmt.Fprintf(os.Stderr, "  profile   the profile given with -profile and the profiles it extends\n")
+	fmt.Fprintf(os.Stderr, "  env       COTO_<KEY>, COTO_EXTRACT_<KEY> and COTO_RENAME_<KEY>\n")
+	fmt.Fprintf(os.Stderr, "  flag      command line flags\n")
+	fmt.Fprintf(os.Stderr, "\nThe options of the command are accepted and shown as flag values.\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Examples:\n", cyan("🚀"))
+	fmt.Fprintf(os.Stderr, "  coto config show\n")
+	fmt.Fprintf(os.Stderr, "  coto config show -i ./src -ext .go\n")
+	fmt.Fprintf(os.Stderr, "  COTO_PARALLEL=8 coto config show\n")
+	fmt.Fprintf(os.Stderr, "  coto config show extract\n")
+}
+
+// runProfilesCommand lists the profiles of the user, project and -config
+// files
+func runProfilesCommand(args []string) {
+	fs := flag.NewFlagSet("profiles", flag.ContinueOnError)
+	configFile := fs.String("config", "", "Also read profiles from a JSON, YAML or TOML file")
+	fs.Usage = printProfilesHelp
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		os.Exit(runlog.ExitUsage)
+	}
+
+	loader := settings.Loader{
+		Schema: settings.StructSchema(Config{}),
+		Dir:    projectDir(fs.Args()),
+		File:   *configFile,
+	}
+	profiles, err := loader.Profiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(runlog.ExitUsage)
+	}
+	if len(profiles) == 0 {
+		fmt.Printf("%s No profiles found. Define them under \"%s\" in ~/.config/coto/config or a .coto file.\n",
+			yellow("⚠"), settings.ProfilesKey)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tEXTENDS\tSOURCE\tDESCRIPTION")
+	for _, p := range profiles {
+		extends := p.Extends
+		if extends == "" {
+			extends = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s (%s)\t%s\n", p.Name, extends, p.Source, p.Origin, p.Description)
+	}
+	tw.Flush()
+}
+
+func printProfilesHelp() {
+	fmt.Fprintf(os.Stderr, "%s Coto Profiles v%s - List the configured profiles\n\n", cyan("📚"), version)
+	fmt.Fprintf(os.Stderr, "Usage: coto profiles [options] [dir]\n\n")
+
+	fmt.Fprintf(os.Stderr, "Profiles are read from the user file, the nearest project file above dir\n")
+	fmt.Fprintf(os.Stderr, "(default: current directory) and -config. Each one holds settings like the\n")
+	fmt.Fprintf(os.Stderr, "top level of a file, and may set \"extends\" and \"description\":\n\n")
+	fmt.Fprintf(os.Stderr, "  profiles:\n")
+	fmt.Fprintf(os.Stderr, "    backend:\n")
+	fmt.Fprintf(os.Stderr, "      description: Go services\n")
+	fmt.Fprintf(os.Stderr, "      input_dirs: [server]\n")
+	fmt.Fprintf(os.Stderr, "      extensions: [.go, .sql]\n")
+	fmt.Fprintf(os.Stderr, "    review:\n")
+	fmt.Fprintf(os.Stderr, "      extends: backend\n")
+	fmt.Fprintf(os.Stderr, "      newest: 20\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Options:\n", cyan("⚙️"))
+	fmt.Fprintf(os.Stderr, "  -config string   Also read profiles from a JSON, YAML or TOML file\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Examples:\n", cyan("🚀"))
+	fmt.Fprintf(os.Stderr, "  coto profiles\n")
+	fmt.Fprintf(os.Stderr, "  coto -profile backend\n")
+	fmt.Fprintf(os.Stderr, "  coto config show -profile review\n")
+}
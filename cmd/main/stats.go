@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/combine"
+	"github.com/bhangun/coto/pkg/extract"
+)
+
+// otherLanguage groups files no plugin or shebang claims
+const otherLanguage = "other"
+
+// commentStyle describes the comment syntax of a language
+type commentStyle struct {
+	Line  []string    // line comment prefixes
+	Block [][2]string // block comment delimiters
+}
+
+var (
+	cStyle     = commentStyle{Line: []string{"//"}, Block: [][2]string{{"/*", "*/"}}}
+	hashStyle  = commentStyle{Line: []string{"#"}}
+	markupHTML = commentStyle{Block: [][2]string{{"<!--", "-->"}}}
+)
+
+// commentStyles is keyed by the language names of the extract plugins
+var commentStyles = map[string]commentStyle{
+	"go":         cStyle,
+	"java":       cStyle,
+	"javascript": cStyle,
+	"rust":       cStyle,
+	"dart":       cStyle,
+	"php":        {Line: []string{"//", "#"}, Block: [][2]string{{"/*", "*/"}}},
+	"python":     {Line: []string{"#"}, Block: [][2]string{{`"""`, `"""`}, {"'''", "'''"}}},
+	"shell":      hashStyle,
+	"ruby":       hashStyle,
+	"perl":       hashStyle,
+	"lua":        {Line: []string{"--"}},
+}
+
+// extensionStyles covers the generic plugin's files, whose syntax differs
+var extensionStyles = map[string]commentStyle{
+	".yml":  hashStyle,
+	".yaml": hashStyle,
+	".toml": hashStyle,
+	".ini":  {Line: []string{";", "#"}},
+	".cfg":  hashStyle,
+	".conf": hashStyle,
+	".xml":  markupHTML,
+	".html": markupHTML,
+	".md":   markupHTML,
+}
+
+// lineCounts splits the lines of a file into code, comment and blank lines
+type lineCounts struct {
+	Code    int `json:"code"`
+	Comment int `json:"comment"`
+	Blank   int `json:"blank"`
+}
+
+func (c *lineCounts) add(o lineCounts) {
+	c.Code += o.Code
+	c.Comment += o.Comment
+	c.Blank += o.Blank
+}
+
+// Lines is the total number of lines
+func (c lineCounts) Lines() int {
+	return c.Code + c.Comment + c.Blank
+}
+
+// languageStats aggregates the files of one language
+type languageStats struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	lineCounts
+	Bytes  int64 `json:"bytes"`
+	Tokens int   `json:"tokens"`
+}
+
+// fileStats describes a single file in the largest files list
+type fileStats struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Lines    int    `json:"lines"`
+	Bytes    int64  `json:"bytes"`
+	Tokens   int    `json:"tokens"`
+}
+
+// statsReport is the result of coto stats
+type statsReport struct {
+	Total     languageStats   `json:"total"`
+	Languages []languageStats `json:"languages"`
+	Largest   []fileStats     `json:"largest"`
+}
+
+// estimateTokens approximates the model tokens of content at about four
+// bytes per token, which is close for source code and English text
+func estimateTokens(content string) int {
+	return (len(content) + 3) / 4
+}
+
+// styleFor returns the comment syntax for a file of the given language
+func styleFor(language, path string) commentStyle {
+	if style, ok := commentStyles[language]; ok {
+		return style
+	}
+	return extensionStyles[strings.ToLower(filepath.Ext(path))]
+}
+
+// countLines classifies each line of content. A line holding both code and
+// a comment counts as code.
+func countLines(content string, style commentStyle) lineCounts {
+	var counts lineCounts
+	var closing string // delimiter ending the open block comment
+	if content == "" {
+		return counts
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		t := strings.TrimSpace(line)
+		switch {
+		case t == "":
+			counts.Blank++
+		case closing != "":
+			i := strings.Index(t, closing)
+			if i < 0 {
+				counts.Comment++
+				continue
+			}
+			rest := strings.TrimSpace(t[i+len(closing):])
+			closing = ""
+			if rest != "" && !isComment(rest, style) {
+				counts.Code++
+				closing = openBlock(rest, style, false)
+			} else {
+				counts.Comment++
+			}
+		case isComment(t, style):
+			counts.Comment++
+			closing = openBlock(t, style, true)
+		default:
+			counts.Code++
+			closing = openBlock(t, style, false)
+		}
+	}
+	return counts
+}
+
+// isComment reports whether a trimmed line starts with a comment
+func isComment(t string, style commentStyle) bool {
+	for _, prefix := range style.Line {
+		if strings.HasPrefix(t, prefix) {
+			return true
+		}
+	}
+	for _, block := range style.Block {
+		if strings.HasPrefix(t, block[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// openBlock returns the closing delimiter when t leaves a block comment
+// open. Comment lines only open a block when they start with it.
+func openBlock(t string, style commentStyle, commentLine bool) string {
+	for _, block := range style.Block {
+		start := strings.Index(t, block[0])
+		if start < 0 || (commentLine && start > 0) {
+			continue
+		}
+		// Anything after a line comment marker cannot open a block
+		if !commentLine && lineCommentBefore(t, start, style) {
+			continue
+		}
+		rest := t[start+len(block[0]):]
+		if !strings.Contains(rest, block[1]) {
+			return block[1]
+		}
+	}
+	return ""
+}
+
+func lineCommentBefore(t string, pos int, style commentStyle) bool {
+	for _, prefix := range style.Line {
+		if i := strings.Index(t, prefix); i >= 0 && i < pos {
+			return true
+		}
+	}
+	return false
+}
+
+// buildStats aggregates per-language counts and the top largest files
+func buildStats(fileInfos []combine.FileInfo, registry *extract.PluginRegistry, top int) statsReport {
+	byLanguage := make(map[string]*languageStats)
+	var report statsReport
+	var files []fileStats
+
+	for _, info := range fileInfos {
+		language := registry.DetectLanguage(info.Path, []byte(info.Content))
+		if language == "" {
+			language = otherLanguage
+		}
+		counts := countLines(info.Content, styleFor(language, info.Path))
+		tokens := estimateTokens(info.Content)
+
+		ls, ok := byLanguage[language]
+		if !ok {
+			ls = &languageStats{Language: language}
+			byLanguage[language] = ls
+		}
+		for _, agg := range []*languageStats{ls, &report.Total} {
+			agg.Files++
+			agg.add(counts)
+			agg.Bytes += info.Size
+			agg.Tokens += tokens
+		}
+
+		files = append(files, fileStats{
+			Path:     info.RelativePath,
+			Language: language,
+			Lines:    counts.Lines(),
+			Bytes:    info.Size,
+			Tokens:   tokens,
+		})
+	}
+
+	report.Total.Language = "total"
+	report.Languages = []languageStats{}
+	for _, ls := range byLanguage {
+		report.Languages = append(report.Languages, *ls)
+	}
+	sort.Slice(report.Languages, func(i, j int) bool {
+		a, b := report.Languages[i], report.Languages[j]
+		if a.Code != b.Code {
+			return a.Code > b.Code
+		}
+		return a.Language < b.Language
+	})
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Bytes > files[j].Bytes })
+	if top >= 0 && len(files) > top {
+		files = files[:top]
+	}
+	report.Largest = append([]fileStats{}, files...)
+	return report
+}
+
+// printStats writes the report of fileInfos to stdout
+func printStats(fileInfos []combine.FileInfo, top int, format string) error {
+	return writeStats(os.Stdout, buildStats(fileInfos, extract.NewBuiltinRegistry(), top), format)
+}
+
+// writeStats renders the report as a table, JSON or Markdown
+func writeStats(w io.Writer, report statsReport, format string) error {
+	switch strings.ToLower(format) {
+	case "", "text", "table":
+		writeStatsTable(w, report)
+		return nil
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "markdown", "md":
+		writeStatsMarkdown(w, report)
+		return nil
+	default:
+		return fmt.Errorf("unsupported stats format: %s (expected table, json or markdown)", format)
+	}
+}
+
+func writeStatsTable(w io.Writer, report statsReport) {
+	row := func(name, files, code, comment, blank, tokens, size string) {
+		fmt.Fprintf(w, "%-14s %7s %10s %9s %8s %10s %10s\n", name, files, code, comment, blank, tokens, size)
+	}
+	rule := strings.Repeat("─", 74)
+
+	row("Language", "Files", "Code", "Comment", "Blank", "Tokens", "Size")
+	fmt.Fprintln(w, rule)
+	for _, ls := range append(report.Languages, report.Total) {
+		if ls.Language == "total" {
+			fmt.Fprintln(w, rule)
+			ls.Language = "Total"
+		}
+		row(ls.Language, combine.FormatCount(ls.Files), combine.FormatCount(ls.Code), combine.FormatCount(ls.Comment),
+			combine.FormatCount(ls.Blank), combine.FormatCount(ls.Tokens), combine.FormatBytes(ls.Bytes))
+	}
+
+	if len(report.Largest) > 0 {
+		fmt.Fprintf(w, "\nLargest files:\n")
+		for _, f := range report.Largest {
+			fmt.Fprintf(w, "  %10s %8s lines %9s tokens  %s\n",
+				combine.FormatBytes(f.Bytes), combine.FormatCount(f.Lines), combine.FormatCount(f.Tokens), f.Path)
+		}
+	}
+}
+
+func writeStatsMarkdown(w io.Writer, report statsReport) {
+	fmt.Fprintf(w, "| Language | Files | Code | Comment | Blank | Tokens | Size |\n")
+	fmt.Fprintf(w, "|---|---:|---:|---:|---:|---:|---:|\n")
+	for _, ls := range report.Languages {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s |\n", ls.Language, combine.FormatCount(ls.Files),
+			combine.FormatCount(ls.Code), combine.FormatCount(ls.Comment), combine.FormatCount(ls.Blank), combine.FormatCount(ls.Tokens), combine.FormatBytes(ls.Bytes))
+	}
+	t := report.Total
+	fmt.Fprintf(w, "| **Total** | **%s** | **%s** | **%s** | **%s** | **%s** | **%s** |\n", combine.FormatCount(t.Files),
+		combine.FormatCount(t.Code), combine.FormatCount(t.Comment), combine.FormatCount(t.Blank), combine.FormatCount(t.Tokens), combine.FormatBytes(t.Bytes))
+
+	if len(report.Largest) > 0 {
+		fmt.Fprintf(w, "\n### Largest files\n\n")
+		fmt.Fprintf(w, "| File | Language | Lines | Tokens | Size |\n")
+		fmt.Fprintf(w, "|---|---|---:|---:|---:|\n")
+		for _, f := range report.Largest {
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s | %s |\n", f.Path, f.Language,
+				combine.FormatCount(f.Lines), combine.FormatCount(f.Tokens), combine.FormatBytes(f.Bytes))
+		}
+	}
+}
+
+func printStatsHelp() {
+	fmt.Fprintf(os.Stderr, "%s Coto Stats v%s - Language and line statistics\n\n", cyan("📊"), version)
+	fmt.Fprintf(os.Stderr, "Usage: coto stats [options] [path|glob ...]\n\n")
+
+	fmt.Fprintf(os.Stderr, "%s Options:\n", cyan("📋"))
+	fmt.Fprintf(os.Stderr, "  -format string           Report format: table, json, markdown (default table)\n")
+	fmt.Fprintf(os.Stderr, "  -top int                 Number of largest files to list (default 10)\n")
+	fmt.Fprintf(os.Stderr, "  -parallel int            Number of files to read in parallel (default 1)\n")
+	fmt.Fprintf(os.Stderr, "  -h, -help                Show this help message\n")
+	fmt.Fprintf(os.Stderr, "\nAll input and filtering options of the combine command apply, see coto -help.\n")
+	fmt.Fprintf(os.Stderr, "Languages are detected like coto extract does: by extension, then by shebang.\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Examples:\n", cyan("🚀"))
+	fmt.Fprintf(os.Stderr, "  coto stats ./src\n")
+	fmt.Fprintf(os.Stderr, "  coto stats -exclude-generated -format markdown . > STATS.md\n")
+	fmt.Fprintf(os.Stderr, "  coto stats -ext .go -top 5 -format json\n")
+}
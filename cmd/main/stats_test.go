@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bhangun/coto/pkg/combine"
+	"github.com/bhangun/coto/pkg/extract"
+)
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		style   commentStyle
+		want    lineCounts
+	}{
+		{
+			name:    "line comments and blanks",
+			content: "package main\n\n// Doc\nfunc main() {} // trailing\n",
+			style:   cStyle,
+			want:    lineCounts{Code: 2, Comment: 1, Blank: 1},
+		},
+		{
+			name:    "block comment",
+			content: "/*\n * Licence\n\n */\nvar x = 1\n/* one line */\nx = 2 /* opens\nstill comment */ y = 3\n",
+			style:   cStyle,
+			want:    lineCounts{Code: 3, Comment: 4, Blank: 1},
+		},
+		{
+			name:    "python docstring",
+			content: "#!/usr/bin/env python3\ndef f():\n    \"\"\"Summary.\n\n    More.\n    \"\"\"\n    return 1\n",
+			style:   commentStyles["python"],
+			want:    lineCounts{Code: 2, Comment: 4, Blank: 1},
+		},
+		{
+			name:    "no comment syntax",
+			content: "{\n  \"a\": \"// not a comment\"\n}",
+			style:   commentStyle{},
+			want:    lineCounts{Code: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countLines(tt.content, tt.style); got != tt.want {
+				t.Errorf("countLines() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildStats(t *testing.T) {
+	files := []combine.FileInfo{
+		{Path: "main.go", RelativePath: "main.go", Size: 30, Content: "package main\n\n// x\nfunc main() {}\n"},
+		{Path: "bin/deploy", RelativePath: "bin/deploy", Size: 40, Content: "#!/bin/bash\n# deploy\necho hi\n"},
+		{Path: "tool", RelativePath: "tool", Size: 50, Content: "#!/usr/bin/env -S python3 -u\nprint(1)\n"},
+		{Path: "LICENSE", RelativePath: "LICENSE", Size: 10, Content: "MIT\n"},
+	}
+	report := buildStats(files, extract.NewBuiltinRegistry(), 2)
+
+	languages := make(map[string]languageStats)
+	for _, ls := range report.Languages {
+		languages[ls.Language] = ls
+	}
+	for _, lang := range []string{"go", "shell", "python", otherLanguage} {
+		if languages[lang].Files != 1 {
+			t.Errorf("Expected one %s file, got %+v", lang, languages[lang])
+		}
+	}
+	if got := languages["shell"].lineCounts; got != (lineCounts{Code: 1, Comment: 2}) {
+		t.Errorf("Unexpected shell counts %+v", got)
+	}
+	if report.Total.Files != 4 || report.Total.Bytes != 130 {
+		t.Errorf("Unexpected totals %+v", report.Total)
+	}
+	if len(report.Largest) != 2 || report.Largest[0].Path != "tool" || report.Largest[1].Path != "bin/deploy" {
+		t.Errorf("Unexpected largest files %+v", report.Largest)
+	}
+}
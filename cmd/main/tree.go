@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/combine"
+)
+
+// treeNode is a directory or file of the selected set
+type treeNode struct {
+	Name     string      `json:"name"`
+	Dir      bool        `json:"dir,omitempty"`
+	Size     int64       `json:"size"`
+	Files    int         `json:"files,omitempty"` // files below a directory
+	Tokens   int         `json:"tokens,omitempty"`
+	Children []*treeNode `json:"children,omitempty"`
+}
+
+// buildTree arranges the selected files by directory. Sizes come from the
+// file system so nothing is read.
+func buildTree(paths []string, baseDir string) (*treeNode, error) {
+	root := &treeNode{Name: filepath.Base(baseDir), Dir: true}
+	if baseDir == "" {
+		root.Name = "."
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			rel = path
+		}
+		rel = filepath.ToSlash(rel)
+		parts := strings.Split(rel, "/")
+
+		node := root
+		for _, dir := range parts[:len(parts)-1] {
+			node = node.child(dir, true)
+		}
+		node.child(parts[len(parts)-1], false).Size = info.Size()
+	}
+	root.total()
+	return root, nil
+}
+
+// child returns the named child, creating it when needed
+func (n *treeNode) child(name string, dir bool) *treeNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &treeNode{Name: name, Dir: dir}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// total sums sizes, file counts and tokens bottom up and sorts children
+func (n *treeNode) total() {
+	if !n.Dir {
+		n.Tokens = int((n.Size + 3) / 4) // same estimate as coto stats
+		return
+	}
+	n.Size, n.Files, n.Tokens = 0, 0, 0
+	for _, c := range n.Children {
+		c.total()
+		n.Size += c.Size
+		n.Tokens += c.Tokens
+		if c.Dir {
+			n.Files += c.Files
+		} else {
+			n.Files++
+		}
+	}
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+}
+
+// countDirs returns the number of directories below n
+func (n *treeNode) countDirs() int {
+	dirs := 0
+	for _, c := range n.Children {
+		if c.Dir {
+			dirs += 1 + c.countDirs()
+		}
+	}
+	return dirs
+}
+
+// writeTree renders the tree as text or JSON
+func writeTree(w io.Writer, root *treeNode, format string, tokens bool) error {
+	switch strings.ToLower(format) {
+	case "", "text":
+		fmt.Fprintln(w, root.label(tokens))
+		root.writeChildren(w, "", tokens)
+		fmt.Fprintf(w, "\n%d directories, %d files, %s", root.countDirs(), root.Files, combine.FormatBytes(root.Size))
+		if tokens {
+			fmt.Fprintf(w, ", ~%s tokens", combine.FormatCount(root.Tokens))
+		}
+		fmt.Fprintln(w)
+		return nil
+	case "json":
+		if !tokens {
+			root.clearTokens()
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(root)
+	default:
+		return fmt.Errorf("unsupported tree format: %s (expected text or json)", format)
+	}
+}
+
+func (n *treeNode) label(tokens bool) string {
+	var details []string
+	name := n.Name
+	if n.Dir {
+		name = cyan(name + "/")
+		details = append(details, fmt.Sprintf("%d files", n.Files))
+	}
+	details = append(details, combine.FormatBytes(n.Size))
+	if tokens {
+		details = append(details, "~"+combine.FormatCount(n.Tokens)+" tokens")
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.Join(details, ", "))
+}
+
+func (n *treeNode) writeChildren(w io.Writer, prefix string, tokens bool) {
+	for i, c := range n.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, c.label(tokens))
+		if c.Dir {
+			c.writeChildren(w, prefix+indent, tokens)
+		}
+	}
+}
+
+func (n *treeNode) clearTokens() {
+	n.Tokens = 0
+	for _, c := range n.Children {
+		c.clearTokens()
+	}
+}
+
+func printTreeHelp() {
+	fmt.Fprintf(os.Stderr, "%s Coto Tree v%s - Show the files a filter set selects\n\n", cyan("🌳"), version)
+	fmt.Fprintf(os.Stderr, "Usage: coto tree [options] [path|glob ...]\n\n")
+
+	fmt.Fprintf(os.Stderr, "%s Options:\n", cyan("📋"))
+	fmt.Fprintf(os.Stderr, "  -format string           Output format: text, json (default text)\n")
+	fmt.Fprintf(os.Stderr, "  -tokens                  Show estimated tokens per file and directory\n")
+	fmt.Fprintf(os.Stderr, "  -h, -help                Show this help message\n")
+	fmt.Fprintf(os.Stderr, "\nAll input and filtering options of the combine command apply, see coto -help.\n")
+	fmt.Fprintf(os.Stderr, "Nothing is written; file contents are only read by -contains and -not-contains.\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Examples:\n", cyan("🚀"))
+	fmt.Fprintf(os.Stderr, "  coto tree ./src\n")
+	fmt.Fprintf(os.Stderr, "  coto tree -ext .go,.md -exclude-generated -tokens\n")
+	fmt.Fprintf(os.Stderr, "  coto tree -max-size 50000 -format json > selection.json\n")
+}
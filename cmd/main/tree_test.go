@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildTree(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{"a.go": 10, "sub/b.go": 20, "sub/deep/c.go": 30}
+	var paths []string
+	for name, size := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	root, err := buildTree(paths, dir)
+	if err != nil {
+		t.Fatalf("buildTree failed: %v", err)
+	}
+	if root.Files != 3 || root.Size != 60 || root.Tokens != 3+5+8 || root.countDirs() != 2 {
+		t.Errorf("Unexpected root totals: %d files, %d bytes, %d tokens, %d dirs",
+			root.Files, root.Size, root.Tokens, root.countDirs())
+	}
+	if len(root.Children) != 2 || root.Children[0].Name != "a.go" || root.Children[1].Name != "sub" {
+		t.Fatalf("Unexpected children %+v", root.Children)
+	}
+	sub := root.Children[1]
+	if !sub.Dir || sub.Files != 2 || sub.Size != 50 {
+		t.Errorf("Unexpected sub directory %+v", sub)
+	}
+
+	var out strings.Builder
+	if err := writeTree(&out, root, "text", false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "        └── c.go (30 B)") || !strings.Contains(out.String(), "2 directories, 3 files") {
+		t.Errorf("Unexpected tree output:\n%s", out.String())
+	}
+}
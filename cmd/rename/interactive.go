@@ -0,0 +1,178 @@
+package rename
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bhangun/coto/pkg/prompt"
+	"github.com/bhangun/coto/pkg/rename"
+)
+
+// previewRows is the number of renames shown in the preview table
+const previewRows = 20
+
+// Choices of the interactive menu
+const (
+	choicePrefix      = "prefix"
+	choiceSuffix      = "suffix"
+	choicePattern     = "pattern"
+	choiceRegex       = "regex"
+	choiceReplacement = "replacement"
+	choiceRecursive   = "recursive"
+	choiceForce       = "force"
+	choiceApply       = "apply"
+	choiceCancel      = "cancel"
+)
+
+// interactive asks for the directory and lets the user edit the renaming
+// rules while a table previews the new names. It returns false when the
+// user cancels.
+func (c *RenameCommand) interactive(p *prompt.Prompter, w io.Writer) bool {
+	fmt.Fprintf(w, "%s Coto Rename - Interactive Mode\n\n", c.cyan("📁"))
+	c.directory = p.String("Directory to rename files in", c.directory, func(dir string) error {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		return nil
+	})
+
+	for !p.Done() {
+		changed := c.printPreview(w)
+
+		choice := choicePrefix
+		if changed > 0 {
+			choice = choiceApply
+		}
+		options := []string{choicePrefix, choiceSuffix, choicePattern, choiceRegex, choiceReplacement,
+			choiceRecursive, choiceForce, choiceApply, choiceCancel}
+		choice = p.Select("Edit a rule, or apply the renames", options, choice)
+		if p.Done() {
+			break
+		}
+		switch choice {
+		case choicePrefix:
+			c.prefix = p.Optional("Prefix to remove from filenames", c.prefix, nil)
+		case choiceSuffix:
+			c.suffix = p.Optional("Suffix to remove from filenames", c.suffix, nil)
+		case choicePattern:
+			c.pattern = p.Optional("Pattern to remove from filenames", c.pattern, nil)
+		case choiceRegex:
+			c.regex = p.Optional("Regular expression pattern to match", c.regex, func(s string) error {
+				_, err := regexp.Compile(s)
+				return err
+			})
+		case choiceReplacement:
+			c.replacement = p.Optional("Replacement string for regex", c.replacement, nil)
+		case choiceRecursive:
+			c.recursive = p.Bool("Process subdirectories recursively", c.recursive)
+		case choiceForce:
+			c.force = p.Bool("Rename even if the target file exists", c.force)
+		case choiceApply:
+			if changed == 0 {
+				fmt.Fprintf(w, "%s No file would be renamed, edit a rule first\n", c.yellow("⚠"))
+				continue
+			}
+			fmt.Fprintln(w)
+			return true
+		case choiceCancel:
+			return false
+		}
+	}
+	return false
+}
+
+// printPreview prints a table of the renames the current rules would make
+// and returns how many files would be renamed
+func (c *RenameCommand) printPreview(w io.Writer) int {
+	fmt.Fprintf(w, "\n%s %s\n", c.cyan("┌"), strings.Repeat("─", 50))
+	defer fmt.Fprintf(w, "%s %s\n", c.cyan("└"), strings.Repeat("─", 50))
+
+	rules, err := rename.NewRules(c.pattern, c.prefix, c.suffix, c.regex, c.replacement)
+	if err != nil {
+		fmt.Fprintf(w, "%s %s %v\n", c.cyan("│"), c.red("✗"), err)
+		return 0
+	}
+	if rules.Empty() {
+		fmt.Fprintf(w, "%s No rules yet: set a prefix, suffix, pattern or regex\n", c.cyan("│"))
+		return 0
+	}
+	changes, err := rename.Plan(context.Background(), rename.Options{
+		Dir:       c.directory,
+		Rules:     rules,
+		Recursive: c.recursive,
+		Force:     c.force,
+	})
+	if err != nil {
+		fmt.Fprintf(w, "%s %s %v\n", c.cyan("│"), c.red("✗"), err)
+		return 0
+	}
+
+	var shown []rename.Change
+	width := len("OLD NAME")
+	for _, change := range changes {
+		if change.Status == rename.Unchanged {
+			continue
+		}
+		if len(shown) < previewRows {
+			shown = append(shown, change)
+			if n := utf8.RuneCountInString(c.displayName(change.Dir, change.Old)); n > width {
+				width = n
+			}
+		}
+	}
+
+	renamed := countStatus(changes, rename.Renamed)
+	exists := countStatus(changes, rename.Exists)
+	fmt.Fprintf(w, "%s Preview: %s to rename, %d unchanged", c.cyan("│"), c.green(renamed), countStatus(changes, rename.Unchanged))
+	if exists > 0 {
+		fmt.Fprintf(w, ", %s skipped because the target exists", c.yellow(exists))
+	}
+	fmt.Fprintln(w)
+	if len(shown) == 0 {
+		return renamed
+	}
+
+	fmt.Fprintf(w, "%s %s\n", c.cyan("├"), strings.Repeat("─", 50))
+	fmt.Fprintf(w, "%s %s   %s\n", c.cyan("│"), pad("OLD NAME", width), "NEW NAME")
+	for _, change := range shown {
+		old := pad(c.displayName(change.Dir, change.Old), width)
+		if change.Status == rename.Exists {
+			fmt.Fprintf(w, "%s %s %s %s  %s\n", c.cyan("│"), old, c.yellow("→"), c.displayName(change.Dir, change.New), c.yellow("(exists)"))
+		} else {
+			fmt.Fprintf(w, "%s %s %s %s\n", c.cyan("│"), old, c.cyan("→"), c.green(c.displayName(change.Dir, change.New)))
+		}
+	}
+	if more := renamed + exists - len(shown); more > 0 {
+		fmt.Fprintf(w, "%s … and %d more\n", c.cyan("│"), more)
+	}
+	return renamed
+}
+
+// displayName returns the path of name relative to the directory being
+// renamed, so files of subdirectories can be told apart
+func (c *RenameCommand) displayName(dir, name string) string {
+	if rel, err := filepath.Rel(c.directory, filepath.Join(dir, name)); err == nil {
+		return rel
+	}
+	return name
+}
+
+// countStatus returns the number of changes with the given status
+func countStatus(changes []rename.Change, status rename.Status) int {
+	result := rename.Result{Changes: changes}
+	return result.Count(status)
+}
+
+// pad pads s with spaces to width runes
+func pad(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
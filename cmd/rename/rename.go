@@ -1,13 +1,22 @@
 package rename
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
-
+	"time"
+
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/interrupt"
+	"github.com/bhangun/coto/pkg/prompt"
+	"github.com/bhangun/coto/pkg/rename"
+	"github.com/bhangun/coto/pkg/runlog"
+	"github.com/bhangun/coto/pkg/settings"
 	"github.com/fatih/color"
 )
 
@@ -25,8 +34,16 @@ type RenameCommand struct {
 	dryRun      bool
 	force       bool
 	recursive   bool
+	timeout     time.Duration
+	logFormat   string
+	logFile     string
+	outputJSON  bool
+	configFile  string
+	profile     string
+	help        bool
 
 	// Internal fields
+	log    *runlog.Logger
 	cyan   func(...interface{}) string
 	green  func(...interface{}) string
 	yellow func(...interface{}) string
@@ -45,7 +62,65 @@ func NewRenameCommand() *RenameCommand {
 
 // Run executes the rename command
 func (c *RenameCommand) Run(args []string) error {
-	// Define flags
+	fs := c.flags()
+
+	// Parse flags
+	if err := fs.Parse(args); err != nil {
+		return runlog.Usage(err)
+	}
+
+	// Check for help
+	if c.help {
+		c.printHelp()
+		return nil
+	}
+
+	// Fill the flags that were not given from the configuration files and
+	// COTO_RENAME_* variables
+	if _, err := c.loadSettings(fs); err != nil {
+		return err
+	}
+
+	// When neither flags nor configuration set a rule, the rules are edited
+	// interactively on a terminal with a preview of the new names
+	if !c.hasRules() && prompt.Terminal() {
+		if !c.interactive(prompt.Stdio(), os.Stdout) {
+			fmt.Printf("%s Canceled, nothing was renamed\n", c.yellow("⚠"))
+			return nil
+		}
+	}
+
+	if c.outputJSON {
+		c.logFormat = runlog.FormatJSON
+	}
+	log, err := runlog.Open("rename", c.logFormat, c.logFile)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+	c.log = log
+
+	result, err := c.run()
+	var stats any
+	if result != nil {
+		stats = renameStats{
+			Renamed:   result.Renamed,
+			Unchanged: result.Count(rename.Unchanged),
+			Exists:    result.Count(rename.Exists),
+			Failed:    result.Count(rename.Failed),
+			DryRun:    c.dryRun,
+		}
+	}
+	return log.Finish(err, stats)
+}
+
+// hasRules reports whether a renaming rule is set
+func (c *RenameCommand) hasRules() bool {
+	return c.pattern != "" || c.prefix != "" || c.suffix != "" || c.regex != ""
+}
+
+// flags defines the flags of the command
+func (c *RenameCommand) flags() *flag.FlagSet {
 	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
 	fs.StringVar(&c.directory, "dir", ".", "Directory to rename files in")
 	fs.StringVar(&c.pattern, "pattern", "", "Pattern to remove from filenames (prefix, suffix, or substring)")
@@ -58,41 +133,68 @@ func (c *RenameCommand) Run(args []string) error {
 	fs.BoolVar(&c.dryRun, "dry-run", false, "Show what would be renamed without actually renaming")
 	fs.BoolVar(&c.force, "force", false, "Force rename even if target file exists")
 	fs.BoolVar(&c.recursive, "recursive", false, "Process subdirectories recursively")
+	fs.DurationVar(&c.timeout, "timeout", 0, "Stop after this long, e.g. 90s or 5m (0 = no limit)")
+	fs.StringVar(&c.logFormat, "log-format", runlog.FormatText, "Log format: text or json")
+	fs.BoolVar(&c.outputJSON, "output-json", false, "Log JSON events and a summary (same as -log-format json)")
+	fs.StringVar(&c.logFile, "log-file", "", "Write the JSON log to a file instead of stderr")
+	fs.StringVar(&c.configFile, "config", "", "Load configuration from a JSON, YAML or TOML file")
+	fs.StringVar(&c.profile, "profile", "", "Apply a named profile from the configuration files")
 
 	// Help flag
-	help := fs.Bool("help", false, "Show help")
-	h := fs.Bool("h", false, "Show help (shorthand)")
+	fs.BoolVar(&c.help, "help", false, "Show help")
+	fs.BoolVar(&c.help, "h", false, "Show help (shorthand)")
+	return fs
+}
 
-	// Parse flags
-	if err := fs.Parse(args); err != nil {
-		return err
+// loadSettings merges the configuration layers into the flags that were not
+// given on the command line. The project file is looked for from -dir upward.
+func (c *RenameCommand) loadSettings(fs *flag.FlagSet) (*settings.Settings, error) {
+	bindings, schema := settings.FlagBindings(fs, "help", "h", "config", "profile")
+	loader := settings.Loader{Section: "rename", Schema: schema, Dir: c.directory, File: c.configFile, Profile: c.profile}
+	s, err := loader.Load(fs, bindings)
+	if err == nil {
+		err = s.Apply(fs, bindings)
 	}
-
-	// Check for help
-	if *help || *h {
-		c.printHelp()
-		return nil
+	if err != nil {
+		return nil, runlog.Usage(fmt.Errorf("error loading config: %w", err))
 	}
+	return s, nil
+}
 
-	// Validate directory exists
-	if _, err := os.Stat(c.directory); os.IsNotExist(err) {
-		return fmt.Errorf("directory does not exist: %s", c.directory)
+// ShowConfig prints the merged configuration of the command and where each
+// value came from
+func (c *RenameCommand) ShowConfig(args []string) error {
+	fs := c.flags()
+	if err := fs.Parse(args); err != nil {
+		return runlog.Usage(err)
 	}
-
-	// At least one renaming option must be specified
-	hasOptions := c.pattern != "" || c.prefix != "" || c.suffix != "" || c.regex != ""
-	if !hasOptions {
-		return fmt.Errorf("at least one renaming option must be specified (-pattern, -prefix, -suffix, or -regex)")
+	s, err := c.loadSettings(fs)
+	if err != nil {
+		return err
 	}
+	return s.Fprint(os.Stdout)
+}
 
-	// Validate regex if provided
-	var compiledRegex *regexp.Regexp
-	if c.regex != "" {
-		var err error
-		compiledRegex, err = regexp.Compile(c.regex)
-		if err != nil {
-			return fmt.Errorf("invalid regex pattern: %v", err)
+// renameStats is the summary of a run in the JSON log
+type renameStats struct {
+	Renamed   int  `json:"renamed"`
+	Unchanged int  `json:"unchanged"`
+	Exists    int  `json:"skipped_existing"`
+	Failed    int  `json:"failed"`
+	DryRun    bool `json:"dry_run,omitempty"`
+}
+
+// run validates the flags and renames the files
+func (c *RenameCommand) run() (*rename.Result, error) {
+	rules, err := rename.NewRules(c.pattern, c.prefix, c.suffix, c.regex, c.replacement)
+	if err != nil {
+		return nil, runlog.Usage(err)
+	}
+	if err := c.options(rules).Validate(); err != nil {
+		if errors.Is(err, rename.ErrNoRules) {
+			return nil, runlog.Usage(fmt.Errorf("at least one renaming option must be specified (-pattern, -prefix, -suffix, or -regex)"))
 		}
+		return nil, err
 	}
 
 	if !c.quiet {
@@ -118,23 +220,36 @@ func (c *RenameCommand) Run(args []string) error {
 		}
 	}
 
+	// Ctrl-C, SIGTERM and -timeout stop the run between two files
+	ctx, stop := interrupt.Context(c.timeout)
+	defer stop()
+
 	// Process files in the directory
-	count, err := c.processDirectory(compiledRegex)
+	result, err := rename.Run(ctx, c.options(c.rules(rules.Regex)))
+	if interrupt.Canceled(err) {
+		return result, fmt.Errorf("%s after renaming %d files: %w", strings.ToLower(interrupt.Reason(ctx)), result.Renamed, err)
+	}
 	if err != nil {
-		return err
+		return result, err
 	}
 
+	failed := result.Count(rename.Failed)
 	if !c.quiet {
-		fmt.Printf("\n%s Renaming completed. %d files processed.\n", c.green("✓"), count)
+		if failed > 0 {
+			fmt.Printf("\n%s Renaming finished. %d files processed, %d failed.\n", c.yellow("⚠"), result.Renamed, failed)
+		} else {
+			fmt.Printf("\n%s Renaming completed. %d files processed.\n", c.green("✓"), result.Renamed)
+		}
 	}
 
-	return nil
+	return result, runlog.Partial(failed)
 }
 
 // printHelp prints the help message
 func (c *RenameCommand) printHelp() {
 	fmt.Fprintf(os.Stderr, "%s Coto Rename v1.0.0 - Rename files based on patterns\n\n", c.cyan("📁"))
-	fmt.Fprintf(os.Stderr, "Usage: coto rename [options]\n\n")
+	fmt.Fprintf(os.Stderr, "Usage: coto rename [options]\n")
+	fmt.Fprintf(os.Stderr, "Without a rule in a terminal, the rules are edited with a live preview.\n\n")
 
 	fmt.Fprintf(os.Stderr, "%s Basic Options:\n", c.cyan("📋"))
 	fmt.Fprintf(os.Stderr, "  -dir string            Directory to rename files in (default \".\")\n")
@@ -143,6 +258,8 @@ func (c *RenameCommand) printHelp() {
 	fmt.Fprintf(os.Stderr, "  -suffix string         Suffix to remove from filenames\n")
 	fmt.Fprintf(os.Stderr, "  -regex string          Regular expression pattern to match\n")
 	fmt.Fprintf(os.Stderr, "  -replacement string    Replacement string for regex (use with -regex)\n")
+	fmt.Fprintf(os.Stderr, "  -config string         Load configuration from a JSON, YAML or TOML file\n")
+	fmt.Fprintf(os.Stderr, "  -profile string        Apply a named profile from the configuration files\n")
 
 	fmt.Fprintf(os.Stderr, "\n%s Mode Options:\n", c.cyan("🎯"))
 	fmt.Fprintf(os.Stderr, "  -dry-run               Show what would be renamed without actually renaming\n")
@@ -150,6 +267,12 @@ func (c *RenameCommand) printHelp() {
 	fmt.Fprintf(os.Stderr, "  -quiet                 Suppress non-essential output\n")
 	fmt.Fprintf(os.Stderr, "  -force                 Force rename even if target file exists\n")
 	fmt.Fprintf(os.Stderr, "  -recursive             Process subdirectories recursively\n")
+	fmt.Fprintf(os.Stderr, "  -timeout duration      Stop after this long, e.g. 90s or 5m (0 = no limit)\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Logging Options:\n", c.cyan("📝"))
+	fmt.Fprintf(os.Stderr, "  -log-format string     Log format: text or json (default \"text\")\n")
+	fmt.Fprintf(os.Stderr, "  -output-json           Same as -log-format json\n")
+	fmt.Fprintf(os.Stderr, "  -log-file string       Write the JSON log to a file instead of stderr\n")
 
 	fmt.Fprintf(os.Stderr, "\n%s Information:\n", c.cyan("ℹ️"))
 	fmt.Fprintf(os.Stderr, "  -h, -help              Show this help message\n")
@@ -161,161 +284,61 @@ func (c *RenameCommand) printHelp() {
 	fmt.Fprintf(os.Stderr, "  coto rename -dir ./photos -suffix \".bak\" -dry-run\n")
 }
 
-// processDirectory processes all files in the specified directory and subdirectories if recursive is enabled
-func (c *RenameCommand) processDirectory(regex *regexp.Regexp) (int, error) {
-	count := 0
-	
-	if c.recursive {
-		// Walk the directory tree recursively
-		err := filepath.Walk(c.directory, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			
-			// Skip directories
-			if info.IsDir() {
-				return nil
-			}
-			
-			// Get the directory of the current file
-			dir := filepath.Dir(path)
-			filename := info.Name()
-			
-			newName := c.renameFile(filename, regex)
-			
-			// If the name didn't change, skip
-			if newName == filename {
-				if c.verbose && !c.quiet {
-					fmt.Printf("%s Skipping %s (no change)\n", c.cyan("→"), filename)
-				}
-				return nil
-			}
-			
-			newPath := filepath.Join(dir, newName)
-			
-			// Check if target file already exists
-			if !c.force {
-				if _, err := os.Stat(newPath); err == nil {
-					if !c.quiet {
-						fmt.Printf("%s Skipped %s -> %s (target already exists)\n", c.yellow("⚠"), filename, newName)
-					}
-					return nil
-				}
-			}
-			
-			if !c.quiet {
-				fmt.Printf("%s %s -> %s\n", c.cyan("→"), filename, newName)
-			}
-			
-			if !c.dryRun {
-				if err := os.Rename(path, newPath); err != nil {
-					if !c.quiet {
-						fmt.Printf("%s Error renaming %s: %v\n", c.red("✗"), filename, err)
-					}
-					return nil // Continue processing other files
-				}
-			} else {
-				if c.verbose && !c.quiet {
-					fmt.Printf("%s Would rename %s -> %s\n", c.yellow("→"), filename, newName)
-				}
-			}
-			
-			count++
-			
-			return nil
-		})
-		
-		if err != nil {
-			return count, fmt.Errorf("error walking directory tree: %v", err)
-		}
-	} else {
-		// Original behavior - only process the specified directory
-		entries, err := os.ReadDir(c.directory)
-		if err != nil {
-			return 0, fmt.Errorf("failed to read directory: %v", err)
-		}
-
-		for _, entry := range entries {
-			// Skip directories
-			if entry.IsDir() {
-				continue
-			}
-
-			oldPath := filepath.Join(c.directory, entry.Name())
-			newName := c.renameFile(entry.Name(), regex)
-
-			// If the name didn't change, skip
-			if newName == entry.Name() {
-				if c.verbose && !c.quiet {
-					fmt.Printf("%s Skipping %s (no change)\n", c.cyan("→"), entry.Name())
-				}
-				continue
-			}
-
-			newPath := filepath.Join(c.directory, newName)
-
-			// Check if target file already exists
-			if !c.force {
-				if _, err := os.Stat(newPath); err == nil {
-					if !c.quiet {
-						fmt.Printf("%s Skipped %s -> %s (target already exists)\n", c.yellow("⚠"), entry.Name(), newName)
-					}
-					continue
-				}
-			}
-
-			if !c.quiet {
-				fmt.Printf("%s %s -> %s\n", c.cyan("→"), entry.Name(), newName)
-			}
-
-			if !c.dryRun {
-				if err := os.Rename(oldPath, newPath); err != nil {
-					if !c.quiet {
-						fmt.Printf("%s Error renaming %s: %v\n", c.red("✗"), entry.Name(), err)
-					}
-					continue
-				}
-			} else {
-				if c.verbose && !c.quiet {
-					fmt.Printf("%s Would rename %s -> %s\n", c.yellow("→"), entry.Name(), newName)
-				}
-			}
-
-			count++
-		}
+// options builds the library options from the flags
+func (c *RenameCommand) options(rules rename.Rules) rename.Options {
+	return rename.Options{
+		Dir:       c.directory,
+		Rules:     rules,
+		Recursive: c.recursive,
+		Force:     c.force,
+		DryRun:    c.dryRun,
+		OnEvent:   c.log.Handler(c.printEvent),
 	}
-
-	return count, nil
 }
 
-// renameFile applies the renaming rules to a single filename
-func (c *RenameCommand) renameFile(filename string, regex *regexp.Regexp) string {
-	result := filename
-
-	// Apply regex replacement first if specified
-	if c.regex != "" && regex != nil {
-		if c.replacement != "" {
-			result = regex.ReplaceAllString(result, c.replacement)
-		} else {
-			// If no replacement is specified, just remove the matched pattern
-			result = regex.ReplaceAllString(result, "")
-		}
+// rules returns the renaming rules of the flags with an already compiled regex
+func (c *RenameCommand) rules(regex *regexp.Regexp) rename.Rules {
+	rules := rename.Rules{Replacement: c.replacement, Pattern: c.pattern, Prefix: c.prefix, Suffix: c.suffix}
+	if c.regex != "" {
+		rules.Regex = regex
 	}
+	return rules
+}
 
-	// Apply pattern removal (removes the pattern anywhere in the filename)
-	if c.pattern != "" {
-		result = strings.ReplaceAll(result, c.pattern, "")
+// processDirectory renames the files of the directory, and of its
+// subdirectories if recursive is enabled, and returns how many were renamed
+func (c *RenameCommand) processDirectory(ctx context.Context, regex *regexp.Regexp) (int, error) {
+	result, err := rename.Run(ctx, c.options(c.rules(regex)))
+	if result == nil {
+		return 0, err
 	}
+	return result.Renamed, err
+}
 
-	// Apply prefix removal
-	if c.prefix != "" && strings.HasPrefix(result, c.prefix) {
-		result = strings.TrimPrefix(result, c.prefix)
+// printEvent reports a single rename as it happens
+func (c *RenameCommand) printEvent(e event.Event) {
+	if c.quiet {
+		return
 	}
-
-	// Apply suffix removal
-	if c.suffix != "" && strings.HasSuffix(result, c.suffix) {
-		result = strings.TrimSuffix(result, c.suffix)
+	name := filepath.Base(e.Path)
+	switch e.Kind {
+	case event.Skipped:
+		if e.Reason == rename.ReasonExists {
+			fmt.Printf("%s Skipped %s -> %s (%s)\n", c.yellow("⚠"), name, filepath.Base(e.Target), e.Reason)
+		} else if c.verbose {
+			fmt.Printf("%s Skipping %s (%s)\n", c.cyan("→"), name, e.Reason)
+		}
+	case event.Renamed:
+		fmt.Printf("%s %s -> %s\n", c.cyan("→"), name, filepath.Base(e.Target))
+		if c.dryRun && c.verbose {
+			fmt.Printf("%s Would rename %s -> %s\n", c.yellow("→"), name, filepath.Base(e.Target))
+		}
+	case event.Error:
+		fmt.Printf("%s Error renaming %s: %v\n", c.red("✗"), name, e.Err)
 	}
+}
 
-	return result
+// renameFile applies the renaming rules to a single filename
+func (c *RenameCommand) renameFile(filename string, regex *regexp.Regexp) string {
+	return c.rules(regex).Apply(filename)
 }
@@ -1,10 +1,17 @@
 package rename
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
+
+	"github.com/bhangun/coto/pkg/prompt"
+	"github.com/bhangun/coto/pkg/runlog"
 )
 
 func TestRenameCommand_RenameFile_Prefix(t *testing.T) {
@@ -164,7 +171,7 @@ func TestRenameCommand_ProcessDirectory_Prefix(t *testing.T) {
 	}
 
 	// Process the directory
-	count, err := cmd.processDirectory(nil)
+	count, err := cmd.processDirectory(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("processDirectory failed: %v", err)
 	}
@@ -216,7 +223,7 @@ func TestRenameCommand_ProcessDirectory_DryRun(t *testing.T) {
 	}
 
 	// Process the directory
-	count, err := cmd.processDirectory(nil)
+	count, err := cmd.processDirectory(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("processDirectory failed: %v", err)
 	}
@@ -280,7 +287,7 @@ func TestRenameCommand_ProcessDirectory_Suffix(t *testing.T) {
 	}
 
 	// Process the directory
-	count, err := cmd.processDirectory(nil)
+	count, err := cmd.processDirectory(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("processDirectory failed: %v", err)
 	}
@@ -326,7 +333,7 @@ func TestRenameCommand_ProcessDirectory_Pattern(t *testing.T) {
 	}
 
 	// Process the directory
-	count, err := cmd.processDirectory(nil)
+	count, err := cmd.processDirectory(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("processDirectory failed: %v", err)
 	}
@@ -403,3 +410,93 @@ func TestRenameCommand_Run_InvalidRegex(t *testing.T) {
 		t.Errorf("Expected invalid regex error, got: %v", err)
 	}
 }
+
+func TestRenameCommand_Run_LogJSON(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "old_a.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	logFile := filepath.Join(t.TempDir(), "rename.jsonl")
+
+	cmd := NewRenameCommand()
+	if err := cmd.Run([]string{"-dir", tempDir, "-prefix", "old_", "-quiet", "-output-json", "-log-file", logFile}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var last struct {
+		Event    string
+		Status   string
+		ExitCode int `json:"exit_code"`
+		Stats    struct{ Renamed int }
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || last.Event != "summary" || last.Status != "success" || last.Stats.Renamed != 1 {
+		t.Errorf("Unexpected log:\n%s", data)
+	}
+
+	err = NewRenameCommand().Run([]string{"-dir", tempDir})
+	if runlog.ExitCode(err) != runlog.ExitUsage {
+		t.Errorf("Expected a usage error without renaming options, got %v", err)
+	}
+}
+
+func TestRenameCommand_Interactive(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"old_a.txt", "old_b.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Apply is refused without rules, then a prefix is set and applied
+	var out bytes.Buffer
+	cmd := NewRenameCommand()
+	cmd.directory = "."
+	p := prompt.New(strings.NewReader(tempDir+"\napply\n1\nold_\n\n"), &out)
+	if !cmd.interactive(p, &out) {
+		t.Fatalf("Expected the renames to be applied:\n%s", out.String())
+	}
+	if cmd.directory != tempDir || cmd.prefix != "old_" {
+		t.Errorf("Expected the answers in the flags, got %q and %q", cmd.directory, cmd.prefix)
+	}
+	for _, want := range []string{"No file would be renamed", "1 to rename, 1 unchanged", "old_a.txt", "a.txt", "(exists)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in the preview:\n%s", want, out.String())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "old_a.txt")); err != nil {
+		t.Error("Expected nothing to be renamed before the run")
+	}
+
+	// The end of the input cancels
+	cmd = NewRenameCommand()
+	if cmd.interactive(prompt.New(strings.NewReader(tempDir+"\n1\nold_\n"), &out), &out) {
+		t.Error("Expected the end of the input to cancel")
+	}
+}
+
+func TestRenameCommand_HasRulesFromSettings(t *testing.T) {
+	cmd := NewRenameCommand()
+	fs := cmd.flags()
+	if err := fs.Parse([]string{"-dir", t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.hasRules() {
+		t.Error("Expected no rule without flags or settings")
+	}
+
+	// A rule from the environment skips the interactive mode
+	t.Setenv("COTO_RENAME_PREFIX", "old_")
+	if _, err := cmd.loadSettings(fs); err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.hasRules() {
+		t.Error("Expected the prefix from COTO_RENAME_PREFIX to count as a rule")
+	}
+}
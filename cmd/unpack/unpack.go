@@ -0,0 +1,210 @@
+package unpack
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/bundle"
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/fsys"
+	"github.com/fatih/color"
+)
+
+// UnpackCommand handles the unpack subcommand
+type UnpackCommand struct {
+	// Flags
+	outputDir     string
+	decryptTo     string
+	list          bool
+	force         bool
+	partial       bool
+	dryRun        bool
+	quiet         bool
+	passphraseEnv string
+
+	// Internal fields
+	out    fsys.WriteFS // receives the restored files
+	cyan   func(...interface{}) string
+	green  func(...interface{}) string
+	yellow func(...interface{}) string
+	red    func(...interface{}) string
+}
+
+// NewUnpackCommand creates a new unpack command instance
+func NewUnpackCommand() *UnpackCommand {
+	return &UnpackCommand{
+		out:    fsys.OS,
+		cyan:   color.New(color.FgCyan).SprintFunc(),
+		green:  color.New(color.FgGreen).SprintFunc(),
+		yellow: color.New(color.FgYellow).SprintFunc(),
+		red:    color.New(color.FgRed).SprintFunc(),
+	}
+}
+
+// Run executes the unpack command
+func (c *UnpackCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("unpack", flag.ContinueOnError)
+	fs.StringVar(&c.outputDir, "output", "unpacked", "Directory to restore files into")
+	fs.StringVar(&c.outputDir, "o", "unpacked", "Directory to restore files into (shorthand)")
+	fs.StringVar(&c.decryptTo, "decrypt", "", "Only decrypt and decompress the bundle to this file (\"-\" for stdout)")
+	fs.BoolVar(&c.list, "list", false, "List the files in the bundle without writing them")
+	fs.BoolVar(&c.force, "force", false, "Overwrite existing files")
+	fs.BoolVar(&c.partial, "partial", false, "Also restore files whose content was truncated")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "Show what would be written")
+	fs.BoolVar(&c.quiet, "quiet", false, "Suppress non-essential output")
+	fs.StringVar(&c.passphraseEnv, "passphrase-env", encryption.PassphraseEnv, "Environment variable holding the passphrase")
+
+	// Help flag
+	help := fs.Bool("help", false, "Show help")
+	h := fs.Bool("h", false, "Show help (shorthand)")
+	fs.Usage = c.printHelp
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *help || *h {
+		c.printHelp()
+		return nil
+	}
+	if fs.NArg() != 1 {
+		c.printHelp()
+		return fmt.Errorf("expected exactly one bundle")
+	}
+	bundlePath := fs.Arg(0)
+
+	data, err := encryption.ReadFile(bundlePath, encryption.CachedPassphrase(c.passphraseEnv))
+	if err != nil {
+		return err
+	}
+
+	if c.decryptTo != "" {
+		return c.writeDecrypted(data)
+	}
+
+	b, err := bundle.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", bundlePath, err)
+	}
+
+	if c.list {
+		for _, f := range b.Files {
+			note := ""
+			if f.Partial {
+				note = c.yellow(" (partial: lines " + f.LineRanges + ")")
+			}
+			fmt.Printf("%s%s\n", f.RelativePath, note)
+			for _, alias := range f.Aliases {
+				fmt.Printf("%s %s\n", alias, c.cyan("(alias of "+f.RelativePath+")"))
+			}
+		}
+		return nil
+	}
+
+	return c.restore(b)
+}
+
+// writeDecrypted writes the plain bundle bytes
+func (c *UnpackCommand) writeDecrypted(data []byte) error {
+	if c.decryptTo == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if _, err := os.Stat(c.decryptTo); err == nil && !c.force {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", c.decryptTo)
+	}
+	if err := os.WriteFile(c.decryptTo, data, 0644); err != nil {
+		return err
+	}
+	if !c.quiet {
+		fmt.Printf("%s Decrypted bundle written to %s\n", c.green("✓"), c.decryptTo)
+	}
+	return nil
+}
+
+// restore writes every file of the bundle, and its aliases, below outputDir
+func (c *UnpackCommand) restore(b *bundle.Bundle) error {
+	written, skipped := 0, 0
+	for _, f := range b.Files {
+		if f.Partial && !c.partial {
+			if !c.quiet {
+				fmt.Printf("%s Skipping %s: only lines %s are in the bundle (use -partial)\n",
+					c.yellow("⚠"), f.RelativePath, f.LineRanges)
+			}
+			skipped += 1 + len(f.Aliases)
+			continue
+		}
+
+		for _, rel := range append([]string{f.RelativePath}, f.Aliases...) {
+			target, err := c.targetPath(rel)
+			if err != nil {
+				return err
+			}
+			if _, err := c.out.Stat(target); err == nil && !c.force {
+				if !c.quiet {
+					fmt.Printf("%s Skipping %s: file exists (use -force)\n", c.yellow("⚠"), target)
+				}
+				skipped++
+				continue
+			}
+
+			if c.dryRun {
+				fmt.Printf("%s Would write %s\n", c.cyan("→"), target)
+				written++
+				continue
+			}
+			if err := c.out.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := c.out.WriteFile(target, []byte(f.Content), 0644); err != nil {
+				return err
+			}
+			written++
+		}
+	}
+
+	if !c.quiet {
+		verb := "Restored"
+		if c.dryRun {
+			verb = "Would restore"
+		}
+		fmt.Printf("%s %s %d files into %s", c.green("✓"), verb, written, c.outputDir)
+		if skipped > 0 {
+			fmt.Printf(" (%d skipped)", skipped)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// targetPath maps a bundle path into outputDir, refusing paths that escape it
+func (c *UnpackCommand) targetPath(rel string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to write %q outside %s", rel, c.outputDir)
+	}
+	return filepath.Join(c.outputDir, clean), nil
+}
+
+func (c *UnpackCommand) printHelp() {
+	fmt.Fprintf(os.Stderr, "%s Coto Unpack - Restore files from a bundle\n\n", c.cyan("📦"))
+	fmt.Fprintf(os.Stderr, "Usage: coto unpack [options] <bundle>\n\n")
+
+	fmt.Fprintf(os.Stderr, "%s Options:\n", c.cyan("📋"))
+	fmt.Fprintf(os.Stderr, "  -o, -output string      Directory to restore files into (default \"unpacked\")\n")
+	fmt.Fprintf(os.Stderr, "  -decrypt string          Only decrypt/decompress the bundle to a file (\"-\" for stdout)\n")
+	fmt.Fprintf(os.Stderr, "  -list                    List the files in the bundle\n")
+	fmt.Fprintf(os.Stderr, "  -force                   Overwrite existing files\n")
+	fmt.Fprintf(os.Stderr, "  -partial                 Also restore files whose content was truncated\n")
+	fmt.Fprintf(os.Stderr, "  -dry-run                 Show what would be written\n")
+	fmt.Fprintf(os.Stderr, "  -quiet                   Suppress non-essential output\n")
+	fmt.Fprintf(os.Stderr, "  -passphrase-env string   Variable holding the passphrase (default \"COTO_PASSPHRASE\")\n")
+	fmt.Fprintf(os.Stderr, "  -h, -help                Show this help message\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Examples:\n", c.cyan("🚀"))
+	fmt.Fprintf(os.Stderr, "  coto unpack -o restored bundle.txt.gz\n")
+	fmt.Fprintf(os.Stderr, "  COTO_PASSPHRASE=... coto unpack -list bundle.md.enc\n")
+	fmt.Fprintf(os.Stderr, "  coto unpack -decrypt bundle.md bundle.md.enc\n")
+}
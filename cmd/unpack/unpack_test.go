@@ -0,0 +1,34 @@
+package unpack
+
+import (
+	"testing"
+
+	"github.com/bhangun/coto/pkg/bundle"
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+func TestRestore(t *testing.T) {
+	out := fsys.MapFS{}
+	c := &UnpackCommand{out: out, outputDir: "restored", quiet: true}
+	b := &bundle.Bundle{Files: []bundle.File{
+		{RelativePath: "main.go", Content: "package main\n", Aliases: []string{"copy/main.go"}},
+		{RelativePath: "part.go", Content: "1\n", Partial: true},
+	}}
+
+	if err := c.restore(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"restored/main.go", "restored/copy/main.go"} {
+		if data, err := out.ReadFile(name); err != nil || string(data) != "package main\n" {
+			t.Errorf("%s: %q, %v", name, data, err)
+		}
+	}
+	if _, err := out.Stat("restored/part.go"); err == nil {
+		t.Error("Expected the partial file to be skipped")
+	}
+
+	b.Files = []bundle.File{{RelativePath: "../escape.go"}}
+	if err := c.restore(b); err == nil {
+		t.Error("Expected paths outside the output directory to be refused")
+	}
+}
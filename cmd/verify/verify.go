@@ -0,0 +1,350 @@
+package verify
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/bundle"
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/fsys"
+	"github.com/bhangun/coto/pkg/signing"
+	"github.com/fatih/color"
+)
+
+// VerifyCommand handles the verify subcommand
+type VerifyCommand struct {
+	// Flags
+	extensions     string
+	excludePattern string
+	allExtra       bool
+	noExtra        bool
+	pubKey         string
+	sigFile        string
+	passphraseEnv  string
+	verbose        bool
+	quiet          bool
+
+	// Internal fields
+	files  fsys.FS // holds the directory compared with the bundle
+	cyan   func(...interface{}) string
+	green  func(...interface{}) string
+	yellow func(...interface{}) string
+	red    func(...interface{}) string
+}
+
+// Result lists the differences between a bundle and a directory
+type Result struct {
+	Matched    []string
+	Modified   []string
+	Missing    []string
+	Extra      []string
+	Unverified []string // partial entries without a checksum
+	RootHashOK bool     // false when the stored root hash does not match the entries
+}
+
+// OK reports whether the directory matches the bundle
+func (r Result) OK() bool {
+	return r.RootHashOK && len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// NewVerifyCommand creates a new verify command instance
+func NewVerifyCommand() *VerifyCommand {
+	return &VerifyCommand{
+		files:  fsys.OS,
+		cyan:   color.New(color.FgCyan).SprintFunc(),
+		green:  color.New(color.FgGreen).SprintFunc(),
+		yellow: color.New(color.FgYellow).SprintFunc(),
+		red:    color.New(color.FgRed).SprintFunc(),
+	}
+}
+
+// Run executes the verify command
+func (c *VerifyCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.StringVar(&c.extensions, "ext", "", "Extensions considered for extra files (default: those in the bundle)")
+	fs.StringVar(&c.excludePattern, "exclude", "", "Regex of paths to ignore when looking for extra files")
+	fs.BoolVar(&c.allExtra, "all", false, "Report every non-hidden file missing from the bundle as extra")
+	fs.BoolVar(&c.noExtra, "no-extra", false, "Do not look for extra files")
+	fs.StringVar(&c.pubKey, "pubkey", "", "Check the bundle signature with this ed25519 public key")
+	fs.StringVar(&c.sigFile, "sig", "", "Detached signature file (default: <bundle>.sig when not embedded)")
+	fs.StringVar(&c.passphraseEnv, "passphrase-env", encryption.PassphraseEnv, "Environment variable holding the passphrase of encrypted bundles")
+	fs.BoolVar(&c.verbose, "verbose", false, "List matching files too")
+	fs.BoolVar(&c.quiet, "quiet", false, "Only report problems")
+
+	// Help flag
+	help := fs.Bool("help", false, "Show help")
+	h := fs.Bool("h", false, "Show help (shorthand)")
+	fs.Usage = c.printHelp
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *help || *h {
+		c.printHelp()
+		return nil
+	}
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		c.printHelp()
+		return fmt.Errorf("expected a bundle and an optional directory")
+	}
+	bundlePath := fs.Arg(0)
+	dir := "."
+	if fs.NArg() == 2 {
+		dir = fs.Arg(1)
+	}
+
+	var exclude *regexp.Regexp
+	if c.excludePattern != "" {
+		var err error
+		if exclude, err = regexp.Compile(c.excludePattern); err != nil {
+			return fmt.Errorf("invalid exclude pattern: %v", err)
+		}
+	}
+
+	data, err := encryption.ReadFile(bundlePath, encryption.CachedPassphrase(c.passphraseEnv))
+	if err != nil {
+		return err
+	}
+
+	if c.pubKey != "" {
+		if data, err = c.checkSignature(bundlePath, data); err != nil {
+			return err
+		}
+		// Without a directory only the signature is checked
+		if fs.NArg() == 1 {
+			return nil
+		}
+	}
+
+	b, err := bundle.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", bundlePath, err)
+	}
+
+	if !c.quiet {
+		fmt.Printf("%s Verifying %s (%s, %d files) against %s\n", c.cyan("→"), bundlePath, b.Format, len(b.Files), dir)
+	}
+
+	result, err := c.verify(b, bundlePath, dir, exclude)
+	if err != nil {
+		return err
+	}
+	c.printResult(b, result)
+
+	if !result.RootHashOK {
+		return fmt.Errorf("verification failed: root hash mismatch")
+	}
+	if !result.OK() {
+		return fmt.Errorf("verification failed: %d modified, %d missing, %d extra",
+			len(result.Modified), len(result.Missing), len(result.Extra))
+	}
+	return nil
+}
+
+// checkSignature validates the embedded or detached signature of a bundle
+// and returns the signed bytes
+func (c *VerifyCommand) checkSignature(bundlePath string, data []byte) ([]byte, error) {
+	pub, err := signing.LoadPublicKey(c.pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, sig := signing.SplitTrailer(data)
+	source := "embedded"
+	if sig == nil || c.sigFile != "" {
+		path := c.sigFile
+		if path == "" {
+			path = bundlePath + signing.SignatureExtension
+		}
+		sigData, err := os.ReadFile(path)
+		if os.IsNotExist(err) && c.sigFile == "" {
+			return nil, fmt.Errorf("%s is not signed (no embedded signature or %s)", bundlePath, path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		detached, err := signing.DecodeSignatureFile(sigData)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		signed, sig, source = data, &detached, path
+	}
+
+	if err := signing.Verify(pub, signed, *sig); err != nil {
+		fmt.Printf("%s Signature check failed (%s)\n", c.red("✗"), source)
+		return nil, err
+	}
+	if !c.quiet {
+		fmt.Printf("%s Signature valid, key %s (%s)\n", c.green("✓"), sig.KeyID, source)
+	}
+	return signed, nil
+}
+
+// verify compares every bundle entry with the file of the same path below dir
+func (c *VerifyCommand) verify(b *bundle.Bundle, bundlePath, dir string, exclude *regexp.Regexp) (Result, error) {
+	result := Result{RootHashOK: true}
+	entries := b.Entries()
+
+	if b.RootHash != "" && bundle.RootHash(entries) != b.RootHash {
+		result.RootHashOK = false
+	}
+
+	known := make(map[string]bool, len(entries))
+	exts := make(map[string]bool)
+	for _, entry := range entries {
+		known[entry.Path] = true
+		exts[strings.ToLower(filepath.Ext(entry.Path))] = true
+
+		data, err := c.files.ReadFile(filepath.Join(dir, filepath.FromSlash(entry.Path)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			result.Missing = append(result.Missing, entry.Path)
+		case err != nil:
+			return result, err
+		case entry.SHA256 == "":
+			result.Unverified = append(result.Unverified, entry.Path)
+		case bundle.HashBytes(data) != entry.SHA256:
+			result.Modified = append(result.Modified, entry.Path)
+		default:
+			result.Matched = append(result.Matched, entry.Path)
+		}
+	}
+
+	if c.noExtra {
+		return result, nil
+	}
+
+	if c.extensions != "" {
+		exts = make(map[string]bool)
+		for _, ext := range strings.Split(c.extensions, ",") {
+			exts[strings.ToLower(strings.TrimSpace(ext))] = true
+		}
+	}
+
+	bundleAbs, _ := filepath.Abs(bundlePath)
+	err := fs.WalkDir(c.files, dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if known[rel] || (exclude != nil && exclude.MatchString(rel)) {
+			return nil
+		}
+		if !c.allExtra && !exts[strings.ToLower(filepath.Ext(rel))] {
+			return nil
+		}
+		if abs, _ := filepath.Abs(path); abs == bundleAbs || bundle.IsBundleFS(c.files, path) {
+			return nil
+		}
+		result.Extra = append(result.Extra, rel)
+		return nil
+	})
+	sort.Strings(result.Extra)
+	return result, err
+}
+
+func (c *VerifyCommand) printResult(b *bundle.Bundle, result Result) {
+	if c.verbose && !c.quiet {
+		for _, path := range result.Matched {
+			fmt.Printf("  %s %s\n", c.green("✓"), path)
+		}
+	}
+	for _, path := range result.Modified {
+		fmt.Printf("  %s modified    %s\n", c.red("✗"), path)
+	}
+	for _, path := range result.Missing {
+		fmt.Printf("  %s missing     %s\n", c.red("✗"), path)
+	}
+	for _, path := range result.Extra {
+		fmt.Printf("  %s extra       %s\n", c.yellow("⚠"), path)
+	}
+	if !c.quiet {
+		for _, path := range result.Unverified {
+			fmt.Printf("  %s unverified  %s (partial content without checksum)\n", c.yellow("?"), path)
+		}
+	}
+	if !result.RootHashOK {
+		fmt.Printf("%s Root hash does not match the bundle entries, the bundle was altered\n", c.red("✗"))
+	}
+
+	if c.quiet {
+		return
+	}
+	fmt.Printf("\n%s %s\n", c.cyan("┌"), strings.Repeat("─", 50))
+	fmt.Printf("%s Verification Summary\n", c.cyan("│"))
+	fmt.Printf("%s %s\n", c.cyan("├"), strings.Repeat("─", 50))
+	fmt.Printf("%s Matched:    %s\n", c.cyan("│"), c.green(strconv.Itoa(len(result.Matched))))
+	fmt.Printf("%s Modified:   %s\n", c.cyan("│"), c.countColor(len(result.Modified), c.red))
+	fmt.Printf("%s Missing:    %s\n", c.cyan("│"), c.countColor(len(result.Missing), c.red))
+	fmt.Printf("%s Extra:      %s\n", c.cyan("│"), c.countColor(len(result.Extra), c.yellow))
+	if len(result.Unverified) > 0 {
+		fmt.Printf("%s Unverified: %s\n", c.cyan("│"), c.yellow(strconv.Itoa(len(result.Unverified))))
+	}
+	if b.RootHash != "" {
+		status := c.green("ok")
+		if !result.RootHashOK {
+			status = c.red("mismatch")
+		}
+		fmt.Printf("%s Root hash:  %s\n", c.cyan("│"), status)
+	}
+	fmt.Printf("%s %s\n", c.cyan("└"), strings.Repeat("─", 50))
+
+	if result.OK() {
+		fmt.Printf("\n%s Directory matches the bundle\n", c.green("✓"))
+	}
+}
+
+func (c *VerifyCommand) countColor(n int, bad func(...interface{}) string) string {
+	if n == 0 {
+		return c.green("0")
+	}
+	return bad(strconv.Itoa(n))
+}
+
+func (c *VerifyCommand) printHelp() {
+	fmt.Fprintf(os.Stderr, "%s Coto Verify - Check a bundle against a directory\n\n", c.cyan("🔏"))
+	fmt.Fprintf(os.Stderr, "Usage: coto verify [options] <bundle> [dir]\n\n")
+	fmt.Fprintf(os.Stderr, "With -pubkey and no directory only the signature is checked.\n\n")
+
+	fmt.Fprintf(os.Stderr, "%s Options:\n", c.cyan("📋"))
+	fmt.Fprintf(os.Stderr, "  -ext string          Extensions considered for extra files (default: those in the bundle)\n")
+	fmt.Fprintf(os.Stderr, "  -exclude string      Regex of paths to ignore when looking for extra files\n")
+	fmt.Fprintf(os.Stderr, "  -all                 Report every non-hidden file missing from the bundle\n")
+	fmt.Fprintf(os.Stderr, "  -no-extra            Do not look for extra files\n")
+	fmt.Fprintf(os.Stderr, "  -pubkey string       Check the signature with an ed25519 public key\n")
+	fmt.Fprintf(os.Stderr, "  -sig string          Detached signature file (default: <bundle>.sig)\n")
+	fmt.Fprintf(os.Stderr, "  -passphrase-env string  Variable holding the passphrase of encrypted bundles\n")
+	fmt.Fprintf(os.Stderr, "  -verbose             List matching files too\n")
+	fmt.Fprintf(os.Stderr, "  -quiet               Only report problems\n")
+	fmt.Fprintf(os.Stderr, "  -h, -help            Show this help message\n")
+
+	fmt.Fprintf(os.Stderr, "\n%s Examples:\n", c.cyan("🚀"))
+	fmt.Fprintf(os.Stderr, "  coto -checksum -o review.txt ./src\n")
+	fmt.Fprintf(os.Stderr, "  coto verify review.txt ./src\n")
+	fmt.Fprintf(os.Stderr, "  coto verify -no-extra bundle.json.zst\n")
+	fmt.Fprintf(os.Stderr, "  coto verify -pubkey team.key.pub bundle.txt        # signature only\n")
+	fmt.Fprintf(os.Stderr, "  coto verify -pubkey team.key.pub bundle.txt ./src  # signature and contents\n")
+}
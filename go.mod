@@ -2,10 +2,18 @@ module github.com/bhangun/coto
 
 go 1.21
 
-require github.com/fatih/color v1.15.0
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/fatih/color v1.15.0
+	github.com/klauspost/compress v1.17.11
+	github.com/ulikunitz/xz v0.5.12
+	golang.org/x/crypto v0.31.0
+	golang.org/x/term v0.27.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
-	golang.org/x/sys v0.6.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,197 @@
+// Package bundle reads coto bundles in any output format and provides the
+// checksums used to verify them.
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bhangun/coto/pkg/compression"
+	"github.com/bhangun/coto/pkg/encryption"
+)
+
+// Output formats
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatXML      = "xml"
+	FormatMarkdown = "markdown"
+)
+
+// headerSize is how much of a file is read to recognize a bundle
+const headerSize = 1024
+
+// File is one entry of a bundle
+type File struct {
+	Path         string   `json:"path" xml:"path"`
+	Size         int64    `json:"size" xml:"size"`
+	Modified     string   `json:"modified" xml:"modified"`
+	Content      string   `json:"content" xml:"content"`
+	RelativePath string   `json:"relative_path" xml:"relative_path"`
+	Lines        int      `json:"lines" xml:"lines"`
+	Partial      bool     `json:"partial" xml:"partial"`
+	LineRanges   string   `json:"line_ranges" xml:"line_ranges"`
+	OmittedLines int      `json:"omitted_lines" xml:"omitted_lines"`
+	Aliases      []string `json:"aliases" xml:"aliases>alias"`
+	SHA256       string   `json:"sha256" xml:"sha256"`
+}
+
+// Bundle is a parsed coto bundle
+type Bundle struct {
+	Format   string
+	Version  string
+	RootHash string
+	Files    []File
+}
+
+// Entry pairs a path with the SHA-256 of its content
+type Entry struct {
+	Path   string
+	SHA256 string
+}
+
+// HashBytes returns the hex encoded SHA-256 of data
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// RootHash hashes a sorted "sha256  path" listing of entries, so the same
+// set of files always gives the same root hash regardless of bundle order
+func RootHash(entries []Entry) string {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	h := sha256.New()
+	for _, e := range sorted {
+		fmt.Fprintf(h, "%s  %s\n", e.SHA256, e.Path)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Entries lists every path in the bundle, aliases included, with its
+// checksum. Files without a stored checksum are hashed from their content
+// unless the content is partial, in which case SHA256 is left empty.
+func (b *Bundle) Entries() []Entry {
+	var entries []Entry
+	for _, f := range b.Files {
+		sum := f.SHA256
+		if sum == "" && !f.Partial {
+			sum = HashBytes([]byte(f.Content))
+		}
+		entries = append(entries, Entry{Path: filepath.ToSlash(f.RelativePath), SHA256: sum})
+		for _, alias := range f.Aliases {
+			entries = append(entries, Entry{Path: filepath.ToSlash(alias), SHA256: sum})
+		}
+	}
+	return entries
+}
+
+// DetectFormat returns the format of a bundle from its first bytes, or ""
+// when head does not start like a coto bundle
+func DetectFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte(textTitle+"\nGenerated: ")):
+		return FormatText
+	case bytes.HasPrefix(head, []byte(markdownTitle+"\n\n**Generated**")):
+		return FormatMarkdown
+	case bytes.HasPrefix(head, []byte("<?xml")) && bytes.Contains(head, []byte("<filecombiner_output ")):
+		return FormatXML
+	}
+
+	trimmed := bytes.TrimSpace(head)
+	if bytes.HasPrefix(trimmed, []byte("{")) &&
+		bytes.Contains(trimmed, []byte(`"metadata": {`)) &&
+		bytes.Contains(trimmed, []byte(`"files_count"`)) &&
+		bytes.Contains(trimmed, []byte(`"generated"`)) {
+		return FormatJSON
+	}
+	return ""
+}
+
+// IsBundleFile reports whether path starts with a coto bundle header,
+// looking through compression. Encrypted bundles always count.
+func IsBundleFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	return isBundle(file)
+}
+
+// IsBundleFS is IsBundleFile for a file of fsys
+func IsBundleFS(fsys fs.FS, name string) bool {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	return isBundle(file)
+}
+
+func isBundle(r io.Reader) bool {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(encryption.Magic)); encryption.IsEncrypted(head) {
+		return true
+	}
+
+	reader, _, err := compression.NewReader(br)
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+
+	head := make([]byte, headerSize)
+	n, _ := io.ReadFull(reader, head)
+	return DetectFormat(head[:n]) != ""
+}
+
+// ReadFile reads and parses a bundle, decompressing it if needed.
+// Encrypted bundles need Open.
+func ReadFile(path string) (*Bundle, error) {
+	return Open(path, nil)
+}
+
+// Open reads and parses a bundle that may be encrypted and compressed;
+// passphrase is only called for encrypted bundles
+func Open(path string, passphrase func() ([]byte, error)) (*Bundle, error) {
+	data, err := encryption.ReadFile(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	b, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return b, nil
+}
+
+// Parse parses a bundle in any of the output formats
+func Parse(data []byte) (*Bundle, error) {
+	head := data
+	if len(head) > headerSize {
+		head = head[:headerSize]
+	}
+
+	switch DetectFormat(head) {
+	case FormatText:
+		return parseText(data)
+	case FormatMarkdown:
+		return parseMarkdown(data)
+	case FormatJSON:
+		return parseJSON(data)
+	case FormatXML:
+		return parseXML(data)
+	}
+	return nil, fmt.Errorf("not a coto bundle")
+}
@@ -0,0 +1,106 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+)
+
+const textBundle = `Coto Output
+Generated: 2024-06-15 12:00:00
+Files: 2 | Directories: 1 | Total Size: 40 B
+
+
+================================================================================
+a.go
+Size: 20 B | Modified: 2024-06-15 11:00:00
+Aliases: b.go, c/a.go
+--------------------------------------------------------------------------------
+package a
+================================================================================
+
+================================================================================
+big.txt
+Size: 2.0 KB | Modified: 2024-06-15 11:00:00 | Partial: lines 1-2 of 1,245 (1,243 omitted)
+SHA-256: abc123
+--------------------------------------------------------------------------------
+first
+================================================================================
+[... 1,243 lines omitted ...]
+================================================================================
+
+
+=== SUMMARY ===
+Files processed: 2
+`
+
+const markdownBundle = "# Coto Output\n\n**Generated**: 2024-06-15 12:00:00  \n**Files**: 2 | **Directories**: 1 | **Total Size**: 40 B  \n**Root SHA-256**: `ff00`  \n\n" +
+	"## File 1: `a.go`\n\n**Size**: 20 B  \n**Modified**: 2024-06-15 11:00:00  \n**Aliases**: `b.go`, `c/a.go`  \n\n### Content\n```\npackage a\n```\n\n---\n\n" +
+	"## File 2: `doc.md`\n\n**Size**: 20 B  \n**Modified**: 2024-06-15 11:00:00  \n\n### Content\n```\nExample:\n```\n\n---\n\nstill doc\n```\n\n---\n\n" +
+	"## Summary\n\n- **Files processed**: 2\n"
+
+func TestParseText(t *testing.T) {
+	b, err := Parse([]byte(textBundle))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if b.Format != FormatText || len(b.Files) != 2 {
+		t.Fatalf("Expected 2 text files, got %s with %d", b.Format, len(b.Files))
+	}
+
+	a := b.Files[0]
+	if a.RelativePath != "a.go" || a.Content != "package a" || len(a.Aliases) != 2 || a.Aliases[1] != "c/a.go" {
+		t.Errorf("Unexpected first file: %+v", a)
+	}
+
+	big := b.Files[1]
+	if !big.Partial || big.Lines != 1245 || big.OmittedLines != 1243 || big.SHA256 != "abc123" {
+		t.Errorf("Unexpected partial metadata: %+v", big)
+	}
+	if !strings.Contains(big.Content, strings.Repeat("=", 80)+"\n[... 1,243") {
+		t.Errorf("Expected a rule inside content to be kept, got %q", big.Content)
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	b, err := Parse([]byte(markdownBundle))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if b.Format != FormatMarkdown || b.RootHash != "ff00" || len(b.Files) != 2 {
+		t.Fatalf("Unexpected bundle: %+v", b)
+	}
+	if b.Files[0].Aliases[0] != "b.go" {
+		t.Errorf("Unexpected aliases: %v", b.Files[0].Aliases)
+	}
+	if b.Files[1].Content != "Example:\n```\n\n---\n\nstill doc" {
+		t.Errorf("Expected fenced content to be kept, got %q", b.Files[1].Content)
+	}
+}
+
+func TestEntriesAndRootHash(t *testing.T) {
+	b := &Bundle{Files: []File{
+		{RelativePath: "b.go", Content: "b"},
+		{RelativePath: "a.go", Content: "a", Aliases: []string{"z.go"}},
+		{RelativePath: "p.go", Content: "x", Partial: true},
+	}}
+
+	entries := b.Entries()
+	if len(entries) != 4 || entries[2].SHA256 != HashBytes([]byte("a")) || entries[3].SHA256 != "" {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+
+	reversed := []Entry{entries[3], entries[2], entries[1], entries[0]}
+	if RootHash(entries) != RootHash(reversed) {
+		t.Error("Expected root hash to be independent of entry order")
+	}
+	entries[0].SHA256 = HashBytes([]byte("changed"))
+	if RootHash(entries) == RootHash(reversed) {
+		t.Error("Expected root hash to change with content")
+	}
+}
+
+func TestParse_NotABundle(t *testing.T) {
+	if _, err := Parse([]byte("just some notes\n")); err == nil {
+		t.Error("Expected error for non-bundle input, got nil")
+	}
+}
@@ -0,0 +1,228 @@
+package bundle
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	textTitle     = "Coto Output"
+	markdownTitle = "# Coto Output"
+)
+
+var (
+	textRule     = strings.Repeat("=", 80)
+	textDivider  = strings.Repeat("-", 80)
+	partialInfo  = regexp.MustCompile(`lines (\S+) of ([\d,]+) \(([\d,]+) omitted\)`)
+	markdownFile = regexp.MustCompile("^## File \\d+: `(.*)`$")
+	backticked   = regexp.MustCompile("`([^`]*)`")
+)
+
+// parseText reads the text format. Each file is framed by lines of "="
+// with a "-" divider between its header and content.
+func parseText(data []byte) (*Bundle, error) {
+	b := &Bundle{Format: FormatText}
+	lines := strings.Split(string(data), "\n")
+
+	// Header lines up to the first file
+	i := 0
+	for ; i < len(lines) && lines[i] != textRule; i++ {
+		if v, ok := strings.CutPrefix(lines[i], "Root SHA-256: "); ok {
+			b.RootHash = v
+		}
+	}
+
+	for i < len(lines) && lines[i] == textRule {
+		if i+2 >= len(lines) || !strings.HasPrefix(lines[i+2], "Size: ") {
+			break
+		}
+		f := File{RelativePath: lines[i+1]}
+		i += 2
+
+		// Metadata lines until the divider
+		for ; i < len(lines) && lines[i] != textDivider; i++ {
+			line := lines[i]
+			switch {
+			case strings.HasPrefix(line, "Size: "):
+				for _, part := range strings.Split(line, " | ") {
+					if v, ok := strings.CutPrefix(part, "Modified: "); ok {
+						f.Modified = v
+					}
+					if v, ok := strings.CutPrefix(part, "Partial: "); ok {
+						parsePartial(&f, v)
+					}
+				}
+			case strings.HasPrefix(line, "Aliases: "):
+				f.Aliases = strings.Split(strings.TrimPrefix(line, "Aliases: "), ", ")
+			case strings.HasPrefix(line, "SHA-256: "):
+				f.SHA256 = strings.TrimPrefix(line, "SHA-256: ")
+			}
+		}
+		if i >= len(lines) {
+			return nil, fmt.Errorf("unterminated header for %s", f.RelativePath)
+		}
+		i++
+
+		// Content runs until a closing rule followed by the next file or the summary
+		start := i
+		for ; i < len(lines); i++ {
+			if lines[i] == textRule && textSectionEnds(lines, i+1) {
+				break
+			}
+		}
+		if i >= len(lines) {
+			return nil, fmt.Errorf("unterminated content for %s", f.RelativePath)
+		}
+		f.Content = strings.Join(lines[start:i], "\n")
+		finishFile(&f)
+		b.Files = append(b.Files, f)
+
+		// Skip the blank line before the next file
+		i += 2
+	}
+	return b, nil
+}
+
+// textSectionEnds reports whether lines[i:] starts a new file or the summary
+func textSectionEnds(lines []string, i int) bool {
+	if i >= len(lines) || (i == len(lines)-1 && lines[i] == "") {
+		return true
+	}
+	if lines[i] != "" || i+1 >= len(lines) {
+		return false
+	}
+	next := lines[i+1]
+	if next == textRule {
+		return i+3 < len(lines) && strings.HasPrefix(lines[i+3], "Size: ")
+	}
+	return next == "" && i+2 < len(lines) && lines[i+2] == "=== SUMMARY ==="
+}
+
+// parseMarkdown reads the markdown format, where each file is a "## File"
+// section with its content in a fenced block
+func parseMarkdown(data []byte) (*Bundle, error) {
+	b := &Bundle{Format: FormatMarkdown}
+	lines := strings.Split(string(data), "\n")
+
+	i := 0
+	for ; i < len(lines) && !markdownFile.MatchString(lines[i]); i++ {
+		if v, ok := strings.CutPrefix(lines[i], "**Root SHA-256**: "); ok {
+			b.RootHash = strings.Trim(strings.TrimSpace(v), "`")
+		}
+	}
+
+	for i < len(lines) {
+		m := markdownFile.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		f := File{RelativePath: m[1]}
+		i++
+
+		for ; i < len(lines) && lines[i] != "### Content"; i++ {
+			key, value, ok := strings.Cut(strings.TrimSpace(lines[i]), ": ")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "**Modified**":
+				f.Modified = value
+			case "**Partial**":
+				parsePartial(&f, value)
+			case "**Aliases**":
+				for _, alias := range backticked.FindAllStringSubmatch(value, -1) {
+					f.Aliases = append(f.Aliases, alias[1])
+				}
+			case "**SHA-256**":
+				f.SHA256 = strings.Trim(value, "`")
+			}
+		}
+		if i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "```") {
+			return nil, fmt.Errorf("missing content block for %s", f.RelativePath)
+		}
+		i += 2
+
+		start := i
+		for ; i < len(lines); i++ {
+			if lines[i] == "```" && markdownSectionEnds(lines, i+1) {
+				break
+			}
+		}
+		if i >= len(lines) {
+			return nil, fmt.Errorf("unterminated content block for %s", f.RelativePath)
+		}
+		f.Content = strings.Join(lines[start:i], "\n")
+		finishFile(&f)
+		b.Files = append(b.Files, f)
+
+		// Skip the blank line, the horizontal rule and the blank line after it
+		i += 4
+	}
+	return b, nil
+}
+
+// markdownSectionEnds reports whether lines[i:] closes a file section
+func markdownSectionEnds(lines []string, i int) bool {
+	if i+3 >= len(lines) {
+		return i >= len(lines)
+	}
+	if lines[i] != "" || lines[i+1] != "---" || lines[i+2] != "" {
+		return false
+	}
+	next := lines[i+3]
+	return markdownFile.MatchString(next) || strings.HasPrefix(next, "## Summary") || next == ""
+}
+
+func parseJSON(data []byte) (*Bundle, error) {
+	var doc struct {
+		Metadata struct {
+			Version  string `json:"version"`
+			RootHash string `json:"root_sha256"`
+		} `json:"metadata"`
+		Files []File `json:"files"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return &Bundle{Format: FormatJSON, Version: doc.Metadata.Version, RootHash: doc.Metadata.RootHash, Files: doc.Files}, nil
+}
+
+func parseXML(data []byte) (*Bundle, error) {
+	var doc struct {
+		Version  string `xml:"version,attr"`
+		Metadata struct {
+			RootHash string `xml:"root_sha256"`
+		} `xml:"metadata"`
+		Files []File `xml:"file"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return &Bundle{Format: FormatXML, Version: doc.Version, RootHash: doc.Metadata.RootHash, Files: doc.Files}, nil
+}
+
+// parsePartial reads "lines 1-3,9-10 of 1,245 (1,239 omitted)"
+func parsePartial(f *File, value string) {
+	m := partialInfo.FindStringSubmatch(value)
+	if m == nil {
+		return
+	}
+	f.Partial = true
+	f.LineRanges = m[1]
+	f.Lines, _ = strconv.Atoi(strings.ReplaceAll(m[2], ",", ""))
+	f.OmittedLines, _ = strconv.Atoi(strings.ReplaceAll(m[3], ",", ""))
+}
+
+// finishFile fills in what the text formats do not record exactly
+func finishFile(f *File) {
+	if f.Path == "" {
+		f.Path = f.RelativePath
+	}
+	if !f.Partial {
+		f.Size = int64(len(f.Content))
+	}
+}
@@ -0,0 +1,385 @@
+// Package combine selects files below one or more input roots and writes
+// them into a single bundle in text, JSON, XML or Markdown format.
+//
+// Collect applies the filters, Load reads the selected files and WriteFile
+// encodes them. Build does the last two for a collection and Run does
+// everything the way the coto command does.
+package combine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bhangun/coto/pkg/compression"
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/fsys"
+	"github.com/bhangun/coto/pkg/signing"
+)
+
+// Version is written into every bundle header
+const Version = "0.1.1"
+
+// Options select the input files and control the bundle. The JSON form is
+// the coto configuration file.
+type Options struct {
+	InputDir         string   `json:"input_dir"`
+	InputDirs        []string `json:"input_dirs,omitempty"` // several roots, directories, files or globs
+	FilesFrom        string   `json:"files_from,omitempty"` // file listing paths to include, "-" for stdin
+	NullSeparated    bool     `json:"null_separated,omitempty"`
+	OutputFile       string   `json:"output_file"`
+	Extensions       []string `json:"extensions"`
+	ExcludeHidden    bool     `json:"exclude_hidden"`
+	MaxFileSize      int64    `json:"max_file_size"`
+	MinFileSize      int64    `json:"min_file_size"`
+	ExcludePattern   string   `json:"exclude_pattern"`
+	IncludePattern   string   `json:"include_pattern"`
+	Rules            []string `json:"rules,omitempty"` // ordered "+ glob", "- glob" or ". rules-file" entries
+	NoIgnore         bool     `json:"no_ignore,omitempty"`
+	GitIgnore        bool     `json:"gitignore,omitempty"`
+	Contains         string   `json:"contains,omitempty"`     // regex file contents must match
+	NotContains      string   `json:"not_contains,omitempty"` // regex file contents must not match
+	ExcludeGenerated bool     `json:"exclude_generated,omitempty"`
+	ModifiedAfter    string   `json:"modified_after,omitempty"`  // date or relative age such as "7d"
+	ModifiedBefore   string   `json:"modified_before,omitempty"` // date or relative age such as "7d"
+	MaxDepth         int      `json:"max_depth,omitempty"`
+	MinDepth         int      `json:"min_depth,omitempty"`
+	Newest           int      `json:"newest,omitempty"` // keep only the N most recently modified files
+	FollowSymlinks   bool     `json:"follow_symlinks,omitempty"`
+	DedupeLinks      bool     `json:"dedupe_links,omitempty"`   // include files reachable via several paths once
+	Dedupe           bool     `json:"dedupe,omitempty"`         // emit identical contents once, with aliases
+	Checksum         bool     `json:"checksum,omitempty"`       // record SHA-256 per file and a root hash
+	SignKey          string   `json:"sign_key,omitempty"`       // ed25519 private key to sign the bundle with
+	SignatureFile    bool     `json:"signature_file,omitempty"` // write <output>.sig instead of embedding
+	Encrypt          bool     `json:"encrypt,omitempty"`        // passphrase-encrypt the output
+	PassphraseEnv    string   `json:"passphrase_env,omitempty"` // variable holding the passphrase, default COTO_PASSPHRASE
+	MaxLines         int      `json:"max_lines,omitempty"`
+	Truncate         string   `json:"truncate,omitempty"`      // head, tail or head+tail
+	PromptHeader     string   `json:"prompt_header,omitempty"` // file of instructions written before the files
+	PromptFooter     string   `json:"prompt_footer,omitempty"` // file of text written after the files
+	Prompt           string   `json:"prompt,omitempty"`        // text written after the files, such as a question
+	OutputFormat     string   `json:"output_format"`
+	Compress         bool     `json:"compress"` // legacy switch, same as Compression "gzip"
+	Compression      string   `json:"compression"`
+	CompressionLvl   int      `json:"compression_level"`
+	Parallel         int      `json:"parallel"`
+	Quiet            bool     `json:"quiet"`   // used by the command line only
+	Verbose          bool     `json:"verbose"` // used by the command line only
+	DryRun           bool     `json:"dry_run"`
+	Explain          bool     `json:"explain,omitempty"`      // implies DryRun
+	KeepPartial      bool     `json:"keep_partial,omitempty"` // write the files read so far when canceled
+
+	// FS holds the inputs, rule files, file lists and prompt files, nil
+	// reads them from the operating system. The bundle itself is always
+	// written to the operating system and "-" lists are read from stdin.
+	FS         fsys.FS       `json:"-"`
+	Passphrase []byte        `json:"-"` // required by Encrypt
+	OnEvent    event.Handler `json:"-"`
+}
+
+// FileInfo is a single file of a bundle
+type FileInfo struct {
+	Path         string    `json:"path" xml:"path"`
+	Size         int64     `json:"size" xml:"size"`
+	Modified     string    `json:"modified" xml:"modified"`
+	Content      string    `json:"content,omitempty" xml:"content,omitempty"`
+	RelativePath string    `json:"relative_path" xml:"relative_path"`
+	Lines        int       `json:"lines,omitempty" xml:"lines,omitempty"`             // total lines, set when lines were selected
+	Partial      bool      `json:"partial,omitempty" xml:"partial,omitempty"`         // content is not the whole file
+	LineRanges   string    `json:"line_ranges,omitempty" xml:"line_ranges,omitempty"` // lines kept, e.g. "1-150,1096-1245"
+	OmittedLines int       `json:"omitted_lines,omitempty" xml:"omitted_lines,omitempty"`
+	Aliases      aliasList `json:"aliases,omitempty" xml:"aliases,omitempty"` // paths with identical content (-dedupe)
+	SHA256       string    `json:"sha256,omitempty" xml:"sha256,omitempty"`   // checksum of the whole file (-checksum)
+}
+
+// Stats summarizes a run
+type Stats struct {
+	FilesProcessed int     `json:"files_processed"`
+	Directories    int     `json:"directories"`
+	TotalBytes     int64   `json:"total_bytes"`
+	Duration       float64 `json:"duration_seconds"`
+	OutputSize     int64   `json:"output_size"`
+	BrokenLinks    int     `json:"broken_links,omitempty"`
+	SymlinkCycles  int     `json:"symlink_cycles,omitempty"`
+	DuplicateFiles int     `json:"duplicate_files,omitempty"`
+	BytesSaved     int64   `json:"bytes_saved,omitempty"` // content left out by -dedupe
+	RootHash       string  `json:"root_sha256,omitempty"` // hash over all file checksums (-checksum)
+	Errors         int     `json:"errors,omitempty"`      // files that could not be accessed or read
+}
+
+// OptionError reports an invalid option value
+type OptionError struct {
+	Option string // name of the command line flag
+	Err    error
+}
+
+func (e *OptionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// InputError reports an input root or file list that cannot be read
+type InputError struct {
+	Path string
+	Err  error
+}
+
+func (e *InputError) Error() string {
+	if errors.Is(e.Err, fs.ErrNotExist) {
+		return fmt.Sprintf("input path does not exist: %s", e.Path)
+	}
+	return e.Err.Error()
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// OutputError reports a bundle that could not be written
+type OutputError struct {
+	Path string
+	Err  error
+}
+
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("error writing %s: %v", e.Path, e.Err)
+}
+
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+// Codec resolves the compression codec selected by the options
+func (o Options) Codec() (compression.Codec, error) {
+	if o.Compression == "" && o.Compress {
+		return compression.Gzip, nil
+	}
+	codec, err := compression.Parse(o.Compression)
+	if err != nil {
+		return codec, err
+	}
+	if codec != compression.None && !codec.CanWrite() {
+		return codec, fmt.Errorf("%s compression is only supported for reading", codec)
+	}
+	return codec, codec.ValidateLevel(o.CompressionLvl)
+}
+
+// OutputPath returns the file Run writes: OutputFile with the suffix of the
+// compression codec and, when encrypting, the encryption suffix
+func (o Options) OutputPath() string {
+	path := o.OutputFile
+	if codec, err := o.Codec(); err == nil {
+		path = compression.WithExtension(path, codec)
+	}
+	if o.Encrypt {
+		path = encryption.WithExtension(path)
+	}
+	return path
+}
+
+// source returns the file system the inputs are read from
+func (o Options) source() fsys.FS {
+	return fsys.Or(o.FS)
+}
+
+// patterns compiles the exclude and include regular expressions
+func (o Options) patterns() (exclude, include *regexp.Regexp, err error) {
+	if o.ExcludePattern != "" {
+		if exclude, err = regexp.Compile(o.ExcludePattern); err != nil {
+			return nil, nil, &OptionError{Option: "exclude", Err: fmt.Errorf("invalid exclude pattern: %v", err)}
+		}
+	}
+	if o.IncludePattern != "" {
+		if include, err = regexp.Compile(o.IncludePattern); err != nil {
+			return nil, nil, &OptionError{Option: "include", Err: fmt.Errorf("invalid include pattern: %v", err)}
+		}
+	}
+	return exclude, include, nil
+}
+
+// Validate checks the options without reading any input file, so mistakes
+// are reported before a long walk
+func (o Options) Validate() error {
+	if _, err := resolveInputRoots(o.source(), o.Roots()); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(o.OutputFile)
+	if info, err := os.Stat(dir); err != nil {
+		return &OptionError{Option: "output", Err: fmt.Errorf("parent directory does not exist: %s", dir)}
+	} else if !info.IsDir() {
+		return &OptionError{Option: "output", Err: fmt.Errorf("parent path is not a directory: %s", dir)}
+	}
+
+	if err := ValidateExtensions(o.Extensions); err != nil {
+		return &OptionError{Option: "ext", Err: err}
+	}
+	if _, err := o.Codec(); err != nil {
+		return &OptionError{Option: "compress", Err: err}
+	}
+	if _, err := normalizeTruncate(o.Truncate); err != nil {
+		return &OptionError{Option: "truncate", Err: err}
+	}
+	if _, _, err := o.patterns(); err != nil {
+		return err
+	}
+	if o.SignKey != "" {
+		if _, err := signing.LoadPrivateKey(o.SignKey); err != nil {
+			return &OptionError{Option: "sign", Err: fmt.Errorf("invalid signing key: %v", err)}
+		}
+	}
+	if _, err := LoadPrompt(o, "."); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ValidateExtensions checks that every extension starts with a dot or is "*"
+func ValidateExtensions(extensions []string) error {
+	for _, ext := range extensions {
+		ext = strings.TrimSpace(ext)
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") && ext != "*" {
+			return fmt.Errorf("extension '%s' should start with a dot (.) or be '*' for all files", ext)
+		}
+	}
+	return nil
+}
+
+// Result describes a finished Run
+type Result struct {
+	Files      []FileInfo
+	Stats      Stats
+	BaseDir    string
+	OutputFile string // file written, empty for a dry run
+	Signature  string // detached signature file, if one was written
+	// Partial is set when the run was canceled; Files and Stats then cover
+	// the files read before, and OutputFile is only set with KeepPartial
+	Partial bool
+}
+
+// Run selects, reads and bundles the files described by opts. Files that
+// cannot be read are reported to opts.OnEvent and left out.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	collected, err := Collect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return Build(ctx, collected, opts)
+}
+
+// Build reads the collected files and writes the bundle, or only reads them
+// for a dry run. When ctx ends, the partial Result is returned with
+// ctx.Err(); the bundle is discarded unless opts.KeepPartial is set, in which
+// case it is finalized with the files read so far.
+func Build(ctx context.Context, c *Collection, opts Options) (*Result, error) {
+	dryRun := opts.DryRun || opts.Explain
+
+	// Load the signing key and check the passphrase before reading anything
+	write := WriteOptions{Format: opts.OutputFormat, Level: opts.CompressionLvl}
+	write.Codec, _ = opts.Codec()
+	if opts.SignKey != "" {
+		key, err := signing.LoadPrivateKey(opts.SignKey)
+		if err != nil {
+			return nil, &OptionError{Option: "sign", Err: fmt.Errorf("invalid signing key: %v", err)}
+		}
+		write.SignKey = key
+		// JSON has no comment syntax to carry an embedded signature
+		write.DetachedSignature = opts.SignatureFile || strings.EqualFold(opts.OutputFormat, "json")
+	}
+	prompt, err := LoadPrompt(opts, c.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	write.Prompt = prompt
+	if opts.Encrypt {
+		if !dryRun && len(opts.Passphrase) == 0 {
+			return nil, &OptionError{Option: "encrypt", Err: errors.New("encryption needs a passphrase")}
+		}
+		write.Passphrase = opts.Passphrase
+	}
+
+	// A canceled run keeps going with the files read so far if asked to,
+	// otherwise no output is written
+	fileInfos, loadErr := Load(ctx, c, opts)
+	if loadErr != nil && (ctx.Err() == nil || !opts.KeepPartial) {
+		if ctx.Err() != nil {
+			c.Stats.Duration = time.Since(c.started).Seconds()
+			return &Result{Files: fileInfos, Stats: c.Stats, BaseDir: c.BaseDir, Partial: true}, loadErr
+		}
+		return nil, loadErr
+	}
+
+	result := &Result{Stats: c.Stats, BaseDir: c.BaseDir, Partial: loadErr != nil}
+	if opts.Dedupe {
+		fileInfos, result.Stats.DuplicateFiles, result.Stats.BytesSaved = dedupeFiles(fileInfos)
+	}
+	if opts.Checksum {
+		result.Stats.RootHash = rootHash(fileInfos)
+	}
+	result.Files = fileInfos
+	result.Stats.Duration = time.Since(c.started).Seconds()
+
+	if dryRun {
+		return result, loadErr
+	}
+	writeCtx := ctx
+	if opts.KeepPartial {
+		// A bundle that is being finalized is written to the end
+		writeCtx = context.WithoutCancel(ctx)
+	}
+	output := opts.OutputPath()
+	result.Stats.OutputSize, err = WriteFile(writeCtx, fileInfos, output, write, result.Stats)
+	if err != nil {
+		if ctxErr := writeCtx.Err(); ctxErr != nil {
+			result.Partial = true
+			return result, ctxErr
+		}
+		return result, &OutputError{Path: output, Err: err}
+	}
+	result.OutputFile = output
+	if write.SignKey != nil && write.DetachedSignature {
+		result.Signature = output + signing.SignatureExtension
+	}
+	return result, loadErr
+}
+
+func getRelativePath(path, baseDir string) string {
+	relPath, err := relativeTo(baseDir, path)
+	if err != nil {
+		return path
+	}
+	return relPath
+}
+
+// relativeTo is filepath.Rel on the absolute forms of base and path, so
+// that roots given as "src", "../src" or "/abs/src" share one base
+func relativeTo(base, path string) (string, error) {
+	if abs, err := filepath.Abs(base); err == nil {
+		base = abs
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.Rel(base, path)
+}
+
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".") ||
+		(strings.HasPrefix(name, "~") && len(name) > 1)
+}
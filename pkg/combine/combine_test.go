@@ -0,0 +1,275 @@
+package combine
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bhangun/coto/pkg/bundle"
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+func TestRun(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	for name, content := range map[string]string{
+		"main.go":      "package main\n",
+		"util/util.go": "package util\n",
+		"README.md":    "# readme\n",
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events := make(map[event.Kind][]string)
+	opts := Options{
+		InputDir:     src,
+		OutputFile:   filepath.Join(tempDir, "bundle.json"),
+		Extensions:   []string{".go"},
+		OutputFormat: "json",
+		Checksum:     true,
+		Parallel:     2,
+		OnEvent: func(e event.Event) {
+			events[e.Kind] = append(events[e.Kind], filepath.ToSlash(e.Path))
+		},
+	}
+	result, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Files) != 2 || result.Files[0].RelativePath != "main.go" || result.Stats.RootHash == "" {
+		t.Fatalf("Expected main.go and util/util.go with a root hash, got %+v", result)
+	}
+	if len(events[event.Included]) != 2 || len(events[event.Skipped]) != 1 || events[event.Skipped][0] != "README.md" {
+		t.Errorf("Unexpected events: %v", events)
+	}
+	if len(events[event.Processed]) != 2 {
+		t.Errorf("Expected 2 processed events, got %v", events[event.Processed])
+	}
+
+	b, err := bundle.ReadFile(result.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Files) != 2 || b.RootHash != result.Stats.RootHash {
+		t.Errorf("Bundle does not match the result: %+v", b)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tempDir := t.TempDir()
+	output := filepath.Join(tempDir, "out.txt")
+
+	var optionErr *OptionError
+	if _, err := Run(context.Background(), Options{InputDir: tempDir, OutputFile: output, ExcludePattern: "("}); !errors.As(err, &optionErr) || optionErr.Option != "exclude" {
+		t.Errorf("Expected an exclude OptionError, got %v", err)
+	}
+
+	var inputErr *InputError
+	if _, err := Run(context.Background(), Options{InputDir: filepath.Join(tempDir, "missing"), OutputFile: output}); !errors.As(err, &inputErr) {
+		t.Errorf("Expected an InputError, got %v", err)
+	}
+
+	if _, err := Run(context.Background(), Options{InputDir: tempDir, OutputFile: output, Encrypt: true}); !errors.As(err, &optionErr) || optionErr.Option != "encrypt" {
+		t.Errorf("Expected a passphrase error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, Options{InputDir: tempDir, OutputFile: output}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("Expected no output after an error")
+	}
+}
+
+func TestRun_FS(t *testing.T) {
+	src := fstest.MapFS{
+		"src/main.go":     {Data: []byte("package main\n\n// PaymentService\n")},
+		"src/gen.go":      {Data: []byte("package main\n")},
+		"src/.cotoignore": {Data: []byte("gen.go\n")},
+		"src/util.go":     {Data: []byte("package main\n")},
+		"docs/a.md":       {Data: []byte("# a\n")},
+		"docs/b.txt":      {Data: []byte("b\n")},
+	}
+	opts := Options{
+		FS:            fsys.FromFS(src),
+		InputDirs:     []string{"src", "docs/*.md", "src/util.go:1-1"},
+		OutputFile:    filepath.Join(t.TempDir(), "out.txt"),
+		ExcludeHidden: true,
+		DryRun:        true,
+	}
+
+	result, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range result.Files {
+		paths = append(paths, filepath.ToSlash(f.RelativePath))
+	}
+	want := []string{"src/main.go", "src/util.go", "docs/a.md"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, paths)
+	}
+
+	opts.Contains = "PaymentService"
+	if result, err = Run(context.Background(), opts); err != nil || len(result.Files) != 1 {
+		t.Errorf("Expected only main.go to contain the pattern, got %+v, %v", result, err)
+	}
+
+	var inputErr *InputError
+	opts.InputDirs = []string{"missing"}
+	if _, err := Run(context.Background(), opts); !errors.As(err, &inputErr) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a missing InputError, got %v", err)
+	}
+}
+
+func TestRun_FSSideFiles(t *testing.T) {
+	src := fstest.MapFS{
+		"src/main.go":       {Data: []byte("package main\n")},
+		"src/util.go":       {Data: []byte("package main\n")},
+		"src/old.txt":       {Data: []byte(encryption.Magic + "...")},
+		"rules/main.rules":  {Data: []byte(". skip.rules\n")},
+		"rules/skip.rules":  {Data: []byte("- util.go\n")},
+		"list.txt":          {Data: []byte("src/util.go\nsrc/old.txt\n")},
+		"prompt/header.md":  {Data: []byte("Review {{project}}\n")},
+		"prompt/footer.txt": {Data: []byte("Any bugs?\n")},
+	}
+	paths := func(opts Options) string {
+		t.Helper()
+		opts.FS = fsys.FromFS(src)
+		opts.OutputFile = filepath.Join(t.TempDir(), "out.txt")
+		opts.DryRun = true
+		result, err := Run(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, f := range result.Files {
+			paths = append(paths, filepath.ToSlash(f.RelativePath))
+		}
+		return strings.Join(paths, ",")
+	}
+
+	// Rule files, file lists and bundle headers are read from the FS
+	if got := paths(Options{InputDirs: []string{"src"}, Rules: []string{". rules/main.rules"}}); got != "main.go" {
+		t.Errorf("Expected rules from the FS to keep main.go, got %s", got)
+	}
+	if got := paths(Options{FilesFrom: "list.txt"}); got != "src/util.go" {
+		t.Errorf("Expected the list from the FS to give src/util.go, got %s", got)
+	}
+
+	prompt, err := LoadPrompt(Options{FS: fsys.FromFS(src), PromptHeader: "prompt/header.md", PromptFooter: "prompt/footer.txt"}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt.Header != "Review {{project}}" || prompt.Footer != "Any bugs?" {
+		t.Errorf("Unexpected prompt from the FS: %+v", prompt)
+	}
+}
+
+func TestBuild_Canceled(t *testing.T) {
+	src := fstest.MapFS{}
+	for _, name := range []string{"a.go", "b.go", "c.go", "d.go", "e.go", "f.go"} {
+		src[name] = &fstest.MapFile{Data: []byte("package " + strings.TrimSuffix(name, ".go") + "\n")}
+	}
+
+	for _, keep := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		output := filepath.Join(t.TempDir(), "out.txt")
+		opts := Options{FS: fsys.FromFS(src), OutputFile: output, KeepPartial: keep, Checksum: true}
+		opts.OnEvent = func(e event.Event) {
+			if e.Kind == event.Processed {
+				cancel()
+			}
+		}
+
+		result, err := Run(ctx, opts)
+		cancel()
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("keep=%v: expected context.Canceled, got %v", keep, err)
+		}
+		if result == nil || !result.Partial || len(result.Files) == 0 || len(result.Files) == len(src) {
+			t.Fatalf("keep=%v: expected a partial result, got %+v", keep, result)
+		}
+
+		if !keep {
+			if _, err := os.Stat(output); !os.IsNotExist(err) {
+				t.Errorf("Expected the output to be discarded, got %v", err)
+			}
+			continue
+		}
+		b, err := bundle.ReadFile(result.OutputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b.Files) != len(result.Files) || b.RootHash != result.Stats.RootHash {
+			t.Errorf("Expected a valid bundle of %d files, got %d", len(result.Files), len(b.Files))
+		}
+	}
+}
+
+func TestCollect_RootsWithSameNames(t *testing.T) {
+	tempDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"rr/w/src/a.go", "rr/src/a.go", "r3/api/main.go"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package "+filepath.Base(filepath.Dir(path))+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(tempDir, "rr", "w")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+
+	relative := func(opts Options) []string {
+		t.Helper()
+		collected, err := Collect(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, path := range collected.Paths {
+			paths = append(paths, filepath.ToSlash(getRelativePath(path, collected.BaseDir)))
+		}
+		return paths
+	}
+
+	// A root above the working directory and one below it
+	if got := strings.Join(relative(Options{InputDirs: []string{"src", "../src"}}), " "); got != "w/src/a.go src/a.go" {
+		t.Errorf("Expected distinct paths, got %s", got)
+	}
+	// Glob rules see the same paths
+	if got := strings.Join(relative(Options{InputDirs: []string{"src", "../src"}, Rules: []string{"- w/**"}}), " "); got != "src/a.go" {
+		t.Errorf("Expected the rule to drop w/src/a.go, got %s", got)
+	}
+	// A relative and an absolute root
+	if got := strings.Join(relative(Options{InputDirs: []string{"src", filepath.Join(tempDir, "r3", "api")}}), " "); got != "rr/w/src/a.go r3/api/main.go" {
+		t.Errorf("Expected paths below the common parent, got %s", got)
+	}
+}
@@ -0,0 +1,51 @@
+package combine
+
+import (
+	"crypto/sha256"
+	"encoding/xml"
+)
+
+// aliasList holds the other paths of a deduplicated file. In XML each path
+// is an <alias> element inside <aliases>.
+type aliasList []string
+
+func (a aliasList) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Alias []string `xml:"alias"`
+	}{a}, start)
+}
+
+func (a *aliasList) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v struct {
+		Alias []string `xml:"alias"`
+	}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*a = v.Alias
+	return nil
+}
+
+// dedupeFiles keeps the first file for each distinct content and records the
+// paths of identical files as its aliases. It returns the remaining files,
+// the number of duplicates dropped and the bytes their content would have taken.
+func dedupeFiles(fileInfos []FileInfo) ([]FileInfo, int, int64) {
+	var unique []FileInfo
+	index := make(map[[sha256.Size]byte]int)
+	duplicates := 0
+	saved := int64(0)
+
+	for _, info := range fileInfos {
+		// Partial files only alias each other when the whole files match too
+		sum := sha256.Sum256([]byte(info.SHA256 + "\x00" + info.Content))
+		if i, ok := index[sum]; ok {
+			unique[i].Aliases = append(unique[i].Aliases, info.RelativePath)
+			duplicates++
+			saved += int64(len(info.Content))
+			continue
+		}
+		index[sum] = len(unique)
+		unique = append(unique, info)
+	}
+	return unique, duplicates, saved
+}
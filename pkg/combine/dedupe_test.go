@@ -0,0 +1,42 @@
+package combine
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestDedupeFiles(t *testing.T) {
+	files := []FileInfo{
+		{RelativePath: "a/x.go", Content: "same\n"},
+		{RelativePath: "b/y.go", Content: "other\n"},
+		{RelativePath: "b/x.go", Content: "same\n"},
+		{RelativePath: "c/x.go", Content: "same\n"},
+	}
+
+	unique, duplicates, saved := dedupeFiles(files)
+	if len(unique) != 2 || duplicates != 2 || saved != 10 {
+		t.Fatalf("Expected 2 unique, 2 duplicates, 10 bytes saved, got %d, %d, %d", len(unique), duplicates, saved)
+	}
+	if unique[0].RelativePath != "a/x.go" || len(unique[0].Aliases) != 2 || unique[0].Aliases[1] != "c/x.go" {
+		t.Errorf("Unexpected aliases: %+v", unique[0])
+	}
+	if len(unique[1].Aliases) != 0 {
+		t.Errorf("Expected no aliases for b/y.go, got %v", unique[1].Aliases)
+	}
+}
+
+func TestAliasListXML(t *testing.T) {
+	info := FileInfo{RelativePath: "a/x.go", Aliases: aliasList{"b/x.go", "c/x.go"}}
+	data, err := xml.Marshal(info)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded FileInfo
+	if err := xml.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(decoded.Aliases) != 2 || decoded.Aliases[0] != "b/x.go" {
+		t.Errorf("Expected aliases to round trip, got %v from %s", decoded.Aliases, data)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package combine
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// fileIdentity falls back to the fully resolved path where device and inode
+// numbers are not exposed
+func fileIdentity(path string, info os.FileInfo) (fileKey, bool) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fileKey{}, false
+	}
+	if abs, err := filepath.Abs(real); err == nil {
+		real = abs
+	}
+	return fileKey{path: real}, true
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package combine
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode numbers of info
+func fileIdentity(path string, info os.FileInfo) (fileKey, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,688 @@
+package combine
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhangun/coto/pkg/bundle"
+	"github.com/bhangun/coto/pkg/fsys"
+	"github.com/bhangun/coto/pkg/glob"
+)
+
+// filterRule is one ordered include (+) or exclude (-) glob rule
+type filterRule struct {
+	Include bool
+	Pattern string // doublestar glob relative to the base directory
+	DirOnly bool   // pattern ended with "/" and only matches directories
+	MinSize int64
+	MaxSize int64
+	Source  string // where the rule came from, e.g. "rules.txt:3"
+}
+
+func (r filterRule) String() string {
+	sign := "-"
+	if r.Include {
+		sign = "+"
+	}
+	text := sign + " " + r.Pattern
+	if r.DirOnly {
+		text += "/"
+	}
+	if r.MinSize > 0 {
+		text += " min-size=" + FormatBytes(r.MinSize)
+	}
+	if r.MaxSize > 0 {
+		text += " max-size=" + FormatBytes(r.MaxSize)
+	}
+	return text
+}
+
+// sizeAllowed reports whether size satisfies the rule's size limits
+func (r filterRule) sizeAllowed(size int64) bool {
+	if r.MinSize > 0 && size < r.MinSize {
+		return false
+	}
+	if r.MaxSize > 0 && size > r.MaxSize {
+		return false
+	}
+	return true
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix (e.g. 100k, 1.5MB)
+func parseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(value, "B")
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// parseRule parses a single rule line: "+ pattern [min-size=N] [max-size=N]",
+// "- pattern ..." or ". file" to merge the rules of another file
+func parseRule(line, source string) ([]filterRule, error) {
+	return ruleReader{}.parse(line, source)
+}
+
+// ruleReader reads rules while following ". file" includes from src, the
+// operating system when nil. files lists the rule files being read,
+// outermost first.
+type ruleReader struct {
+	src   fsys.FS
+	files []string
+}
+
+// parse parses a rule line of the innermost file being read. Included files
+// are found relative to the directory of the file including them.
+func (r ruleReader) parse(line, source string) ([]filterRule, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	if len(line) < 2 || line[1] != ' ' {
+		return nil, fmt.Errorf("%s: rule must start with \"+ \", \"- \" or \". \": %s", source, line)
+	}
+
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s: rule has no pattern", source)
+	}
+
+	switch line[0] {
+	case '.':
+		path := fields[0]
+		if n := len(r.files); n > 0 && !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(r.files[n-1]), path)
+		}
+		return r.load(path)
+	case '+', '-':
+	default:
+		return nil, fmt.Errorf("%s: rule must start with \"+ \", \"- \" or \". \": %s", source, line)
+	}
+
+	rule := filterRule{Include: line[0] == '+', Source: source}
+	pattern := filepath.ToSlash(fields[0])
+	if strings.HasSuffix(pattern, "/") {
+		rule.DirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	rule.Pattern = strings.TrimPrefix(pattern, "./")
+	if err := glob.Validate(rule.Pattern); err != nil {
+		return nil, fmt.Errorf("%s: invalid pattern %s: %v", source, fields[0], err)
+	}
+
+	for _, option := range fields[1:] {
+		key, value, found := strings.Cut(option, "=")
+		if !found {
+			return nil, fmt.Errorf("%s: invalid rule option: %s", source, option)
+		}
+		size, err := parseSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+		switch key {
+		case "min-size":
+			rule.MinSize = size
+		case "max-size":
+			rule.MaxSize = size
+		default:
+			return nil, fmt.Errorf("%s: unknown rule option: %s", source, key)
+		}
+	}
+
+	return []filterRule{rule}, nil
+}
+
+// loadRuleFile reads ordered rules from a file of src
+func loadRuleFile(src fsys.FS, filename string) ([]filterRule, error) {
+	return ruleReader{src: src}.load(filename)
+}
+
+// load reads the rules of filename, failing when it is already being read
+func (r ruleReader) load(filename string) ([]filterRule, error) {
+	for i, open := range r.files {
+		if sameFile(open, filename) {
+			chain := append(append([]string{}, r.files[i:]...), filename)
+			return nil, fmt.Errorf("rules file %s includes itself: %s", open, strings.Join(chain, " → "))
+		}
+	}
+	r.files = append(r.files[:len(r.files):len(r.files)], filename)
+
+	file, err := fsys.Or(r.src).Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rules file: %v", err)
+	}
+	defer file.Close()
+
+	var rules []filterRule
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		parsed, err := r.parse(scanner.Text(), fmt.Sprintf("%s:%d", filename, lineNo))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %v", err)
+	}
+	return rules, nil
+}
+
+// sameFile reports whether two paths name the same file once made absolute
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+// compileRules parses the configured rule strings in order, reading included
+// rule files from src
+func compileRules(src fsys.FS, lines []string) ([]filterRule, error) {
+	reader := ruleReader{src: src}
+	var rules []filterRule
+	for i, line := range lines {
+		parsed, err := reader.parse(line, fmt.Sprintf("rule %d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed...)
+	}
+	return rules, nil
+}
+
+// ignorePattern is one line of a gitignore-style ignore file
+type ignorePattern struct {
+	Pattern string // doublestar glob relative to the ignore file's directory
+	Negate  bool
+	DirOnly bool
+	Source  string
+}
+
+// ignoreMatcher evaluates ignore files found in the directories being walked
+type ignoreMatcher struct {
+	src   fsys.FS
+	names []string
+	cache map[string][]ignorePattern
+}
+
+func newIgnoreMatcher(src fsys.FS, names []string) *ignoreMatcher {
+	return &ignoreMatcher{src: src, names: names, cache: make(map[string][]ignorePattern)}
+}
+
+// patterns returns the ignore patterns declared directly in dir
+func (m *ignoreMatcher) patterns(dir string) []ignorePattern {
+	if cached, ok := m.cache[dir]; ok {
+		return cached
+	}
+
+	var patterns []ignorePattern
+	for _, name := range m.names {
+		path := filepath.Join(dir, name)
+		file, err := m.src.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		lineNo := 0
+		for scanner.Scan() {
+			lineNo++
+			line := strings.TrimRight(scanner.Text(), " \t\r")
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			p := ignorePattern{Source: fmt.Sprintf("%s:%d", path, lineNo)}
+			if strings.HasPrefix(line, "!") {
+				p.Negate = true
+				line = line[1:]
+			}
+			if strings.HasSuffix(line, "/") {
+				p.DirOnly = true
+				line = strings.TrimRight(line, "/")
+			}
+			// Patterns without a slash match at any depth, like .gitignore
+			if strings.Contains(line, "/") {
+				line = strings.TrimPrefix(line, "/")
+			} else {
+				line = "**/" + line
+			}
+			p.Pattern = line
+			patterns = append(patterns, p)
+		}
+		file.Close()
+	}
+
+	m.cache[dir] = patterns
+	return patterns
+}
+
+// match reports whether path is ignored by an ignore file in one of the
+// directories from baseDir down to the path's parent
+func (m *ignoreMatcher) match(path, baseDir string, isDir bool) (bool, string) {
+	if m == nil || len(m.names) == 0 {
+		return false, ""
+	}
+
+	rel, err := relativeTo(baseDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		baseDir, rel = filepath.Dir(path), filepath.Base(path)
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+
+	ignored, reason := false, ""
+	dir := baseDir
+	for i := range segments {
+		for _, p := range m.patterns(dir) {
+			// Check the path itself and every directory between dir and it
+			for j := i + 1; j <= len(segments); j++ {
+				candidateIsDir := j < len(segments) || isDir
+				if p.DirOnly && !candidateIsDir {
+					continue
+				}
+				if glob.Match(p.Pattern, strings.Join(segments[i:j], "/")) {
+					ignored, reason = !p.Negate, p.Source
+					break
+				}
+			}
+		}
+		dir = filepath.Join(dir, segments[i])
+	}
+	return ignored, reason
+}
+
+// fileFilter bundles everything that decides whether a path is combined
+type fileFilter struct {
+	opts         Options
+	baseDir      string
+	excludeRegex *regexp.Regexp
+	includeRegex *regexp.Regexp
+	rules        []filterRule
+	hasIncludes  bool
+	ignores      *ignoreMatcher
+	contains     *regexp.Regexp
+	notContains  *regexp.Regexp
+	after        time.Time
+	before       time.Time
+	output       *outputGuard
+}
+
+// newFileFilter compiles the rules and ignore settings of opts
+func newFileFilter(opts Options, baseDir string, excludeRegex, includeRegex *regexp.Regexp) (*fileFilter, error) {
+	rules, err := compileRules(opts.source(), opts.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &fileFilter{
+		opts:         opts,
+		baseDir:      baseDir,
+		excludeRegex: excludeRegex,
+		includeRegex: includeRegex,
+		rules:        rules,
+	}
+	if fsys.IsOS(opts.FS) {
+		// Only a bundle on the same file system can end up in its own input
+		filter.output = newOutputGuard(opts.OutputPath())
+	}
+	for _, rule := range rules {
+		if rule.Include {
+			filter.hasIncludes = true
+		}
+	}
+
+	now := time.Now()
+	if opts.ModifiedAfter != "" {
+		if filter.after, err = parseTimeFilter(opts.ModifiedAfter, now); err != nil {
+			return nil, fmt.Errorf("invalid modified-after value: %v", err)
+		}
+	}
+	if opts.ModifiedBefore != "" {
+		if filter.before, err = parseTimeFilter(opts.ModifiedBefore, now); err != nil {
+			return nil, fmt.Errorf("invalid modified-before value: %v", err)
+		}
+	}
+
+	if opts.Contains != "" {
+		if filter.contains, err = regexp.Compile(opts.Contains); err != nil {
+			return nil, fmt.Errorf("invalid contains pattern: %v", err)
+		}
+	}
+	if opts.NotContains != "" {
+		if filter.notContains, err = regexp.Compile(opts.NotContains); err != nil {
+			return nil, fmt.Errorf("invalid not-contains pattern: %v", err)
+		}
+	}
+
+	if !opts.NoIgnore {
+		names := []string{".cotoignore"}
+		if opts.GitIgnore {
+			names = append(names, ".gitignore")
+		}
+		filter.ignores = newIgnoreMatcher(opts.source(), names)
+	}
+	return filter, nil
+}
+
+// shouldDescend decides whether a directory is walked, so excluded trees are pruned early.
+// depth is the directory's depth below its input root (1 for a direct child).
+func shouldDescend(path string, depth int, info os.FileInfo, filter *fileFilter) (bool, string) {
+	if filter.opts.ExcludeHidden && isHidden(info.Name()) {
+		return false, "hidden"
+	}
+
+	// Files inside this directory sit at depth+1
+	if filter.opts.MaxDepth > 0 && depth >= filter.opts.MaxDepth {
+		return false, fmt.Sprintf("contents at depth %d exceed -max-depth %d", depth+1, filter.opts.MaxDepth)
+	}
+
+	if ignored, source := filter.ignores.match(path, filter.baseDir, true); ignored {
+		return false, "ignore file " + source
+	}
+
+	relPath := filepath.ToSlash(getRelativePath(path, filter.baseDir))
+	if filter.opts.ExcludeGenerated && vendorDirs[info.Name()] {
+		return false, "vendored directory"
+	}
+
+	for _, rule := range filter.rules {
+		if rule.Include {
+			// Keep walking if the include rule may match something below
+			if glob.Match(rule.Pattern, relPath) || glob.CouldMatchUnder(rule.Pattern, relPath) {
+				return true, fmt.Sprintf("rule %q (%s)", rule.String(), rule.Source)
+			}
+			continue
+		}
+		if rule.MinSize == 0 && rule.MaxSize == 0 && glob.Match(rule.Pattern, relPath) {
+			return false, fmt.Sprintf("rule %q (%s)", rule.String(), rule.Source)
+		}
+	}
+	return true, ""
+}
+
+// shouldProcessFile decides whether a file is combined and explains why.
+// depth is the file's depth below its input root (1 for a direct child).
+func shouldProcessFile(path string, depth int, info os.FileInfo, filter *fileFilter) (bool, string) {
+	opts := filter.opts
+
+	// Never bundle the output itself
+	if own, why := filter.output.matches(path); own {
+		return false, why
+	}
+
+	// Skip hidden files
+	if opts.ExcludeHidden && isHidden(info.Name()) {
+		return false, "hidden"
+	}
+
+	// Check depth limits
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return false, fmt.Sprintf("depth %d exceeds -max-depth %d", depth, opts.MaxDepth)
+	}
+	if opts.MinDepth > 0 && depth < opts.MinDepth {
+		return false, fmt.Sprintf("depth %d below -min-depth %d", depth, opts.MinDepth)
+	}
+
+	// Check modification time window
+	if !filter.after.IsZero() && !info.ModTime().After(filter.after) {
+		return false, fmt.Sprintf("modified %s, not after %s", info.ModTime().Format("2006-01-02 15:04"), filter.after.Format("2006-01-02 15:04"))
+	}
+	if !filter.before.IsZero() && !info.ModTime().Before(filter.before) {
+		return false, fmt.Sprintf("modified %s, not before %s", info.ModTime().Format("2006-01-02 15:04"), filter.before.Format("2006-01-02 15:04"))
+	}
+
+	// Skip files covered by ignore files
+	if ignored, source := filter.ignores.match(path, filter.baseDir, false); ignored {
+		return false, "ignore file " + source
+	}
+
+	relPath := filepath.ToSlash(getRelativePath(path, filter.baseDir))
+
+	// Apply ordered glob rules; the first matching rule wins
+	reason := ""
+	if len(filter.rules) > 0 {
+		matched := false
+		var sizeMiss string
+		for _, rule := range filter.rules {
+			if !ruleMatchesFile(rule, relPath) {
+				continue
+			}
+			if !rule.sizeAllowed(info.Size()) {
+				if sizeMiss == "" {
+					sizeMiss = fmt.Sprintf("; rule %q (%s) skipped for size %s", rule.String(), rule.Source, FormatBytes(info.Size()))
+				}
+				continue
+			}
+			matched = true
+			reason = fmt.Sprintf("rule %q (%s)", rule.String(), rule.Source)
+			if !rule.Include {
+				return false, reason
+			}
+			break
+		}
+		if !matched && filter.hasIncludes {
+			return false, "no include rule matched" + sizeMiss
+		}
+	}
+
+	// Check file size limits
+	if opts.MaxFileSize > 0 && info.Size() > opts.MaxFileSize {
+		return false, fmt.Sprintf("size %s exceeds -max-size %s", FormatBytes(info.Size()), FormatBytes(opts.MaxFileSize))
+	}
+	if opts.MinFileSize > 0 && info.Size() < opts.MinFileSize {
+		return false, fmt.Sprintf("size %s below -min-size %s", FormatBytes(info.Size()), FormatBytes(opts.MinFileSize))
+	}
+
+	// Check extensions
+	if len(opts.Extensions) > 0 {
+		ext := filepath.Ext(path)
+		found := false
+		for _, allowedExt := range opts.Extensions {
+			if strings.EqualFold(ext, allowedExt) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, fmt.Sprintf("extension %q not in -ext", ext)
+		}
+	}
+
+	// Check regex patterns
+	if filter.excludeRegex != nil && filter.excludeRegex.MatchString(relPath) {
+		return false, fmt.Sprintf("regex -exclude %q", filter.excludeRegex.String())
+	}
+	if filter.includeRegex != nil && !filter.includeRegex.MatchString(relPath) {
+		return false, fmt.Sprintf("regex -include %q did not match", filter.includeRegex.String())
+	}
+
+	// Content checks read the file, so they run last
+	if bundle.IsBundleFS(opts.source(), path) {
+		return false, "coto bundle header"
+	}
+	if filter.contains != nil || filter.notContains != nil || opts.ExcludeGenerated {
+		if opts.ExcludeGenerated {
+			if dir := vendoredParent(relPath); dir != "" {
+				return false, "vendored directory " + dir + "/"
+			}
+		}
+		if ok, why := checkContent(path, filter); !ok {
+			return false, why
+		}
+	}
+
+	if reason == "" {
+		reason = "passed all filters"
+	}
+	return true, reason
+}
+
+// relativeDuration matches values such as 7d, 12h, 2w or 1.5d
+var relativeDuration = regexp.MustCompile(`^(\d+(?:\.\d+)?)([smhdw])$`)
+
+// parseTimeFilter parses an absolute date/time or a duration relative to now ("7d" = 7 days ago)
+func parseTimeFilter(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if m := relativeDuration.FindStringSubmatch(value); m != nil {
+		n, _ := strconv.ParseFloat(m[1], 64)
+		unit := map[string]time.Duration{
+			"s": time.Second,
+			"m": time.Minute,
+			"h": time.Hour,
+			"d": 24 * time.Hour,
+			"w": 7 * 24 * time.Hour,
+		}[m[2]]
+		return now.Add(-time.Duration(n * float64(unit))), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	layouts := []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a date (2006-01-02), a timestamp or a relative age like 7d: %s", value)
+}
+
+// vendorDirs are directory names holding third-party code
+var vendorDirs = map[string]bool{
+	"vendor":           true,
+	"node_modules":     true,
+	"bower_components": true,
+	"third_party":      true,
+}
+
+// generatedMarker matches the usual "do not edit" headers of generated code:
+// Go's "// Code generated ... DO NOT EDIT." line, or a comment starting with
+// @generated, auto-generated or autogenerated. The marker is submatch 1 or 2.
+var generatedMarker = regexp.MustCompile(`^// (Code generated .* DO NOT EDIT\.)$|^\s*(?://|#|/\*|\*|<!--)\s*((?i:@generated|auto-?generated)\b)`)
+
+const (
+	// generatedHeaderLines is how far into a file generated markers are searched
+	generatedHeaderLines = 50
+	// minifiedLineLength marks files with longer lines as minified
+	minifiedLineLength = 1000
+)
+
+// vendoredParent returns the first vendored directory in relPath, if any
+func vendoredParent(relPath string) string {
+	segments := strings.Split(relPath, "/")
+	for i, segment := range segments[:len(segments)-1] {
+		if vendorDirs[segment] {
+			return strings.Join(segments[:i+1], "/")
+		}
+	}
+	return ""
+}
+
+// checkContent streams a file line by line and applies -contains,
+// -not-contains and generated file detection, stopping as soon as the
+// outcome is known
+func checkContent(path string, filter *fileFilter) (bool, string) {
+	file, err := filter.opts.source().Open(path)
+	if err != nil {
+		return false, fmt.Sprintf("unreadable: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	found := filter.contains == nil
+	checkGenerated := filter.opts.ExcludeGenerated
+	var line []byte
+
+	for lineNo := 1; ; lineNo++ {
+		line = line[:0]
+		length := 0
+		var readErr error
+		for {
+			fragment, isPrefix, err := reader.ReadLine()
+			length += len(fragment)
+			// Keep at most one buffer of a very long line for matching
+			if len(line) < 64*1024 {
+				line = append(line, fragment...)
+			}
+			if err != nil {
+				readErr = err
+				break
+			}
+			if !isPrefix {
+				break
+			}
+		}
+		if readErr != nil && length == 0 {
+			break
+		}
+
+		if checkGenerated {
+			if lineNo > generatedHeaderLines {
+				checkGenerated = false
+			} else if length > minifiedLineLength {
+				return false, fmt.Sprintf("minified: line %d is %d characters long", lineNo, length)
+			} else if m := generatedMarker.FindSubmatch(bytes.TrimRight(line, "\r")); m != nil {
+				return false, fmt.Sprintf("generated: %q marker on line %d", append(m[1], m[2]...), lineNo)
+			}
+		}
+		if filter.notContains != nil && filter.notContains.Match(line) {
+			return false, fmt.Sprintf("content matches -not-contains %q on line %d", filter.notContains.String(), lineNo)
+		}
+		if !found && filter.contains.Match(line) {
+			found = true
+		}
+
+		// Stop early once nothing is left to decide
+		if found && filter.notContains == nil && !checkGenerated {
+			break
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	if !found {
+		return false, fmt.Sprintf("content does not match -contains %q", filter.contains.String())
+	}
+	return true, ""
+}
+
+// ruleMatchesFile matches a rule against a file path; directory-only rules
+// match when any parent directory of the file matches
+func ruleMatchesFile(rule filterRule, relPath string) bool {
+	if !rule.DirOnly {
+		return glob.Match(rule.Pattern, relPath)
+	}
+	for dir := filepath.ToSlash(filepath.Dir(relPath)); dir != "." && dir != "/"; dir = filepath.ToSlash(filepath.Dir(dir)) {
+		if glob.Match(rule.Pattern, dir) {
+			return true
+		}
+	}
+	return false
+}
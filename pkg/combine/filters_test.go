@@ -0,0 +1,280 @@
+package combine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"100":   100,
+		"1k":    1024,
+		"1.5MB": 1536 * 1024,
+		"2G":    2 << 30,
+	}
+	for input, expected := range cases {
+		got, err := parseSize(input)
+		if err != nil {
+			t.Errorf("parseSize(%q) returned error: %v", input, err)
+		}
+		if got != expected {
+			t.Errorf("parseSize(%q): expected %d, got %d", input, expected, got)
+		}
+	}
+
+	if _, err := parseSize("lots"); err == nil {
+		t.Error("Expected error for invalid size, got nil")
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	rules, err := parseRule("+ src/**/*.sql max-size=1M", "test")
+	if err != nil {
+		t.Fatalf("parseRule failed: %v", err)
+	}
+	if len(rules) != 1 || !rules[0].Include || rules[0].Pattern != "src/**/*.sql" || rules[0].MaxSize != 1<<20 {
+		t.Errorf("Unexpected rule: %+v", rules)
+	}
+
+	rules, err = parseRule("- build/", "test")
+	if err != nil {
+		t.Fatalf("parseRule failed: %v", err)
+	}
+	if rules[0].Include || !rules[0].DirOnly || rules[0].Pattern != "build" {
+		t.Errorf("Unexpected rule: %+v", rules[0])
+	}
+
+	if rules, _ := parseRule("# comment", "test"); len(rules) != 0 {
+		t.Errorf("Expected comment to be ignored, got %+v", rules)
+	}
+	if _, err := parseRule("* oops", "test"); err == nil {
+		t.Error("Expected error for unknown rule prefix, got nil")
+	}
+}
+
+func TestLoadRuleFile_Includes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// Includes are found next to the file including them
+	write("rules/common.rules", "- *.log\n")
+	rules, err := loadRuleFile(nil, write("rules/main.rules", ". common.rules\n+ *.go\n"))
+	if err != nil {
+		t.Fatalf("loadRuleFile failed: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Pattern != "*.log" || rules[1].Pattern != "*.go" {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+
+	// A file included twice without a cycle is fine
+	write("rules/twice.rules", ". common.rules\n. common.rules\n")
+	if _, err := loadRuleFile(nil, filepath.Join(dir, "rules/twice.rules")); err != nil {
+		t.Errorf("Expected repeated include to load, got %v", err)
+	}
+
+	write("sub/b.rules", ". ../a.rules\n")
+	for _, name := range []string{
+		write("self.rules", "+ *.go\n. self.rules\n"),
+		write("a.rules", ". sub/b.rules\n"),
+	} {
+		_, err := loadRuleFile(nil, name)
+		if err == nil || !strings.Contains(err.Error(), "includes itself") {
+			t.Errorf("Expected include cycle error for %s, got %v", filepath.Base(name), err)
+		}
+	}
+}
+
+func TestShouldProcessFile_Rules(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]int{
+		"src/main.go":      10,
+		"src/main_test.go": 10,
+		"src/dump.go":      4096,
+		"docs/readme.md":   10,
+	}
+	for name, size := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := Options{
+		Rules: []string{
+			"- **/*_test.go",
+			"+ src/**/*.go max-size=1k",
+		},
+		NoIgnore: true,
+	}
+	filter, err := newFileFilter(opts, tempDir, nil, nil)
+	if err != nil {
+		t.Fatalf("newFileFilter failed: %v", err)
+	}
+
+	expected := map[string]bool{
+		"src/main.go":      true,
+		"src/main_test.go": false,
+		"src/dump.go":      false,
+		"docs/readme.md":   false,
+	}
+	for name, want := range expected {
+		path := filepath.Join(tempDir, name)
+		info, _ := os.Stat(path)
+		got, reason := shouldProcessFile(path, 2, info, filter)
+		if got != want {
+			t.Errorf("%s: expected %v, got %v (%s)", name, want, got, reason)
+		}
+	}
+
+	// Directories are only pruned by exclude rules, never by a missing include
+	info, _ := os.Stat(filepath.Join(tempDir, "docs"))
+	if descend, _ := shouldDescend(filepath.Join(tempDir, "docs"), 1, info, filter); !descend {
+		t.Error("Expected docs/ to be walked, no exclude rule matches it")
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tempDir, "pkg", "gen"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ignore := "*.log\ngen/\n!keep.log\n"
+	if err := os.WriteFile(filepath.Join(tempDir, ".cotoignore"), []byte(ignore), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newIgnoreMatcher(fsys.OS, []string{".cotoignore"})
+	cases := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"pkg/app.log", false, true},
+		{"pkg/keep.log", false, false},
+		{"pkg/gen", true, true},
+		{"pkg/gen/x.go", false, true},
+		{"pkg/app.go", false, false},
+	}
+	for _, c := range cases {
+		got, _ := m.match(filepath.Join(tempDir, c.path), tempDir, c.isDir)
+		if got != c.ignored {
+			t.Errorf("%s: expected ignored=%v, got %v", c.path, c.ignored, got)
+		}
+	}
+}
+
+func TestCheckContent(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"gen.go":    "// Code generated by mockgen. DO NOT EDIT.\npackage mocks\n",
+		"app.js":    strings.Repeat("a=1;", 500) + "\n",
+		"pay.go":    "package pay\n\ntype PaymentService struct{}\n",
+		"plain.go":  "package plain\n",
+		"legacy.go": "package legacy\n// TODO remove\n",
+		"proto.py":  "# -*- coding: utf-8 -*-\n# @generated by protoc\n",
+		"api.ts":    "/*\n * Auto-generated from openapi.yaml\n */\n",
+		"user.go":   "package user\n\n// Token is auto-generated when the user signs up\ntype Token string\n",
+		"notes.go":  "package notes\n\n// The @generated tag marks generated files\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter, err := newFileFilter(Options{ExcludeGenerated: true, NotContains: "TODO", NoIgnore: true}, tempDir, nil, nil)
+	if err != nil {
+		t.Fatalf("newFileFilter failed: %v", err)
+	}
+	expected := map[string]bool{
+		"gen.go":    false,
+		"app.js":    false,
+		"pay.go":    true,
+		"plain.go":  true,
+		"legacy.go": false,
+		"proto.py":  false,
+		"api.ts":    false,
+		"user.go":   true,
+		"notes.go":  true,
+	}
+	for name, want := range expected {
+		if got, reason := checkContent(filepath.Join(tempDir, name), filter); got != want {
+			t.Errorf("%s: expected %v, got %v (%s)", name, want, got, reason)
+		}
+	}
+
+	filter, _ = newFileFilter(Options{Contains: `Payment\w+`, NoIgnore: true}, tempDir, nil, nil)
+	if ok, _ := checkContent(filepath.Join(tempDir, "pay.go"), filter); !ok {
+		t.Error("Expected pay.go to match -contains")
+	}
+	if ok, _ := checkContent(filepath.Join(tempDir, "plain.go"), filter); ok {
+		t.Error("Expected plain.go not to match -contains")
+	}
+}
+
+func TestVendoredParent(t *testing.T) {
+	if got := vendoredParent("a/vendor/b/c.go"); got != "a/vendor" {
+		t.Errorf("Expected a/vendor, got %q", got)
+	}
+	if got := vendoredParent("src/vendors.go"); got != "" {
+		t.Errorf("Expected no vendored parent, got %q", got)
+	}
+}
+
+func TestParseTimeFilter(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+	cases := map[string]time.Time{
+		"7d":         now.Add(-7 * 24 * time.Hour),
+		"2w":         now.Add(-14 * 24 * time.Hour),
+		"90m":        now.Add(-90 * time.Minute),
+		"1h30m":      now.Add(-90 * time.Minute),
+		"2024-05-01": time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+	}
+	for input, expected := range cases {
+		got, err := parseTimeFilter(input, now)
+		if err != nil {
+			t.Errorf("parseTimeFilter(%q) returned error: %v", input, err)
+			continue
+		}
+		if !got.Equal(expected) {
+			t.Errorf("parseTimeFilter(%q): expected %v, got %v", input, expected, got)
+		}
+	}
+
+	if _, err := parseTimeFilter("last tuesday", now); err == nil {
+		t.Error("Expected error for unsupported time value, got nil")
+	}
+}
+
+func TestNewestFiles(t *testing.T) {
+	base := time.Now()
+	paths := []string{"a.go", "b.go", "c.go", "d.go"}
+	modTimes := map[string]time.Time{
+		"a.go": base.Add(-4 * time.Hour),
+		"b.go": base.Add(-1 * time.Hour),
+		"c.go": base.Add(-3 * time.Hour),
+		"d.go": base.Add(-2 * time.Hour),
+	}
+
+	got := newestFiles(paths, modTimes, 2)
+	if len(got) != 2 || got[0] != "b.go" || got[1] != "d.go" {
+		t.Errorf("Expected [b.go d.go] in walk order, got %v", got)
+	}
+}
@@ -0,0 +1,421 @@
+package combine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/fsys"
+	"github.com/bhangun/coto/pkg/glob"
+)
+
+// inputRoot is one source of files: a directory, a single file or a glob pattern
+type inputRoot struct {
+	Path    string      // directory or file path, or the static base of a glob
+	Pattern string      // full glob pattern, empty for plain paths
+	Anchor  string      // directory relative paths are computed from
+	Ranges  []LineRange // line ranges requested with "file:120-200"
+}
+
+// Collection is the set of files selected by Collect
+type Collection struct {
+	Paths   []string
+	BaseDir string                 // relative paths in the bundle start here
+	Ranges  map[string][]LineRange // requested line ranges keyed by path
+	Stats   Stats                  // directories walked and links skipped
+
+	started time.Time
+}
+
+// Roots returns the configured input paths, defaulting to the current directory
+func (o Options) Roots() []string {
+	if len(o.InputDirs) > 0 {
+		return o.InputDirs
+	}
+	if o.InputDir != "" {
+		return []string{o.InputDir}
+	}
+	if o.FilesFrom != "" {
+		return nil
+	}
+	return []string{"."}
+}
+
+// resolveInputRoots turns input arguments into roots and validates plain paths
+func resolveInputRoots(src fsys.FS, inputs []string) ([]inputRoot, error) {
+	var roots []inputRoot
+	for _, input := range inputs {
+		if glob.HasMeta(input) {
+			pattern := filepath.ToSlash(filepath.Clean(input))
+			if err := glob.Validate(pattern); err != nil {
+				return nil, &InputError{Path: input, Err: fmt.Errorf("invalid glob pattern %s: %v", input, err)}
+			}
+			base, _ := glob.Split(pattern)
+			base = filepath.FromSlash(base)
+			roots = append(roots, inputRoot{Path: base, Pattern: pattern, Anchor: base})
+			continue
+		}
+
+		path, ranges, _ := splitRangeSpec(src, input)
+		info, err := src.Stat(path)
+		if err != nil {
+			return nil, &InputError{Path: input, Err: err}
+		}
+		anchor := path
+		if !info.IsDir() {
+			anchor = filepath.Dir(path)
+		}
+		roots = append(roots, inputRoot{Path: path, Anchor: anchor, Ranges: ranges})
+	}
+	return roots, nil
+}
+
+// readFileList reads paths from a file of src (or the process stdin for "-"),
+// one per line or NUL-separated
+func readFileList(src fsys.FS, source string, nullSeparated bool) ([]string, error) {
+	var reader io.Reader
+	if source == "-" {
+		reader = os.Stdin
+	} else {
+		file, err := src.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file list: %v", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if nullSeparated {
+		scanner.Split(scanNull)
+	}
+
+	var paths []string
+	for scanner.Scan() {
+		entry := scanner.Text()
+		if !nullSeparated {
+			entry = strings.TrimSpace(entry)
+		}
+		if entry == "" {
+			continue
+		}
+		paths = append(paths, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file list: %v", err)
+	}
+	return paths, nil
+}
+
+// scanNull is a bufio.SplitFunc for NUL-terminated records
+func scanNull(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// commonBaseDir returns the directory relative paths are computed from.
+// A single anchor is used as-is; several anchors resolve to their deepest
+// common ancestor so that paths from different roots cannot collide.
+func commonBaseDir(anchors []string) string {
+	unique := make(map[string]bool)
+	var distinct []string
+	for _, anchor := range anchors {
+		abs, err := filepath.Abs(anchor)
+		if err != nil {
+			abs = anchor
+		}
+		if !unique[abs] {
+			unique[abs] = true
+			distinct = append(distinct, anchor)
+		}
+	}
+
+	switch len(distinct) {
+	case 0:
+		return "."
+	case 1:
+		return distinct[0]
+	}
+
+	allRelative := true
+	var common []string
+	for i, anchor := range distinct {
+		if filepath.IsAbs(anchor) {
+			allRelative = false
+		}
+		abs, _ := filepath.Abs(anchor)
+		parts := strings.Split(abs, string(filepath.Separator))
+		if i == 0 {
+			common = parts
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	base := strings.Join(common, string(filepath.Separator))
+	if base == "" || filepath.VolumeName(base) == base {
+		base += string(filepath.Separator)
+	}
+	if allRelative {
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, base); err == nil {
+				return rel
+			}
+		}
+	}
+	return base
+}
+
+// Collect walks every input root and file list entry, applies the filters
+// and returns the matching paths together with their common base. Every
+// path looked at is reported to opts.OnEvent as included or skipped.
+func Collect(ctx context.Context, opts Options) (*Collection, error) {
+	excludeRegex, includeRegex, err := opts.patterns()
+	if err != nil {
+		return nil, err
+	}
+	src := opts.source()
+	roots, err := resolveInputRoots(src, opts.Roots())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Collection{Ranges: make(map[string][]LineRange), started: time.Now()}
+	stats := &result.Stats
+	explain := func(path string, isDir, included bool, reason string) {
+		kind := event.Skipped
+		if included {
+			kind = event.Included
+		}
+		opts.OnEvent.Emit(event.Event{Kind: kind, Path: getRelativePath(path, result.BaseDir), Dir: isDir, Reason: reason})
+	}
+
+	var listed []string
+	if opts.FilesFrom != "" {
+		entries, err := readFileList(src, opts.FilesFrom, opts.NullSeparated)
+		if err != nil {
+			return nil, &InputError{Path: opts.FilesFrom, Err: err}
+		}
+		// Entries may carry a line range such as "main.go:120-200"
+		for _, entry := range entries {
+			path, ranges, ok := splitRangeSpec(src, entry)
+			if ok {
+				result.Ranges[path] = append(result.Ranges[path], ranges...)
+			}
+			listed = append(listed, path)
+		}
+	}
+	for _, root := range roots {
+		if len(root.Ranges) > 0 {
+			result.Ranges[root.Path] = append(result.Ranges[root.Path], root.Ranges...)
+		}
+	}
+
+	var anchors []string
+	for _, root := range roots {
+		anchors = append(anchors, root.Anchor)
+	}
+	for _, path := range listed {
+		if filepath.IsAbs(path) {
+			anchors = append(anchors, filepath.Dir(path))
+		} else {
+			anchors = append(anchors, ".")
+		}
+	}
+	baseDir := commonBaseDir(anchors)
+	result.BaseDir = baseDir
+
+	filter, err := newFileFilter(opts, baseDir, excludeRegex, includeRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	var filePaths []string
+	seen := make(map[string]bool)
+	identities := make(map[fileKey]string)
+	modTimes := make(map[string]time.Time)
+	add := func(path string, depth int, info fs.FileInfo) {
+		key, err := filepath.Abs(path)
+		if err != nil {
+			key = path
+		}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if opts.DedupeLinks {
+			// The same file reached through another symlink or hard link
+			if id, ok := identity(src, path, info); ok {
+				if first, dup := identities[id]; dup {
+					explain(path, false, false, "same file as "+getRelativePath(first, baseDir))
+					return
+				}
+				identities[id] = path
+			}
+		}
+		include, reason := shouldProcessFile(path, depth, info, filter)
+		explain(path, false, include, reason)
+		if include {
+			filePaths = append(filePaths, path)
+			modTimes[path] = info.ModTime()
+		}
+	}
+
+	for _, root := range roots {
+		err := walkTree(src, root.Path, opts.FollowSymlinks, func(path string, info fs.FileInfo, err error) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var linkErr *linkError
+			if errors.As(err, &linkErr) {
+				reportLink(linkErr, opts.OnEvent, stats)
+				return nil
+			}
+			if err != nil {
+				stats.Errors++
+				opts.OnEvent.Emit(event.Event{Kind: event.Error, Path: path, Reason: "accessing", Err: err})
+				return nil
+			}
+
+			if info.IsDir() {
+				stats.Directories++
+				if path == root.Path {
+					return nil
+				}
+				if descend, reason := shouldDescend(path, pathDepth(root.Path, path), info, filter); !descend {
+					explain(path, true, false, reason)
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if info.Mode()&fs.ModeSymlink != 0 {
+				// A symlinked directory while -follow-symlinks is off
+				explain(path, true, false, "symlinked directory (use -follow-symlinks)")
+				return nil
+			}
+
+			if root.Pattern != "" && !glob.Match(root.Pattern, filepath.ToSlash(path)) {
+				return nil
+			}
+
+			add(path, pathDepth(root.Path, path), info)
+			return nil
+		})
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, &InputError{Path: root.Path, Err: fmt.Errorf("error walking %s: %w", root.Path, err)}
+		}
+	}
+
+	for _, path := range listed {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		info, err := src.Stat(path)
+		if err != nil {
+			if linkErr := brokenLink(src, path); linkErr != nil {
+				reportLink(linkErr, opts.OnEvent, stats)
+			} else {
+				stats.Errors++
+				opts.OnEvent.Emit(event.Event{Kind: event.Error, Path: path, Reason: "accessing", Err: err})
+			}
+			continue
+		}
+		if info.IsDir() {
+			continue
+		}
+		add(path, pathDepth(baseDir, path), info)
+	}
+
+	if opts.Newest > 0 && len(filePaths) > opts.Newest {
+		kept := newestFiles(filePaths, modTimes, opts.Newest)
+		keep := make(map[string]bool, len(kept))
+		for _, path := range kept {
+			keep[path] = true
+		}
+		for _, path := range filePaths {
+			if !keep[path] {
+				explain(path, false, false, fmt.Sprintf("not among the %d newest files", opts.Newest))
+			}
+		}
+		filePaths = kept
+	}
+
+	result.Paths = filePaths
+	return result, nil
+}
+
+// Reasons of the warnings sent for skipped symlinks
+const (
+	ReasonBrokenLink   = "broken symlink"
+	ReasonSymlinkCycle = "symlink cycle"
+)
+
+// reportLink records a broken or cyclic symlink and reports it as a warning
+func reportLink(err *linkError, onEvent event.Handler, stats *Stats) {
+	reason := ReasonBrokenLink
+	if err.Cycle {
+		stats.SymlinkCycles++
+		reason = ReasonSymlinkCycle
+	} else {
+		stats.BrokenLinks++
+	}
+	onEvent.Emit(event.Event{Kind: event.Warning, Path: err.Path, Target: err.Target, Reason: reason})
+}
+
+// pathDepth returns how many levels path is below root (a direct child has depth 1)
+func pathDepth(root, path string) int {
+	rel, err := relativeTo(root, path)
+	if err != nil || rel == "." {
+		return 1
+	}
+	return strings.Count(filepath.ToSlash(rel), "/") + 1
+}
+
+// newestFiles keeps the n most recently modified paths, preserving walk order
+func newestFiles(paths []string, modTimes map[string]time.Time, n int) []string {
+	byAge := make([]string, len(paths))
+	copy(byAge, paths)
+	sort.SliceStable(byAge, func(i, j int) bool {
+		return modTimes[byAge[i]].After(modTimes[byAge[j]])
+	})
+
+	keep := make(map[string]bool, n)
+	for _, path := range byAge[:n] {
+		keep[path] = true
+	}
+
+	var result []string
+	for _, path := range paths {
+		if keep[path] {
+			result = append(result, path)
+		}
+	}
+	return result
+}
@@ -0,0 +1,224 @@
+package combine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+// Truncation strategies for -max-lines
+const (
+	truncateHead     = "head"
+	truncateTail     = "tail"
+	truncateHeadTail = "head+tail"
+)
+
+// LineRange is an inclusive, 1-based range of lines; End 0 means end of file
+type LineRange struct {
+	Start int
+	End   int
+}
+
+func (r LineRange) String() string {
+	switch {
+	case r.End == r.Start:
+		return strconv.Itoa(r.Start)
+	case r.End == 0:
+		return fmt.Sprintf("%d-", r.Start)
+	default:
+		return fmt.Sprintf("%d-%d", r.Start, r.End)
+	}
+}
+
+// contentOptions controls which lines of each file end up in the bundle
+type contentOptions struct {
+	MaxLines int
+	Truncate string
+	Ranges   map[string][]LineRange // requested line ranges keyed by path
+	Checksum bool                   // record the SHA-256 of the whole file
+}
+
+// rangeSuffix matches a trailing ":120-200" or ":10-20,40-" selection
+var rangeSuffix = regexp.MustCompile(`^(.+):(\d+(?:-\d*)?(?:,\d+(?:-\d*)?)*)$`)
+
+// normalizeTruncate validates a -truncate value
+func normalizeTruncate(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", truncateHead:
+		return truncateHead, nil
+	case truncateTail:
+		return truncateTail, nil
+	case truncateHeadTail, "head-tail", "headtail":
+		return truncateHeadTail, nil
+	default:
+		return "", fmt.Errorf("invalid truncate mode: %s (expected head, tail or head+tail)", mode)
+	}
+}
+
+// parseLineRanges parses "120-200", "15", "300-" or comma-separated lists of them
+func parseLineRanges(spec string) ([]LineRange, error) {
+	var ranges []LineRange
+	for _, part := range strings.Split(spec, ",") {
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(startStr)
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("invalid line range: %s", part)
+		}
+
+		r := LineRange{Start: start, End: start}
+		if isRange {
+			r.End = 0
+			if endStr != "" {
+				if r.End, err = strconv.Atoi(endStr); err != nil || r.End < start {
+					return nil, fmt.Errorf("invalid line range: %s", part)
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// splitRangeSpec splits "path/file.go:120-200" into the path and its ranges.
+// It only succeeds when the argument itself does not exist but the stripped
+// path is a regular file, so names containing colons keep working.
+func splitRangeSpec(src fsys.FS, arg string) (string, []LineRange, bool) {
+	if _, err := src.Stat(arg); err == nil {
+		return arg, nil, false
+	}
+	m := rangeSuffix.FindStringSubmatch(arg)
+	if m == nil {
+		return arg, nil, false
+	}
+	info, err := src.Stat(m[1])
+	if err != nil || info.IsDir() {
+		return arg, nil, false
+	}
+	ranges, err := parseLineRanges(m[2])
+	if err != nil {
+		return arg, nil, false
+	}
+	return m[1], ranges, true
+}
+
+// splitLines splits content into lines, ignoring a final trailing newline
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// omittedMarker is the line inserted where content was left out
+func omittedMarker(n int) string {
+	if n == 1 {
+		return "[... 1 line omitted ...]"
+	}
+	return fmt.Sprintf("[... %s lines omitted ...]", FormatCount(n))
+}
+
+// FormatCount formats n with thousands separators
+func FormatCount(n int) string {
+	s := strconv.Itoa(n)
+	if len(s) <= 3 {
+		return s
+	}
+	var b strings.Builder
+	lead := len(s) % 3
+	if lead > 0 {
+		b.WriteString(s[:lead])
+	}
+	for i := lead; i < len(s); i += 3 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(s[i : i+3])
+	}
+	return b.String()
+}
+
+// applyLineSelection restricts info.Content to the requested ranges or the
+// -max-lines budget and records what was kept in the FileInfo metadata
+func applyLineSelection(info *FileInfo, ranges []LineRange, opts contentOptions) {
+	if len(ranges) == 0 && opts.MaxLines <= 0 {
+		return
+	}
+
+	lines := splitLines(info.Content)
+	total := len(lines)
+	info.Lines = total
+
+	var keep []LineRange
+	if len(ranges) > 0 {
+		// Explicit ranges win over truncation
+		for _, r := range ranges {
+			end := r.End
+			if end == 0 || end > total {
+				end = total
+			}
+			if r.Start <= end {
+				keep = append(keep, LineRange{Start: r.Start, End: end})
+			}
+		}
+		sort.Slice(keep, func(i, j int) bool { return keep[i].Start < keep[j].Start })
+	} else {
+		if total <= opts.MaxLines {
+			return
+		}
+		switch opts.Truncate {
+		case truncateTail:
+			keep = []LineRange{{Start: total - opts.MaxLines + 1, End: total}}
+		case truncateHeadTail:
+			head := (opts.MaxLines + 1) / 2
+			tail := opts.MaxLines - head
+			keep = []LineRange{{Start: 1, End: head}}
+			if tail > 0 {
+				keep = append(keep, LineRange{Start: total - tail + 1, End: total})
+			}
+		default:
+			keep = []LineRange{{Start: 1, End: opts.MaxLines}}
+		}
+	}
+
+	var out []string
+	var described []string
+	next := 1
+	kept := 0
+	for _, r := range keep {
+		if r.Start < next {
+			r.Start = next
+		}
+		if r.Start > r.End {
+			continue
+		}
+		if gap := r.Start - next; gap > 0 {
+			out = append(out, omittedMarker(gap))
+		}
+		out = append(out, lines[r.Start-1:r.End]...)
+		described = append(described, r.String())
+		kept += r.End - r.Start + 1
+		next = r.End + 1
+	}
+	if gap := total - next + 1; gap > 0 {
+		out = append(out, omittedMarker(gap))
+	}
+
+	info.Content = strings.Join(out, "\n")
+	if kept < total {
+		info.Partial = true
+		info.LineRanges = strings.Join(described, ",")
+		info.OmittedLines = total - kept
+	}
+}
+
+// describeLines summarizes partial content for the text and markdown headers
+func describeLines(info FileInfo) string {
+	if !info.Partial {
+		return ""
+	}
+	return fmt.Sprintf("lines %s of %s (%s omitted)", info.LineRanges, FormatCount(info.Lines), FormatCount(info.OmittedLines))
+}
@@ -0,0 +1,74 @@
+package combine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLineRanges(t *testing.T) {
+	ranges, err := parseLineRanges("120-200,15,300-")
+	if err != nil {
+		t.Fatalf("parseLineRanges failed: %v", err)
+	}
+	expected := []LineRange{{120, 200}, {15, 15}, {300, 0}}
+	if len(ranges) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, ranges)
+	}
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Errorf("Range %d: expected %v, got %v", i, expected[i], ranges[i])
+		}
+	}
+
+	for _, spec := range []string{"0-5", "20-10", "a-b", ""} {
+		if _, err := parseLineRanges(spec); err == nil {
+			t.Errorf("Expected error for %q, got nil", spec)
+		}
+	}
+}
+
+func TestApplyLineSelection(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, "line")
+	}
+	content := strings.Join(lines, "\n") + "\n"
+
+	info := FileInfo{Content: content}
+	applyLineSelection(&info, nil, contentOptions{MaxLines: 4, Truncate: truncateHeadTail})
+	if !info.Partial || info.LineRanges != "1-2,9-10" || info.OmittedLines != 6 || info.Lines != 10 {
+		t.Errorf("Unexpected head+tail metadata: %+v", info)
+	}
+	if !strings.Contains(info.Content, "[... 6 lines omitted ...]") {
+		t.Errorf("Expected omitted marker, got %q", info.Content)
+	}
+
+	info = FileInfo{Content: content}
+	applyLineSelection(&info, nil, contentOptions{MaxLines: 3, Truncate: truncateTail})
+	if info.LineRanges != "8-10" || !strings.HasPrefix(info.Content, "[... 7 lines omitted ...]") {
+		t.Errorf("Unexpected tail result: %+v", info)
+	}
+
+	// Explicit ranges override -max-lines and are clamped to the file
+	info = FileInfo{Content: content}
+	applyLineSelection(&info, []LineRange{{9, 0}, {2, 3}}, contentOptions{MaxLines: 1})
+	if info.LineRanges != "2-3,9-10" || info.OmittedLines != 6 {
+		t.Errorf("Unexpected range result: %+v", info)
+	}
+
+	// Short files are left untouched
+	info = FileInfo{Content: content}
+	applyLineSelection(&info, nil, contentOptions{MaxLines: 50})
+	if info.Partial || info.Content != content {
+		t.Errorf("Expected file to be kept whole, got %+v", info)
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	cases := map[int]string{7: "7", 999: "999", 1245: "1,245", 1234567: "1,234,567"}
+	for n, expected := range cases {
+		if got := FormatCount(n); got != expected {
+			t.Errorf("FormatCount(%d): expected %s, got %s", n, expected, got)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package combine
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bhangun/coto/pkg/compression"
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/signing"
+)
+
+// WriteOptions controls how a bundle is encoded
+type WriteOptions struct {
+	Format            string // text, json, xml or markdown
+	Codec             compression.Codec
+	Level             int                // compression level, 0 for the codec default
+	SignKey           ed25519.PrivateKey // sign the bundle when set
+	DetachedSignature bool               // write <output>.sig instead of a trailer
+	Passphrase        []byte             // encrypt when set
+	Prompt            Prompt             // instructions and question around the files
+}
+
+// WriteFile writes the bundle to a temp file next to outputPath and renames
+// it into place, so a failed or canceled run never leaves a truncated bundle
+// behind. It returns the size of the file.
+func WriteFile(ctx context.Context, fileInfos []FileInfo, outputPath string, opts WriteOptions, stats Stats) (int64, error) {
+	file, err := os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	written, sig, err := WriteBundle(&ctxWriter{ctx: ctx, w: file}, fileInfos, opts, stats)
+	if err != nil {
+		return written, err
+	}
+	if err := ctx.Err(); err != nil {
+		return written, err
+	}
+
+	// Report the size on disk rather than the uncompressed byte count
+	if opts.Codec != compression.None || opts.Passphrase != nil {
+		if info, err := file.Stat(); err == nil {
+			written = info.Size()
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return written, err
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return written, err
+	}
+	if err := os.Rename(file.Name(), outputPath); err != nil {
+		return written, err
+	}
+	committed = true
+
+	if sig != nil {
+		return written, os.WriteFile(outputPath+signing.SignatureExtension, signing.EncodeSignatureFile(*sig), 0644)
+	}
+	return written, nil
+}
+
+// ctxWriter fails writes once its context has ended
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *ctxWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
+
+// WriteBundle encodes fileInfos to w, compressing and then encrypting it as
+// opts asks. With a detached signature the signature is returned instead of
+// embedded. The returned size is the uncompressed bundle size.
+func WriteBundle(w io.Writer, fileInfos []FileInfo, opts WriteOptions, stats Stats) (int64, *signing.Signature, error) {
+	// Encryption wraps the compressed stream
+	var encrypter *encryption.Writer
+	if opts.Passphrase != nil {
+		var err error
+		if encrypter, err = encryption.NewWriter(w, opts.Passphrase); err != nil {
+			return 0, nil, err
+		}
+		w = encrypter
+	}
+
+	// Add compression if requested
+	compressor, err := compression.NewWriter(w, opts.Codec, opts.Level)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// The signature covers the uncompressed bundle
+	var writer io.Writer = compressor
+	var hasher *signing.Signer
+	if opts.SignKey != nil {
+		hasher = signing.NewSigner(opts.SignKey)
+		writer = io.MultiWriter(compressor, hasher)
+	}
+
+	// Write based on format
+	var written int64
+	prompt := opts.Prompt.Expand(fileInfos, stats)
+	switch strings.ToLower(opts.Format) {
+	case "json":
+		written, err = writeJSONOutput(fileInfos, writer, stats, prompt)
+	case "xml":
+		written, err = writeXMLOutput(fileInfos, writer, stats, prompt)
+	case "markdown", "md":
+		written, err = writeMarkdownOutput(fileInfos, writer, stats, prompt)
+	default: // text
+		written, err = writeTextOutput(fileInfos, writer, stats, prompt)
+	}
+	if err != nil {
+		compressor.Close()
+		return written, nil, err
+	}
+
+	var detached *signing.Signature
+	if hasher != nil {
+		sig, err := hasher.Sign()
+		if err != nil {
+			compressor.Close()
+			return written, nil, err
+		}
+		if opts.DetachedSignature {
+			detached = &sig
+		} else {
+			n, err := io.WriteString(compressor, signing.Trailer(sig))
+			written += int64(n)
+			if err != nil {
+				compressor.Close()
+				return written, nil, err
+			}
+		}
+	}
+
+	if err := compressor.Close(); err != nil {
+		return written, nil, err
+	}
+	if encrypter != nil {
+		if err := encrypter.Close(); err != nil {
+			return written, nil, err
+		}
+	}
+	return written, detached, nil
+}
+
+func writeTextOutput(fileInfos []FileInfo, writer io.Writer, stats Stats, prompt Prompt) (int64, error) {
+	totalBytes := int64(0)
+	bufWriter := bufio.NewWriter(writer)
+
+	header := fmt.Sprintf("Coto Output\n")
+	header += fmt.Sprintf("Generated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	header += fmt.Sprintf("Files: %d | Directories: %d | Total Size: %s\n",
+		stats.FilesProcessed, stats.Directories, FormatBytes(stats.TotalBytes))
+	if stats.RootHash != "" {
+		header += fmt.Sprintf("Root SHA-256: %s\n", stats.RootHash)
+	}
+	// The prompt follows the title lines, which mark the file as a bundle
+	header += "\n" + section(prompt.Header)
+
+	n, _ := bufWriter.WriteString(header)
+	totalBytes += int64(n)
+
+	for _, info := range fileInfos {
+		section := fmt.Sprintf("\n%s\n%s\n", strings.Repeat("=", 80), info.RelativePath)
+		section += fmt.Sprintf("Size: %s | Modified: %s", FormatBytes(info.Size), info.Modified)
+		if lines := describeLines(info); lines != "" {
+			section += " | Partial: " + lines
+		}
+		section += "\n"
+		if len(info.Aliases) > 0 {
+			section += fmt.Sprintf("Aliases: %s\n", strings.Join(info.Aliases, ", "))
+		}
+		if info.SHA256 != "" {
+			section += fmt.Sprintf("SHA-256: %s\n", info.SHA256)
+		}
+		section += fmt.Sprintf("%s\n", strings.Repeat("-", 80))
+		section += info.Content + "\n"
+		section += fmt.Sprintf("%s\n", strings.Repeat("=", 80))
+
+		n, _ := bufWriter.WriteString(section)
+		totalBytes += int64(n)
+	}
+
+	footer := fmt.Sprintf("\n\n=== SUMMARY ===\n")
+	footer += fmt.Sprintf("Files processed: %d\n", stats.FilesProcessed)
+	footer += fmt.Sprintf("Directories scanned: %d\n", stats.Directories)
+	footer += fmt.Sprintf("Total input size: %s\n", FormatBytes(stats.TotalBytes))
+	if stats.DuplicateFiles > 0 {
+		footer += fmt.Sprintf("Duplicates: %d (%s saved)\n", stats.DuplicateFiles, FormatBytes(stats.BytesSaved))
+	}
+	footer += fmt.Sprintf("Output size: %s\n", FormatBytes(totalBytes))
+	footer += fmt.Sprintf("Processing time: %.2f seconds\n", stats.Duration)
+	if prompt.Footer != "" {
+		footer += "\n" + prompt.Footer + "\n"
+	}
+
+	n, _ = bufWriter.WriteString(footer)
+	totalBytes += int64(n)
+
+	return totalBytes, bufWriter.Flush()
+}
+
+func writeJSONOutput(fileInfos []FileInfo, writer io.Writer, stats Stats, prompt Prompt) (int64, error) {
+	metadata := map[string]interface{}{
+		"generated":     time.Now().Format(time.RFC3339),
+		"version":       Version,
+		"files_count":   stats.FilesProcessed,
+		"directories":   stats.Directories,
+		"total_size":    stats.TotalBytes,
+		"duration_secs": stats.Duration,
+	}
+	if stats.DuplicateFiles > 0 {
+		metadata["duplicate_files"] = stats.DuplicateFiles
+		metadata["bytes_saved"] = stats.BytesSaved
+	}
+	if stats.RootHash != "" {
+		metadata["root_sha256"] = stats.RootHash
+	}
+	if prompt.Header != "" {
+		metadata["prompt_header"] = prompt.Header
+	}
+	if prompt.Footer != "" {
+		metadata["prompt_footer"] = prompt.Footer
+	}
+	// Metadata goes first so the bundle header is recognizable
+	output := struct {
+		Metadata map[string]interface{} `json:"metadata"`
+		Files    []FileInfo             `json:"files"`
+	}{metadata, fileInfos}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(output)
+	if err != nil {
+		return 0, err
+	}
+
+	// Estimate size (not exact but good enough)
+	data, _ := json.Marshal(output)
+	return int64(len(data)), nil
+}
+
+func writeXMLOutput(fileInfos []FileInfo, writer io.Writer, stats Stats, prompt Prompt) (int64, error) {
+	type XMLOutput struct {
+		XMLName   xml.Name `xml:"filecombiner_output"`
+		Version   string   `xml:"version,attr"`
+		Generated string   `xml:"generated,attr"`
+		Metadata  struct {
+			Files       int     `xml:"files"`
+			Directories int     `xml:"directories"`
+			TotalSize   int64   `xml:"total_size"`
+			Duration    float64 `xml:"duration_seconds"`
+			Duplicates  int     `xml:"duplicate_files,omitempty"`
+			BytesSaved  int64   `xml:"bytes_saved,omitempty"`
+			RootHash    string  `xml:"root_sha256,omitempty"`
+			Header      string  `xml:"prompt_header,omitempty"`
+			Footer      string  `xml:"prompt_footer,omitempty"`
+		} `xml:"metadata"`
+		Files []FileInfo `xml:"file"`
+	}
+
+	output := XMLOutput{
+		Version:   Version,
+		Generated: time.Now().Format(time.RFC3339),
+	}
+	output.Metadata.Files = stats.FilesProcessed
+	output.Metadata.Directories = stats.Directories
+	output.Metadata.TotalSize = stats.TotalBytes
+	output.Metadata.Duration = stats.Duration
+	output.Metadata.Duplicates = stats.DuplicateFiles
+	output.Metadata.BytesSaved = stats.BytesSaved
+	output.Metadata.RootHash = stats.RootHash
+	output.Metadata.Header = prompt.Header
+	output.Metadata.Footer = prompt.Footer
+	output.Files = fileInfos
+
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+
+	// Write XML header
+	writer.Write([]byte(xml.Header))
+
+	err := encoder.Encode(output)
+	if err != nil {
+		return 0, err
+	}
+
+	// Estimate size
+	data, _ := xml.MarshalIndent(output, "", "  ")
+	return int64(len(data) + len(xml.Header)), nil
+}
+
+func writeMarkdownOutput(fileInfos []FileInfo, writer io.Writer, stats Stats, prompt Prompt) (int64, error) {
+	totalBytes := int64(0)
+	bufWriter := bufio.NewWriter(writer)
+
+	header := fmt.Sprintf("# Coto Output\n\n")
+	header += fmt.Sprintf("**Generated**: %s  \n", time.Now().Format("2006-01-02 15:04:05"))
+	header += fmt.Sprintf("**Files**: %d | **Directories**: %d | **Total Size**: %s  \n",
+		stats.FilesProcessed, stats.Directories, FormatBytes(stats.TotalBytes))
+	if stats.RootHash != "" {
+		header += fmt.Sprintf("**Root SHA-256**: `%s`  \n", stats.RootHash)
+	}
+	header += "\n" + section(prompt.Header)
+
+	n, _ := bufWriter.WriteString(header)
+	totalBytes += int64(n)
+
+	for i, info := range fileInfos {
+		section := fmt.Sprintf("## File %d: `%s`\n\n", i+1, info.RelativePath)
+		section += fmt.Sprintf("**Size**: %s  \n", FormatBytes(info.Size))
+		section += fmt.Sprintf("**Modified**: %s  \n", info.Modified)
+		if lines := describeLines(info); lines != "" {
+			section += fmt.Sprintf("**Partial**: %s  \n", lines)
+		}
+		if len(info.Aliases) > 0 {
+			section += fmt.Sprintf("**Aliases**: `%s`  \n", strings.Join(info.Aliases, "`, `"))
+		}
+		if info.SHA256 != "" {
+			section += fmt.Sprintf("**SHA-256**: `%s`  \n", info.SHA256)
+		}
+		section += "\n"
+		section += "### Content\n```\n"
+		section += info.Content + "\n```\n\n"
+		section += "---\n\n"
+
+		n, _ := bufWriter.WriteString(section)
+		totalBytes += int64(n)
+	}
+
+	footer := fmt.Sprintf("## Summary\n\n")
+	footer += fmt.Sprintf("- **Files processed**: %d\n", stats.FilesProcessed)
+	footer += fmt.Sprintf("- **Directories scanned**: %d\n", stats.Directories)
+	footer += fmt.Sprintf("- **Total input size**: %s\n", FormatBytes(stats.TotalBytes))
+	if stats.DuplicateFiles > 0 {
+		footer += fmt.Sprintf("- **Duplicates**: %d (%s saved)\n", stats.DuplicateFiles, FormatBytes(stats.BytesSaved))
+	}
+	footer += fmt.Sprintf("- **Processing time**: %.2f seconds\n", stats.Duration)
+	if prompt.Footer != "" {
+		footer += "\n" + prompt.Footer + "\n"
+	}
+
+	n, _ = bufWriter.WriteString(footer)
+	totalBytes += int64(n)
+
+	return totalBytes, bufWriter.Flush()
+}
+
+// FormatBytes renders a byte count with a binary unit, such as "1.5 KB"
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
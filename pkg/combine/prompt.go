@@ -0,0 +1,96 @@
+package combine
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prompt wraps a bundle for a language model: instructions written before
+// the files and a footer, usually a question, written after them. Variables
+// such as {{files}} are expanded when the bundle is written.
+type Prompt struct {
+	Header  string
+	Footer  string
+	Project string // name {{project}} expands to
+}
+
+// promptVariable matches {{name}}, with optional spaces inside the braces
+var promptVariable = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// LoadPrompt reads the prompt header and footer files of opts from opts.FS
+// and appends opts.Prompt to the footer. The project is named after baseDir.
+func LoadPrompt(opts Options, baseDir string) (Prompt, error) {
+	var p Prompt
+	if opts.PromptHeader != "" {
+		data, err := opts.source().ReadFile(opts.PromptHeader)
+		if err != nil {
+			return p, &OptionError{Option: "prompt-header", Err: fmt.Errorf("cannot read prompt header: %v", err)}
+		}
+		p.Header = strings.TrimSpace(string(data))
+	}
+	if opts.PromptFooter != "" {
+		data, err := opts.source().ReadFile(opts.PromptFooter)
+		if err != nil {
+			return p, &OptionError{Option: "prompt-footer", Err: fmt.Errorf("cannot read prompt footer: %v", err)}
+		}
+		p.Footer = strings.TrimSpace(string(data))
+	}
+	if text := strings.TrimSpace(opts.Prompt); text != "" {
+		if p.Footer != "" {
+			p.Footer += "\n\n"
+		}
+		p.Footer += text
+	}
+	if abs, err := filepath.Abs(baseDir); err == nil {
+		p.Project = filepath.Base(abs)
+	}
+	return p, nil
+}
+
+// Empty reports whether there is nothing to write
+func (p Prompt) Empty() bool {
+	return p.Header == "" && p.Footer == ""
+}
+
+// Expand returns the prompt with its variables replaced by the figures of
+// the bundle. Unknown variables are left as they are.
+func (p Prompt) Expand(fileInfos []FileInfo, stats Stats) Prompt {
+	if p.Empty() {
+		return p
+	}
+	tokens := 0
+	for _, info := range fileInfos {
+		tokens += (len(info.Content) + 3) / 4 // same estimate as coto stats
+	}
+	values := map[string]string{
+		"files":       strconv.Itoa(stats.FilesProcessed),
+		"directories": strconv.Itoa(stats.Directories),
+		"size":        FormatBytes(stats.TotalBytes),
+		"tokens":      FormatCount(tokens),
+		"project":     p.Project,
+		"date":        time.Now().Format("2006-01-02"),
+	}
+	expand := func(s string) string {
+		return promptVariable.ReplaceAllStringFunc(s, func(match string) string {
+			if value, ok := values[promptVariable.FindStringSubmatch(match)[1]]; ok {
+				return value
+			}
+			return match
+		})
+	}
+	p.Header, p.Footer = expand(p.Header), expand(p.Footer)
+	return p
+}
+
+// section returns text as a block of lines followed by a blank line, or
+// nothing when text is empty
+func section(text string) string {
+	if text == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s\n\n", text)
+}
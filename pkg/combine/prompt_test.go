@@ -0,0 +1,59 @@
+package combine
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bhangun/coto/pkg/bundle"
+)
+
+func TestWriteBundle_Prompt(t *testing.T) {
+	files := []FileInfo{{Path: "/p/main.go", RelativePath: "main.go", Size: 40, Content: strings.Repeat("x", 40)}}
+	stats := Stats{FilesProcessed: 1, Directories: 1, TotalBytes: 40}
+	prompt := Prompt{Header: "Review {{project}}: {{ files }} files, ~{{tokens}} tokens {{unknown}}", Footer: "What does main do?", Project: "demo"}
+
+	for _, format := range []string{"text", "markdown", "json", "xml"} {
+		var buf bytes.Buffer
+		if _, _, err := WriteBundle(&buf, files, WriteOptions{Format: format, Prompt: prompt}, stats); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if !strings.Contains(out, "Review demo: 1 files, ~10 tokens {{unknown}}") || !strings.Contains(out, "What does main do?") {
+			t.Errorf("%s: expected the expanded prompt:\n%s", format, out)
+		}
+
+		// The prompt must not hide the bundle header or its files
+		b, err := bundle.Parse(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(b.Files) != 1 || b.Files[0].Content != files[0].Content {
+			t.Errorf("%s: expected main.go back, got %+v", format, b.Files)
+		}
+	}
+}
+
+func TestLoadPrompt(t *testing.T) {
+	dir := t.TempDir()
+	header := filepath.Join(dir, "header.md")
+	footer := filepath.Join(dir, "footer.md")
+	os.WriteFile(header, []byte("Instructions\n"), 0644)
+	os.WriteFile(footer, []byte("\nContext\n"), 0644)
+
+	p, err := LoadPrompt(Options{PromptHeader: header, PromptFooter: footer, Prompt: "Question?"}, filepath.Join(dir, "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Header != "Instructions" || p.Footer != "Context\n\nQuestion?" || p.Project != "app" {
+		t.Errorf("Unexpected prompt %+v", p)
+	}
+
+	var optErr *OptionError
+	if _, err := LoadPrompt(Options{PromptHeader: filepath.Join(dir, "missing.md")}, dir); !errors.As(err, &optErr) || optErr.Option != "prompt-header" {
+		t.Errorf("Expected an OptionError for the missing header, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package combine
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	"github.com/bhangun/coto/pkg/bundle"
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+// Load reads the collected files, applying the line selection of opts, and
+// adds them to c.Stats. Files that cannot be read are reported and skipped.
+// With opts.Parallel above one, files are read concurrently; the result
+// keeps the order of c.Paths either way. When ctx ends before every file is
+// read, the files read so far are returned together with ctx.Err().
+func Load(ctx context.Context, c *Collection, opts Options) ([]FileInfo, error) {
+	truncate, err := normalizeTruncate(opts.Truncate)
+	if err != nil {
+		return nil, &OptionError{Option: "truncate", Err: err}
+	}
+	contentOpts := contentOptions{
+		MaxLines: opts.MaxLines,
+		Truncate: truncate,
+		Ranges:   c.Ranges,
+		Checksum: opts.Checksum,
+	}
+
+	src := opts.source()
+	workers := opts.Parallel
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(c.Paths) {
+		workers = len(c.Paths)
+	}
+
+	type outcome struct {
+		index int
+		info  FileInfo
+		err   error
+	}
+	jobs := make(chan int)
+	outcomes := make(chan outcome)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				info, err := processSingleFile(src, c.Paths[index], c.BaseDir, contentOpts)
+				outcomes <- outcome{index, info, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range c.Paths {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// Events are sent from this goroutine only
+	loaded := make([]*FileInfo, len(c.Paths))
+	done := 0
+	for o := range outcomes {
+		done++
+		path := c.Paths[o.index]
+		if o.err != nil {
+			c.Stats.Errors++
+			opts.OnEvent.Emit(event.Event{Kind: event.Error, Path: path, Reason: "processing", Err: o.err})
+			continue
+		}
+		info := o.info
+		loaded[o.index] = &info
+		opts.OnEvent.Emit(event.Event{Kind: event.Processed, Path: info.RelativePath, Size: info.Size,
+			Done: done, Total: len(c.Paths)})
+	}
+
+	var fileInfos []FileInfo
+	for _, info := range loaded {
+		if info != nil {
+			fileInfos = append(fileInfos, *info)
+			c.Stats.FilesProcessed++
+			c.Stats.TotalBytes += info.Size
+		}
+	}
+	if done < len(c.Paths) {
+		return fileInfos, ctx.Err()
+	}
+	return fileInfos, nil
+}
+
+func processSingleFile(src fsys.FS, path, baseDir string, opts contentOptions) (FileInfo, error) {
+	info := FileInfo{
+		Path:         path,
+		RelativePath: getRelativePath(path, baseDir),
+	}
+
+	// Get file stats
+	fileInfo, err := src.Stat(path)
+	if err != nil {
+		return info, err
+	}
+
+	info.Size = fileInfo.Size()
+	info.Modified = fileInfo.ModTime().Format("2006-01-02 15:04:05")
+
+	// Read file content
+	content, err := src.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+
+	info.Content = string(content)
+	if opts.Checksum {
+		info.SHA256 = bundle.HashBytes(content)
+	}
+	applyLineSelection(&info, opts.Ranges[path], opts)
+	return info, nil
+}
+
+// rootHash computes the bundle root hash over every path, aliases included
+func rootHash(fileInfos []FileInfo) string {
+	var entries []bundle.Entry
+	for _, info := range fileInfos {
+		entries = append(entries, bundle.Entry{Path: filepath.ToSlash(info.RelativePath), SHA256: info.SHA256})
+		for _, alias := range info.Aliases {
+			entries = append(entries, bundle.Entry{Path: filepath.ToSlash(alias), SHA256: info.SHA256})
+		}
+	}
+	return bundle.RootHash(entries)
+}
@@ -0,0 +1,75 @@
+package combine
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/compression"
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/signing"
+)
+
+// outputGuard recognizes the bundle this run writes, earlier compressed or
+// split variants of it, its signature and leftover temp files, so a bundle never ends up
+// inside its own input
+type outputGuard struct {
+	dir   string
+	name  string         // output name without compression extension, e.g. combined.txt
+	parts *regexp.Regexp // combined.part1.txt, combined-part-2.txt, ...
+	temp  *regexp.Regexp // .combined.txt.gz.123456.tmp written by WriteFile
+}
+
+// newOutputGuard guards outputPath, the path with the compression and
+// encryption extensions WriteFile names its temp file after
+func newOutputGuard(outputPath string) *outputGuard {
+	if outputPath == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(outputPath)
+	if err != nil {
+		return nil
+	}
+
+	name := bundleName(filepath.Base(abs))
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	return &outputGuard{
+		dir:   filepath.Dir(abs),
+		name:  name,
+		parts: regexp.MustCompile(`^` + regexp.QuoteMeta(stem) + `[._-]part-?\d+` + regexp.QuoteMeta(ext) + `$`),
+		temp:  regexp.MustCompile(`^\.` + regexp.QuoteMeta(filepath.Base(abs)) + `\.\d+\.tmp$`),
+	}
+}
+
+// matches reports whether path is this run's output or a variant of it
+func (g *outputGuard) matches(path string) (bool, string) {
+	if g == nil {
+		return false, ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || filepath.Dir(abs) != g.dir {
+		return false, ""
+	}
+
+	base := filepath.Base(abs)
+	switch name := bundleName(base); {
+	case name == g.name && name == base:
+		return true, "output file of this run"
+	case name == g.name:
+		return true, "compressed or encrypted variant of the output file"
+	case g.parts.MatchString(name):
+		return true, "part of a previous output"
+	case g.temp.MatchString(base):
+		return true, "temporary output file"
+	case strings.HasSuffix(base, signing.SignatureExtension) &&
+		bundleName(strings.TrimSuffix(base, signing.SignatureExtension)) == g.name:
+		return true, "signature of the output file"
+	}
+	return false, ""
+}
+
+// bundleName strips encryption and compression extensions from a file name
+func bundleName(name string) string {
+	return compression.TrimExtension(encryption.TrimExtension(name))
+}
@@ -0,0 +1,112 @@
+package combine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bhangun/coto/pkg/bundle"
+	"github.com/bhangun/coto/pkg/compression"
+)
+
+func TestOutputGuard(t *testing.T) {
+	tempDir := t.TempDir()
+	guard := newOutputGuard(filepath.Join(tempDir, "combined.txt.gz"))
+
+	cases := map[string]bool{
+		"combined.txt":                       true,
+		"combined.txt.gz":                    true,
+		"combined.txt.zst":                   true,
+		"combined.part2.txt":                 true,
+		".combined.txt.gz.1234567.tmp":       true,
+		"combined.go":                        false,
+		"notcombined.txt":                    false,
+		filepath.Join("sub", "combined.txt"): false,
+	}
+	for name, want := range cases {
+		if got, why := guard.matches(filepath.Join(tempDir, name)); got != want {
+			t.Errorf("%s: expected %v, got %v (%s)", name, want, got, why)
+		}
+	}
+
+	// The temp file of a compressed, encrypted run is named after the full
+	// output path, even when empty or truncated
+	opts := Options{OutputFile: filepath.Join(tempDir, "combined.txt"), Compression: "zstd", Encrypt: true, NoIgnore: true}
+	filter, err := newFileFilter(opts, tempDir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp := filepath.Join(tempDir, ".combined.txt.zst.enc.1234567.tmp")
+	if err := os.WriteFile(temp, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, why := filter.output.matches(temp); !got || why != "temporary output file" {
+		t.Errorf("Expected the empty temp file of a compressed output to be guarded, got %v (%s)", got, why)
+	}
+}
+
+func TestWriteOutput_IsBundle(t *testing.T) {
+	tempDir := t.TempDir()
+	files := []FileInfo{{Path: "a.go", RelativePath: "a.go", Content: "package a\n"}}
+	stats := Stats{FilesProcessed: 1}
+
+	for _, format := range []string{"text", "json", "xml", "markdown"} {
+		for _, codec := range []compression.Codec{compression.None, compression.Zstd} {
+			path := filepath.Join(tempDir, "bundle-"+format+codec.Extension())
+			if _, err := WriteFile(context.Background(), files, path, WriteOptions{Format: format, Codec: codec}, stats); err != nil {
+				t.Fatalf("WriteFile(%s, %s) failed: %v", format, codec, err)
+			}
+			if !bundle.IsBundleFile(path) {
+				t.Errorf("Expected %s output (%s) to be recognized as a bundle", format, codec)
+			}
+		}
+	}
+
+	plain := filepath.Join(tempDir, "notes.txt")
+	if err := os.WriteFile(plain, []byte("Coto Output is a nice name\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if bundle.IsBundleFile(plain) {
+		t.Error("Expected plain file not to be recognized as a bundle")
+	}
+
+	// No temp files are left behind
+	leftovers, _ := filepath.Glob(filepath.Join(tempDir, ".*.tmp"))
+	if len(leftovers) > 0 {
+		t.Errorf("Unexpected temp files: %v", leftovers)
+	}
+}
+
+func TestWriteOutput_RoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	files := []FileInfo{
+		{Path: "a.go", RelativePath: "a.go", Content: "package a\n", Aliases: aliasList{"b.go"}, SHA256: bundle.HashBytes([]byte("package a\n"))},
+		{Path: "doc.md", RelativePath: "doc/doc.md", Content: "# Title\n```\ncode\n```\n" + strings.Repeat("=", 80) + "\n"},
+	}
+	stats := Stats{FilesProcessed: 3, RootHash: rootHash(files)}
+
+	for _, format := range []string{"text", "json", "xml", "markdown"} {
+		path := filepath.Join(tempDir, "bundle."+format)
+		if _, err := WriteFile(context.Background(), files, path, WriteOptions{Format: format}, stats); err != nil {
+			t.Fatalf("WriteFile(%s) failed: %v", format, err)
+		}
+
+		b, err := bundle.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", format, err)
+		}
+		if len(b.Files) != len(files) || b.RootHash != stats.RootHash {
+			t.Fatalf("%s: expected %d files and root hash, got %+v", format, len(files), b)
+		}
+		for i, f := range b.Files {
+			if f.RelativePath != files[i].RelativePath || f.Content != files[i].Content || f.SHA256 != files[i].SHA256 {
+				t.Errorf("%s: file %d did not round trip: %+v", format, i, f)
+			}
+		}
+		if len(b.Files[0].Aliases) != 1 || b.Files[0].Aliases[0] != "b.go" {
+			t.Errorf("%s: expected alias b.go, got %v", format, b.Files[0].Aliases)
+		}
+	}
+}
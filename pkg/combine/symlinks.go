@@ -0,0 +1,156 @@
+package combine
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+// fileKey identifies a file independently of the path used to reach it
+type fileKey struct {
+	dev, ino uint64
+	path     string // resolved path where device/inode numbers are unavailable
+}
+
+// linkError reports a symlink that could not be followed
+type linkError struct {
+	Path   string
+	Target string
+	Cycle  bool
+	Err    error
+}
+
+func (e *linkError) Error() string {
+	if e.Cycle {
+		return fmt.Sprintf("symlink cycle: %s -> %s", e.Path, e.Target)
+	}
+	return fmt.Sprintf("broken symlink: %s -> %s", e.Path, e.Target)
+}
+
+func (e *linkError) Unwrap() error {
+	return e.Err
+}
+
+// treeWalker is a filepath.Walk that can traverse symlinked directories.
+// Directories on the current path are tracked by device and inode so a
+// link back to an ancestor is reported instead of walked forever.
+type treeWalker struct {
+	src    fsys.FS
+	follow bool
+	fn     filepath.WalkFunc
+	active map[fileKey]bool
+}
+
+// walkTree walks root like filepath.Walk. Root itself is always resolved;
+// symlinks below it are only traversed when follow is set. Symlinks that
+// are broken or lead back into the current path are passed to fn as a
+// *linkError. Callers see the target's FileInfo for followed links, and the
+// link's own FileInfo (with fs.ModeSymlink) for directory links not followed.
+func walkTree(src fsys.FS, root string, follow bool, fn filepath.WalkFunc) error {
+	info, err := src.Stat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	w := &treeWalker{src: src, follow: follow, fn: fn, active: make(map[fileKey]bool)}
+	err = w.walk(root, info)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (w *treeWalker) walk(path string, info fs.FileInfo) error {
+	if !info.IsDir() {
+		return w.fn(path, info, nil)
+	}
+
+	if err := w.fn(path, info, nil); err != nil {
+		return err
+	}
+
+	entries, err := w.src.ReadDir(path)
+	if err != nil {
+		if err := w.fn(path, info, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+		return nil
+	}
+
+	if key, ok := identity(w.src, path, info); ok {
+		w.active[key] = true
+		defer delete(w.active, key)
+	}
+
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		childInfo, err := w.stat(child, entry)
+		if err != nil {
+			if err := w.fn(child, childInfo, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+
+		if err := w.walk(child, childInfo); err != nil {
+			if err != filepath.SkipDir {
+				return err
+			}
+			if !childInfo.IsDir() {
+				// Like filepath.Walk, SkipDir on a file skips the rest of the directory
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// stat returns the FileInfo a walk callback should see for entry
+func (w *treeWalker) stat(path string, entry fs.DirEntry) (fs.FileInfo, error) {
+	linkInfo, err := entry.Info()
+	if err != nil || entry.Type()&fs.ModeSymlink == 0 {
+		return linkInfo, err
+	}
+
+	target, err := w.src.Stat(path)
+	if err != nil {
+		dest, _ := w.src.Readlink(path)
+		return linkInfo, &linkError{Path: path, Target: dest, Err: err}
+	}
+	if !target.IsDir() {
+		return target, nil
+	}
+	if !w.follow {
+		return linkInfo, nil
+	}
+
+	if key, ok := identity(w.src, path, target); ok && w.active[key] {
+		dest, _ := w.src.Readlink(path)
+		return linkInfo, &linkError{Path: path, Target: dest, Cycle: true}
+	}
+	return target, nil
+}
+
+// brokenLink returns a *linkError when path is a dangling symlink
+func brokenLink(src fsys.FS, path string) *linkError {
+	info, err := src.Lstat(path)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return nil
+	}
+	if _, err := src.Stat(path); err == nil {
+		return nil
+	}
+	dest, _ := src.Readlink(path)
+	return &linkError{Path: path, Target: dest}
+}
+
+// identity returns the fileKey of path. Only the operating system identifies
+// files; elsewhere every path is a file of its own.
+func identity(src fsys.FS, path string, info fs.FileInfo) (fileKey, bool) {
+	if !fsys.IsOS(src) {
+		return fileKey{}, false
+	}
+	return fileIdentity(path, info)
+}
@@ -0,0 +1,71 @@
+package combine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+func TestWalkTree_FollowSymlinks(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tempDir, "shared", "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "shared", "lib", "x.go"), []byte("package lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tempDir, "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"app/shared":    "../shared",
+		"shared/lib/up": "..",
+		"app/broken.go": "missing.go",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(tempDir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	walk := func(follow bool) (files []string, broken, cycles int) {
+		err := walkTree(fsys.OS, filepath.Join(tempDir, "app"), follow, func(path string, info os.FileInfo, err error) error {
+			var linkErr *linkError
+			if errors.As(err, &linkErr) {
+				if linkErr.Cycle {
+					cycles++
+				} else {
+					broken++
+				}
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && info.Mode()&os.ModeSymlink == 0 {
+				rel, _ := filepath.Rel(tempDir, path)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walkTree failed: %v", err)
+		}
+		sort.Strings(files)
+		return files, broken, cycles
+	}
+
+	files, broken, cycles := walk(false)
+	if len(files) != 0 || broken != 1 || cycles != 0 {
+		t.Errorf("Without following: expected no files, 1 broken link, got %v, %d, %d", files, broken, cycles)
+	}
+
+	files, broken, cycles = walk(true)
+	if len(files) != 1 || files[0] != "app/shared/lib/x.go" || broken != 1 || cycles != 1 {
+		t.Errorf("Following: expected [app/shared/lib/x.go], 1 broken, 1 cycle, got %v, %d, %d", files, broken, cycles)
+	}
+}
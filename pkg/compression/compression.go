@@ -0,0 +1,237 @@
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Codec identifies a compression format
+type Codec string
+
+const (
+	None  Codec = ""
+	Gzip  Codec = "gzip"
+	Zstd  Codec = "zstd"
+	Xz    Codec = "xz"
+	Bzip2 Codec = "bzip2" // read-only, the standard library has no bzip2 writer
+)
+
+// magic numbers used to sniff the codec of an existing stream
+var magics = []struct {
+	codec Codec
+	magic []byte
+}{
+	{Gzip, []byte{0x1f, 0x8b}},
+	{Zstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{Xz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{Bzip2, []byte("BZh")},
+}
+
+// xzDictCaps maps compression levels 0-9 to xz dictionary sizes (as in xz presets)
+var xzDictCaps = []int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20,
+	8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// Parse converts a user supplied codec name into a Codec.
+// An empty string, "none" or "false" disable compression and "true" selects gzip
+// so the historical boolean -compress flag keeps working.
+func Parse(name string) (Codec, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "none", "false", "off":
+		return None, nil
+	case "gzip", "gz", "true", "on":
+		return Gzip, nil
+	case "zstd", "zst":
+		return Zstd, nil
+	case "xz":
+		return Xz, nil
+	case "bzip2", "bz2":
+		return Bzip2, nil
+	default:
+		return None, fmt.Errorf("unknown compression codec: %s (supported: gzip, zstd, xz)", name)
+	}
+}
+
+// Names returns the codecs that can be used for writing
+func Names() []string {
+	return []string{string(Gzip), string(Zstd), string(Xz)}
+}
+
+// String returns the codec name, or "none" when compression is disabled
+func (c Codec) String() string {
+	if c == None {
+		return "none"
+	}
+	return string(c)
+}
+
+// Extension returns the conventional file suffix for the codec
+func (c Codec) Extension() string {
+	switch c {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	case Xz:
+		return ".xz"
+	case Bzip2:
+		return ".bz2"
+	default:
+		return ""
+	}
+}
+
+// CanWrite reports whether output can be produced with the codec
+func (c Codec) CanWrite() bool {
+	return c == Gzip || c == Zstd || c == Xz
+}
+
+// ValidateLevel checks a compression level for the codec (0 means the codec default)
+func (c Codec) ValidateLevel(level int) error {
+	if level == 0 {
+		return nil
+	}
+	switch c {
+	case Gzip, Xz:
+		if level < 1 || level > 9 {
+			return fmt.Errorf("%s compression level must be between 1 and 9", c)
+		}
+	case Zstd:
+		if level < 1 || level > 22 {
+			return fmt.Errorf("zstd compression level must be between 1 and 22")
+		}
+	}
+	return nil
+}
+
+// WithExtension appends the codec suffix to path unless it is already present
+func WithExtension(path string, c Codec) string {
+	ext := c.Extension()
+	if ext == "" || strings.HasSuffix(strings.ToLower(path), ext) {
+		return path
+	}
+	return path + ext
+}
+
+// TrimExtension removes a known compression suffix from path
+func TrimExtension(path string) string {
+	lower := strings.ToLower(path)
+	for _, c := range []Codec{Gzip, Zstd, Xz, Bzip2} {
+		if strings.HasSuffix(lower, c.Extension()) {
+			return path[:len(path)-len(c.Extension())]
+		}
+	}
+	return path
+}
+
+// Detect returns the codec whose magic number prefixes header
+func Detect(header []byte) Codec {
+	for _, m := range magics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.codec
+		}
+	}
+	return None
+}
+
+// NewWriter wraps w with a compressing writer for the codec.
+// Closing the returned writer flushes the compressor but does not close w.
+func NewWriter(w io.Writer, c Codec, level int) (io.WriteCloser, error) {
+	if err := c.ValidateLevel(level); err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case Gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case Zstd:
+		opts := []zstd.EOption{}
+		if level > 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	case Xz:
+		cfg := xz.WriterConfig{}
+		if level > 0 {
+			cfg.DictCap = xzDictCaps[level]
+		}
+		return cfg.NewWriter(w)
+	case None:
+		return nopWriteCloser{w}, nil
+	default:
+		return nil, fmt.Errorf("%s compression is only supported for reading", c)
+	}
+}
+
+// NewReader sniffs the stream and returns a reader that transparently
+// decompresses it. Uncompressed input is passed through unchanged.
+func NewReader(r io.Reader) (io.ReadCloser, Codec, error) {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(6)
+	codec := Detect(header)
+
+	switch codec {
+	case Gzip:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, codec, err
+		}
+		return gz, codec, nil
+	case Zstd:
+		dec, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, codec, err
+		}
+		return dec.IOReadCloser(), codec, nil
+	case Xz:
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, codec, err
+		}
+		return io.NopCloser(xr), codec, nil
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(br)), codec, nil
+	default:
+		return io.NopCloser(br), None, nil
+	}
+}
+
+// ReadFile reads a file and transparently decompresses it when needed
+func ReadFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, codec, err := NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s stream: %w", codec, err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", codec, err)
+	}
+	return content, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
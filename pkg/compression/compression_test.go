@@ -0,0 +1,94 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	payload := strings.Repeat("package main\n\nfunc main() {}\n", 100)
+
+	for _, codec := range []Codec{None, Gzip, Zstd, Xz} {
+		for _, level := range []int{0, 1, 9} {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, codec, level)
+			if err != nil {
+				t.Fatalf("%s level %d: NewWriter failed: %v", codec, level, err)
+			}
+			if _, err := io.WriteString(w, payload); err != nil {
+				t.Fatalf("%s: write failed: %v", codec, err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("%s: close failed: %v", codec, err)
+			}
+
+			if got := Detect(buf.Bytes()); got != codec {
+				t.Errorf("Expected detected codec %s, got %s", codec, got)
+			}
+
+			r, detected, err := NewReader(&buf)
+			if err != nil {
+				t.Fatalf("%s: NewReader failed: %v", codec, err)
+			}
+			content, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatalf("%s: read failed: %v", codec, err)
+			}
+			if detected != codec {
+				t.Errorf("Expected reader codec %s, got %s", codec, detected)
+			}
+			if string(content) != payload {
+				t.Errorf("%s level %d: content mismatch after round trip", codec, level)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := map[string]Codec{
+		"":      None,
+		"false": None,
+		"true":  Gzip,
+		"gz":    Gzip,
+		"ZSTD":  Zstd,
+		"xz":    Xz,
+		"bz2":   Bzip2,
+	}
+	for input, expected := range cases {
+		got, err := Parse(input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", input, err)
+		}
+		if got != expected {
+			t.Errorf("Parse(%q): expected %s, got %s", input, expected, got)
+		}
+	}
+
+	if _, err := Parse("lz4"); err == nil {
+		t.Error("Expected error for unknown codec, got nil")
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	if got := WithExtension("bundle.md", Zstd); got != "bundle.md.zst" {
+		t.Errorf("Expected bundle.md.zst, got %s", got)
+	}
+	if got := WithExtension("bundle.md.gz", Gzip); got != "bundle.md.gz" {
+		t.Errorf("Expected suffix not to be doubled, got %s", got)
+	}
+	if got := WithExtension("bundle.md", None); got != "bundle.md" {
+		t.Errorf("Expected unchanged path, got %s", got)
+	}
+	if got := TrimExtension("bundle.json.xz"); got != "bundle.json" {
+		t.Errorf("Expected bundle.json, got %s", got)
+	}
+}
+
+func TestBzip2IsReadOnly(t *testing.T) {
+	if _, err := NewWriter(io.Discard, Bzip2, 0); err == nil {
+		t.Error("Expected error creating bzip2 writer, got nil")
+	}
+}
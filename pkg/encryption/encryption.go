@@ -0,0 +1,290 @@
+// Package encryption wraps bundles in a passphrase protected container.
+//
+// The container starts with a header holding the scrypt parameters and salt,
+// followed by the data split into chunks sealed with AES-256-GCM. Each chunk
+// nonce carries a counter and a final-chunk flag, so chunks cannot be
+// reordered, dropped or truncated without detection, and bundles of any size
+// are encrypted and decrypted in constant memory.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Magic starts every encrypted container
+const Magic = "COTOENC1"
+
+// Extension is appended to the names of encrypted bundles
+const Extension = ".enc"
+
+const (
+	saltSize    = 16
+	prefixSize  = 7
+	headerSize  = len(Magic) + 3 + saltSize + prefixSize
+	chunkSize   = 64 * 1024
+	keySize     = 32
+	defaultLogN = 15 // scrypt N = 2^15, r = 8, p = 1
+	defaultR    = 8
+	defaultP    = 1
+	maxLogN     = 22
+	maxP        = 4
+	maxMemory   = 256 << 20 // scrypt uses 128·r·N bytes
+)
+
+var (
+	// ErrDecrypt is returned when the passphrase is wrong or the data was altered
+	ErrDecrypt = errors.New("wrong passphrase or corrupted data")
+	// ErrTruncated is returned when the container ends before its final chunk
+	ErrTruncated = errors.New("encrypted data is truncated")
+)
+
+// WithExtension appends Extension to path unless it is already there
+func WithExtension(path string) string {
+	if strings.HasSuffix(path, Extension) {
+		return path
+	}
+	return path + Extension
+}
+
+// TrimExtension strips a trailing Extension
+func TrimExtension(path string) string {
+	return strings.TrimSuffix(path, Extension)
+}
+
+// IsEncrypted reports whether header starts an encrypted container
+func IsEncrypted(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(Magic))
+}
+
+type header struct {
+	logN, r, p byte
+	salt       [saltSize]byte
+	prefix     [prefixSize]byte
+}
+
+func (h header) bytes() []byte {
+	b := make([]byte, 0, headerSize)
+	b = append(b, Magic...)
+	b = append(b, h.logN, h.r, h.p)
+	b = append(b, h.salt[:]...)
+	return append(b, h.prefix[:]...)
+}
+
+// check rejects scrypt parameters that are invalid or would take more
+// memory or time than any bundle written by coto. The header is read before
+// the passphrase is verified, so it must not be trusted.
+func (h header) check() error {
+	if h.logN == 0 || h.logN > maxLogN || h.r == 0 || h.p == 0 {
+		return errors.New("invalid encryption parameters")
+	}
+	if 128*uint64(h.r)<<h.logN > maxMemory || h.p > maxP {
+		return fmt.Errorf("encryption parameters too costly: N=2^%d, r=%d, p=%d", h.logN, h.r, h.p)
+	}
+	return nil
+}
+
+func (h header) aead(passphrase []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, h.salt[:], 1<<h.logN, int(h.r), int(h.p), keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (h header) nonce(counter uint32, final bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, h.prefix[:])
+	binary.BigEndian.PutUint32(nonce[prefixSize:], counter)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// Writer encrypts everything written to it
+type Writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  header
+	aad     []byte
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+// NewWriter writes the container header to w and returns a Writer whose
+// Close must be called to seal the final chunk. It does not close w.
+func NewWriter(w io.Writer, passphrase []byte) (*Writer, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+
+	h := header{logN: defaultLogN, r: defaultR, p: defaultP}
+	if _, err := rand.Read(h.salt[:]); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(h.prefix[:]); err != nil {
+		return nil, err
+	}
+	aead, err := h.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	aad := h.bytes()
+	if _, err := w.Write(aad); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, header: h, aad: aad, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (e *Writer) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encryption writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only sealed once more data shows it is not the last chunk
+		if len(e.buf) == chunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the final chunk
+func (e *Writer) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+func (e *Writer) seal(final bool) error {
+	if e.counter == ^uint32(0) {
+		return errors.New("encrypted stream too long")
+	}
+	sealed := e.aead.Seal(nil, e.header.nonce(e.counter, final), e.buf, e.aad)
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// Reader decrypts a container
+type Reader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  header
+	aad     []byte
+	chunk   []byte
+	plain   []byte
+	counter uint32
+	done    bool
+	err     error // sticky, so a retried Read cannot skip a failed chunk
+}
+
+// NewReader reads the container header from r and derives the key. A wrong
+// passphrase is reported by the first Read as ErrDecrypt.
+func NewReader(r io.Reader, passphrase []byte) (*Reader, error) {
+	raw := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("reading encryption header: %v", err)
+	}
+	if !IsEncrypted(raw) {
+		return nil, errors.New("not an encrypted coto bundle")
+	}
+
+	var h header
+	h.logN, h.r, h.p = raw[len(Magic)], raw[len(Magic)+1], raw[len(Magic)+2]
+	if err := h.check(); err != nil {
+		return nil, err
+	}
+	copy(h.salt[:], raw[len(Magic)+3:])
+	copy(h.prefix[:], raw[len(Magic)+3+saltSize:])
+
+	aead, err := h.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		r:      bufio.NewReaderSize(r, chunkSize+aead.Overhead()),
+		aead:   aead,
+		header: h,
+		aad:    raw,
+		chunk:  make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (d *Reader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.err = d.open(); d.err != nil {
+			return 0, d.err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// open decrypts the next chunk
+func (d *Reader) open() error {
+	n, err := io.ReadFull(d.r, d.chunk)
+	switch {
+	case err == io.EOF:
+		return ErrTruncated
+	case err != nil && err != io.ErrUnexpectedEOF:
+		return err
+	}
+
+	// The last chunk is the one with nothing after it
+	final := err == io.ErrUnexpectedEOF
+	if !final {
+		if _, err := d.r.Peek(1); err == io.EOF {
+			final = true
+		}
+	}
+
+	plain, err := d.aead.Open(nil, d.header.nonce(d.counter, final), d.chunk[:n], d.aad)
+	if err != nil {
+		// A chunk that only opens as non-final means the stream was cut short
+		if final {
+			if _, err := d.aead.Open(nil, d.header.nonce(d.counter, false), d.chunk[:n], d.aad); err == nil {
+				return ErrTruncated
+			}
+		}
+		return ErrDecrypt
+	}
+	d.counter++
+	d.plain = plain
+	d.done = final
+	return nil
+}
@@ -0,0 +1,151 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bhangun/coto/pkg/compression"
+)
+
+var testPass = []byte("correct horse battery staple")
+
+func encrypt(t *testing.T, payload []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testPass)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(data, pass []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data), pass)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	sizes := []int{0, 1, chunkSize - 1, chunkSize, 2 * chunkSize, 2*chunkSize + 17}
+	for _, size := range sizes {
+		payload := bytes.Repeat([]byte("x"), size)
+		data := encrypt(t, payload)
+		if !IsEncrypted(data) {
+			t.Fatalf("size %d: missing container header", size)
+		}
+
+		got, err := decrypt(data, testPass)
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Errorf("size %d: content mismatch after round trip", size)
+		}
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	data := encrypt(t, []byte("secret bundle"))
+	if _, err := decrypt(data, []byte("wrong")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestTamperedAndTruncated(t *testing.T) {
+	payload := bytes.Repeat([]byte("y"), 2*chunkSize+100)
+	data := encrypt(t, payload)
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-5] ^= 1
+	if _, err := decrypt(tampered, testPass); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("tampered: expected ErrDecrypt, got %v", err)
+	}
+
+	// Dropping the last chunk leaves a valid but non-final chunk at the end
+	chunk := chunkSize + 16
+	cut := data[:headerSize+2*chunk]
+	if _, err := decrypt(cut, testPass); !errors.Is(err, ErrTruncated) {
+		t.Errorf("truncated: expected ErrTruncated, got %v", err)
+	}
+}
+
+func TestHostileHeader(t *testing.T) {
+	data := encrypt(t, []byte("secret bundle"))
+	params := []struct{ logN, r, p byte }{
+		{22, 255, 1}, // 128 GiB of memory
+		{20, 8, 1},
+		{15, 8, 255},
+		{0, 8, 1},
+	}
+	for _, param := range params {
+		hostile := append([]byte(nil), data...)
+		hostile[len(Magic)], hostile[len(Magic)+1], hostile[len(Magic)+2] = param.logN, param.r, param.p
+		if _, err := NewReader(bytes.NewReader(hostile), testPass); err == nil {
+			t.Errorf("logN=%d r=%d p=%d: expected parameters to be rejected", param.logN, param.r, param.p)
+		}
+	}
+}
+
+func TestOpenReader(t *testing.T) {
+	payload := strings.Repeat("Coto Output\nGenerated: now\n", 200)
+
+	var compressed bytes.Buffer
+	cw, err := compression.NewWriter(&compressed, compression.Zstd, 0)
+	if err != nil {
+		t.Fatalf("compression writer failed: %v", err)
+	}
+	io.WriteString(cw, payload)
+	cw.Close()
+	data := encrypt(t, compressed.Bytes())
+
+	asked := 0
+	pass := func() ([]byte, error) {
+		asked++
+		return testPass, nil
+	}
+	r, err := OpenReader(bytes.NewReader(data), pass)
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(got) != payload {
+		t.Error("content mismatch after decrypt and decompress")
+	}
+	if asked != 1 {
+		t.Errorf("Expected the passphrase to be asked once, got %d", asked)
+	}
+
+	// Plain input never asks for a passphrase
+	r, err = OpenReader(strings.NewReader(payload), pass)
+	if err != nil {
+		t.Fatalf("OpenReader on plain input failed: %v", err)
+	}
+	got, _ = io.ReadAll(r)
+	if string(got) != payload || asked != 1 {
+		t.Error("plain input should pass through without a passphrase")
+	}
+
+	// A wrong passphrase survives the compression sniffing
+	r, err = OpenReader(bytes.NewReader(data), func() ([]byte, error) { return []byte("nope"), nil })
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	if !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt through OpenReader, got %v", err)
+	}
+}
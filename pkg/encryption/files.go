@@ -0,0 +1,134 @@
+package encryption
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/bhangun/coto/pkg/compression"
+	"golang.org/x/term"
+)
+
+// PassphraseEnv is the environment variable read for the passphrase
+const PassphraseEnv = "COTO_PASSPHRASE"
+
+// Passphrase returns the passphrase from the environment variable env or,
+// when it is unset, prompts for it on the terminal. With confirm the
+// passphrase has to be typed twice.
+func Passphrase(env string, confirm bool) ([]byte, error) {
+	if env == "" {
+		env = PassphraseEnv
+	}
+	if value, ok := os.LookupEnv(env); ok {
+		if value == "" {
+			return nil, fmt.Errorf("%s is empty", env)
+		}
+		return []byte(value), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("no passphrase: set %s or run in a terminal", env)
+	}
+
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	pass, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(pass) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		if string(again) != string(pass) {
+			return nil, errors.New("passphrases do not match")
+		}
+	}
+	return pass, nil
+}
+
+// OpenReader returns the plain bundle stream of r, decrypting it when it is
+// an encrypted container and decompressing it when it is compressed.
+// passphrase is only called for encrypted data.
+func OpenReader(r io.Reader, passphrase func() ([]byte, error)) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(Magic))
+
+	var source io.Reader = br
+	if IsEncrypted(head) {
+		if passphrase == nil {
+			return nil, errors.New("bundle is encrypted")
+		}
+		pass, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		dec, err := NewReader(br, pass)
+		if err != nil {
+			return nil, err
+		}
+		source = dec
+	}
+
+	reader, _, err := compression.NewReader(source)
+	return reader, err
+}
+
+// ReadFile reads a bundle that may be encrypted and compressed
+func ReadFile(path string, passphrase func() ([]byte, error)) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := OpenReader(file, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return data, nil
+}
+
+// IsEncryptedFile reports whether path starts with the container header
+func IsEncryptedFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	head := make([]byte, len(Magic))
+	n, _ := io.ReadFull(file, head)
+	return IsEncrypted(head[:n])
+}
+
+// CachedPassphrase returns a passphrase func that asks only once, which
+// matters when several encrypted files are read in one run
+func CachedPassphrase(env string) func() ([]byte, error) {
+	var once sync.Once
+	var pass []byte
+	var err error
+	return func() ([]byte, error) {
+		once.Do(func() {
+			pass, err = Passphrase(env, false)
+		})
+		return pass, err
+	}
+}
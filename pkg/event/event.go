@@ -0,0 +1,39 @@
+// Package event describes what the coto libraries report while they run.
+// Commands decide how to present events; the libraries never print.
+package event
+
+// Kind is the type of an event
+type Kind string
+
+const (
+	Included  Kind = "included"  // a path was selected
+	Skipped   Kind = "skipped"   // a path was left out, Reason says why
+	Processed Kind = "processed" // a file was read or handled, Done of Total
+	Written   Kind = "written"   // a file was created, Target is its path
+	Renamed   Kind = "renamed"   // Path was renamed to Target
+	Warning   Kind = "warning"   // something was skipped that the user may care about
+	Error     Kind = "error"     // a single path failed; the run continues
+)
+
+// Event is a single report from a library call
+type Event struct {
+	Kind   Kind
+	Path   string
+	Dir    bool   // Path is a directory
+	Target string // symlink target, new name or written file
+	Reason string
+	Err    error
+	Size   int64 // bytes read or written
+	Done   int   // progress counters of Processed events
+	Total  int
+}
+
+// Handler receives events. Libraries call it from a single goroutine at a time.
+type Handler func(Event)
+
+// Emit sends e to h; a nil Handler discards it
+func (h Handler) Emit(e Event) {
+	if h != nil {
+		h(e)
+	}
+}
@@ -0,0 +1,399 @@
+// Package extract pulls code blocks out of text files and bundles with the
+// extractor plugins and writes each block to its own file.
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/bhangun/coto/pkg/compression"
+	"github.com/bhangun/coto/pkg/encryption"
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/extractor"
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+// ErrNoInputs is returned when Options.Inputs is empty
+var ErrNoInputs = errors.New("input files are required")
+
+// InputError reports an input that does not exist or is not a valid glob
+type InputError struct {
+	Path string
+	Err  error
+}
+
+func (e *InputError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid glob pattern: %s", e.Path)
+	}
+	return fmt.Sprintf("input file does not exist: %s", e.Path)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// FileError reports a single input that could not be extracted
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Options controls an extraction run
+type Options struct {
+	Inputs    []string // input files, see ExpandInputs for globs
+	OutputDir string
+	Language  string // extractor to use for every file; empty detects it per file
+	Parallel  int    // files extracted at once, capped at the number of CPUs
+	DryRun    bool   // extract without writing any file
+	Registry  *PluginRegistry
+	FS        fsys.FS      // holds the inputs, nil reads them from the operating system
+	Output    fsys.WriteFS // receives the files, nil writes to the operating system
+	// Passphrase is asked for when an input is encrypted; nil fails such inputs
+	Passphrase func() ([]byte, error)
+	OnEvent    event.Handler
+}
+
+// ExtractionResult holds the result of extracting code blocks from a file
+type ExtractionResult struct {
+	SourceFile    string
+	ExtractorName string
+	CodeBlocks    []extractor.CodeBlock
+	WrittenFiles  []string
+
+	writeErrors []*FileError // blocks that could not be written, sent as events
+}
+
+// Result lists the extracted files in input order and the ones that failed
+type Result struct {
+	Files  []ExtractionResult
+	Failed []*FileError
+}
+
+// Blocks returns the number of code blocks found
+func (r *Result) Blocks() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.CodeBlocks)
+	}
+	return n
+}
+
+// Written returns the number of files written
+func (r *Result) Written() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.WrittenFiles)
+	}
+	return n
+}
+
+// Errors returns the number of inputs that failed and of code blocks that
+// could not be written
+func (r *Result) Errors() int {
+	n := len(r.Failed)
+	for _, f := range r.Files {
+		n += len(f.writeErrors)
+	}
+	return n
+}
+
+// ExpandInputs expands glob patterns and checks that every input exists in
+// src, nil meaning the operating system. A pattern without matches is kept
+// as a literal path.
+func ExpandInputs(src fsys.FS, inputs []string) ([]string, error) {
+	src = fsys.Or(src)
+	var expanded []string
+	for _, path := range inputs {
+		var matches []string
+		var err error
+		if fsys.IsOS(src) {
+			matches, err = filepath.Glob(path)
+		} else {
+			matches, err = fs.Glob(src, fsys.Clean(path))
+		}
+		if err != nil {
+			return nil, &InputError{Path: path, Err: err}
+		}
+		if matches != nil {
+			expanded = append(expanded, matches...)
+		} else {
+			expanded = append(expanded, path)
+		}
+	}
+
+	for _, path := range expanded {
+		if _, err := src.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return nil, &InputError{Path: path}
+		}
+	}
+	return expanded, nil
+}
+
+// Run extracts the code blocks of every input. Inputs that fail are listed
+// in Result.Failed; the returned error is for problems that stop the run.
+// When ctx ends first, files already being extracted are finished and the
+// partial Result is returned with ctx.Err().
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	if len(opts.Inputs) == 0 {
+		return nil, ErrNoInputs
+	}
+	if opts.Registry == nil {
+		opts.Registry = NewBuiltinRegistry()
+	}
+	opts.FS = fsys.Or(opts.FS)
+	opts.Output = fsys.OrWrite(opts.Output)
+	if !opts.DryRun {
+		if err := opts.Output.MkdirAll(opts.OutputDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %v", err)
+		}
+	}
+
+	workers := opts.Parallel
+	if workers > runtime.NumCPU() {
+		workers = runtime.NumCPU()
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	type outcome struct {
+		index  int
+		result ExtractionResult
+		err    error
+	}
+	jobs := make(chan int)
+	outcomes := make(chan outcome)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				result, err := extractFile(opts.Inputs[index], opts)
+				outcomes <- outcome{index, result, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range opts.Inputs {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// Events are sent from this goroutine only; results keep the input order
+	results := make([]*ExtractionResult, len(opts.Inputs))
+	errs := make([]*FileError, len(opts.Inputs))
+	done := 0
+	for o := range outcomes {
+		done++
+		path := opts.Inputs[o.index]
+		if o.err != nil {
+			errs[o.index] = &FileError{Path: path, Err: o.err}
+			opts.OnEvent.Emit(event.Event{Kind: event.Error, Path: path, Err: o.err})
+			continue
+		}
+		extracted := o.result
+		results[o.index] = &extracted
+		opts.OnEvent.Emit(event.Event{Kind: event.Processed, Path: path, Reason: o.result.ExtractorName,
+			Done: done, Total: len(opts.Inputs)})
+		o.result.emitWrites(opts.Output, opts.OnEvent)
+	}
+
+	result := &Result{}
+	for i := range opts.Inputs {
+		if results[i] != nil {
+			result.Files = append(result.Files, *results[i])
+		} else if errs[i] != nil {
+			result.Failed = append(result.Failed, errs[i])
+		}
+	}
+	if done < len(opts.Inputs) {
+		return result, ctx.Err()
+	}
+	return result, nil
+}
+
+// extractFile extracts and writes the code blocks of a single file
+func extractFile(path string, opts Options) (ExtractionResult, error) {
+	// Compressed bundles (.gz, .zst, .xz, .bz2) are decompressed transparently,
+	// encrypted ones are decrypted after asking for the passphrase once
+	content, err := readInput(opts.FS, path, opts.Passphrase)
+	if err != nil {
+		return ExtractionResult{}, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	// Determine the appropriate extractor based on file extension or language option
+	var plugin extractor.ExtractorPlugin
+	if opts.Language != "" {
+		plugin = opts.Registry.GetExtractorByLanguage(opts.Language)
+	} else {
+		// Auto-detect based on file extension, ignoring any compression suffix
+		ext := strings.ToLower(filepath.Ext(compression.TrimExtension(encryption.TrimExtension(path))))
+		plugin = opts.Registry.GetExtractorByExtension(ext)
+		if plugin == nil {
+			// Extensionless scripts are recognized by their shebang
+			plugin = opts.Registry.GetExtractorByLanguage(ShebangLanguage(content))
+		}
+	}
+	if plugin == nil {
+		// Use generic extractor as fallback
+		plugin = opts.Registry.GetExtractorByLanguage("generic")
+	}
+	if plugin == nil {
+		return ExtractionResult{}, fmt.Errorf("no suitable extractor found for file: %s", path)
+	}
+
+	if err := plugin.Initialize(); err != nil {
+		return ExtractionResult{}, fmt.Errorf("failed to initialize extractor: %v", err)
+	}
+	defer plugin.Cleanup()
+
+	result := ExtractionResult{
+		SourceFile:    path,
+		ExtractorName: plugin.Name(),
+		CodeBlocks:    plugin.Extract(string(content)),
+	}
+	if !opts.DryRun {
+		result.writeBlocks(opts.Output, opts.OutputDir)
+	}
+	return result, nil
+}
+
+// readInput reads a file that may be an encrypted or compressed bundle
+func readInput(src fsys.FS, path string, passphrase func() ([]byte, error)) ([]byte, error) {
+	file, err := src.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := encryption.OpenReader(file, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return data, nil
+}
+
+// Write writes the code blocks of a dry run below outputDir, after their
+// file names or the blocks themselves were changed, and reports every file
+// written or failed to onEvent
+func (r *Result) Write(out fsys.WriteFS, outputDir string, onEvent event.Handler) error {
+	out = fsys.OrWrite(out)
+	if err := out.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	for i := range r.Files {
+		f := &r.Files[i]
+		f.WrittenFiles, f.writeErrors = nil, nil
+		f.writeBlocks(out, outputDir)
+		f.emitWrites(out, onEvent)
+	}
+	return nil
+}
+
+// emitWrites reports the files written and the blocks that failed
+func (r *ExtractionResult) emitWrites(out fsys.WriteFS, onEvent event.Handler) {
+	for _, written := range r.WrittenFiles {
+		var size int64
+		if info, err := out.Stat(written); err == nil {
+			size = info.Size()
+		}
+		onEvent.Emit(event.Event{Kind: event.Written, Path: r.SourceFile, Target: written, Size: size})
+	}
+	for _, err := range r.writeErrors {
+		onEvent.Emit(event.Event{Kind: event.Error, Path: r.SourceFile, Target: err.Path, Err: err.Err})
+	}
+}
+
+// FileName returns the name code block i is written to below the output
+// directory: the name the extractor found, or one made of the source file,
+// the block type, its index and the extension of its language
+func (r *ExtractionResult) FileName(i int) string {
+	block := r.CodeBlocks[i]
+	if block.Filename != "" {
+		return block.Filename
+	}
+	sourceName := compression.TrimExtension(encryption.TrimExtension(filepath.Base(r.SourceFile)))
+	baseName := strings.TrimSuffix(sourceName, filepath.Ext(sourceName))
+	return fmt.Sprintf("%s_%s_%d%s", baseName, block.Type, i, ExtensionForLanguage(block.Language))
+}
+
+// writeBlocks writes every code block to its own file below outputDir
+func (r *ExtractionResult) writeBlocks(out fsys.WriteFS, outputDir string) {
+	for i, block := range r.CodeBlocks {
+		outputPath := filepath.Join(outputDir, r.FileName(i))
+
+		dir := filepath.Dir(outputPath)
+		if err := out.MkdirAll(dir, 0755); err != nil {
+			r.writeErrors = append(r.writeErrors, &FileError{Path: outputPath,
+				Err: fmt.Errorf("failed to create directory %s: %v", dir, err)})
+			continue
+		}
+		if err := out.WriteFile(outputPath, []byte(block.Content), 0644); err != nil {
+			r.writeErrors = append(r.writeErrors, &FileError{Path: outputPath, Err: err})
+			continue
+		}
+		r.WrittenFiles = append(r.WrittenFiles, outputPath)
+	}
+}
+
+// ExtensionForLanguage returns the file extension for code in a language
+func ExtensionForLanguage(lang string) string {
+	switch strings.ToLower(lang) {
+	case "go", "golang":
+		return ".go"
+	case "java":
+		return ".java"
+	case "python":
+		return ".py"
+	case "javascript", "js":
+		return ".js"
+	case "typescript", "ts":
+		return ".ts"
+	case "rust":
+		return ".rs"
+	case "dart":
+		return ".dart"
+	case "json":
+		return ".json"
+	case "xml":
+		return ".xml"
+	case "yaml", "yml":
+		return ".yaml"
+	case "markdown", "md":
+		return ".md"
+	default:
+		return ".txt"
+	}
+}
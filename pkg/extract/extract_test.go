@@ -0,0 +1,131 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.go")
+	code := "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n\ntype Point struct {\n\tX, Y int\n}\n"
+	if err := os.WriteFile(source, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.go")
+	output := filepath.Join(dir, "out")
+
+	result, err := Run(context.Background(), Options{Inputs: []string{source, missing}, OutputDir: output, Parallel: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 || result.Files[0].ExtractorName != "go" {
+		t.Fatalf("Expected main.go to be extracted by the go plugin, got %+v", result.Files)
+	}
+	if result.Blocks() == 0 || result.Written() != result.Blocks() {
+		t.Errorf("Expected every block to be written, got %d blocks and %d files", result.Blocks(), result.Written())
+	}
+	for _, path := range result.Files[0].WrittenFiles {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to exist: %v", path, err)
+		}
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Path != missing {
+		t.Errorf("Expected missing.go to fail, got %v", result.Failed)
+	}
+
+	if _, err := Run(context.Background(), Options{}); !errors.Is(err, ErrNoInputs) {
+		t.Errorf("Expected ErrNoInputs, got %v", err)
+	}
+	var inputErr *InputError
+	if _, err := ExpandInputs(nil, []string{missing}); !errors.As(err, &inputErr) {
+		t.Errorf("Expected an InputError, got %v", err)
+	}
+}
+
+func TestRun_FS(t *testing.T) {
+	src := fstest.MapFS{
+		"notes/api.md":  {Data: []byte("# API\n\n```go\npackage api\n```\n\n```python\nprint('hi')\n```\n")},
+		"notes/todo.md": {Data: []byte("nothing here\n")},
+	}
+	out := fsys.MapFS{}
+
+	inputs, err := ExpandInputs(fsys.FromFS(src), []string{"notes/*.md"})
+	if err != nil || len(inputs) != 2 {
+		t.Fatalf("Expected both notes, got %v, %v", inputs, err)
+	}
+	var inputErr *InputError
+	if _, err := ExpandInputs(fsys.FromFS(src), []string{"notes/missing.md"}); !errors.As(err, &inputErr) {
+		t.Errorf("Expected an InputError, got %v", err)
+	}
+
+	result, err := Run(context.Background(), Options{Inputs: inputs, OutputDir: "out", FS: fsys.FromFS(src), Output: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Blocks() < 2 || result.Written() != result.Blocks() {
+		t.Fatalf("Expected every block to be written, got %d blocks and %d files", result.Blocks(), result.Written())
+	}
+	for _, path := range result.Files[0].WrittenFiles {
+		if _, err := out.Stat(path); err != nil {
+			t.Errorf("Expected %s in the output file system: %v", path, err)
+		}
+	}
+	if _, err := os.Stat("out"); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be written to the operating system")
+	}
+}
+
+func TestResult_Write(t *testing.T) {
+	src := fstest.MapFS{
+		"api.md": {Data: []byte("```go\npackage api\n```\n\n```python\nprint('hi')\n```\n")},
+	}
+	out := fsys.MapFS{}
+
+	result, err := Run(context.Background(), Options{Inputs: []string{"api.md"}, DryRun: true, FS: fsys.FromFS(src), Output: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &result.Files[0]
+	if len(f.CodeBlocks) != 2 {
+		t.Fatalf("Expected two blocks, got %+v", f.CodeBlocks)
+	}
+	// Blocks without a name are named after the source
+	f.CodeBlocks[0].Filename = ""
+	if name := f.FileName(0); name != "api_code_block_0.go" {
+		t.Errorf("Unexpected name %s", name)
+	}
+
+	// The blocks of a dry run can be renamed or dropped before writing
+	f.CodeBlocks[1].Filename = "scripts/hello.py"
+	f.CodeBlocks = f.CodeBlocks[1:]
+	if err := result.Write(out, "out", nil); err != nil {
+		t.Fatal(err)
+	}
+	if result.Written() != 1 || f.WrittenFiles[0] != filepath.Join("out", "scripts", "hello.py") {
+		t.Fatalf("Expected out/scripts/hello.py only, got %v", f.WrittenFiles)
+	}
+	if _, err := out.Stat(f.WrittenFiles[0]); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShebangLanguage(t *testing.T) {
+	cases := map[string]string{
+		"#!/usr/bin/env python3\nprint()": "python",
+		"#!/bin/bash\n":                   "shell",
+		"#!/usr/bin/env -S node --flag\n": "javascript",
+		"package main\n":                  "",
+	}
+	for head, want := range cases {
+		if got := ShebangLanguage([]byte(head)); got != want {
+			t.Errorf("%q: expected %q, got %q", head, want, got)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package extract
 
 import (
+	"bytes"
+	"path/filepath"
 	"strings"
 
 	"github.com/bhangun/coto/pkg/extractor"
@@ -9,9 +11,9 @@ import (
 
 // PluginRegistry manages all extractor plugins
 type PluginRegistry struct {
-	plugins        map[string]extractor.ExtractorPlugin // name -> plugin
-	extensionMap   map[string]extractor.ExtractorPlugin // extension -> plugin
-	languageMap    map[string]extractor.ExtractorPlugin // language -> plugin
+	plugins      map[string]extractor.ExtractorPlugin // name -> plugin
+	extensionMap map[string]extractor.ExtractorPlugin // extension -> plugin
+	languageMap  map[string]extractor.ExtractorPlugin // language -> plugin
 }
 
 // NewPluginRegistry creates a new plugin registry
@@ -63,6 +65,70 @@ func (r *PluginRegistry) GetAllPlugins() []extractor.ExtractorPlugin {
 	return plugins
 }
 
+// interpreterLanguages maps shebang interpreters to language names
+var interpreterLanguages = map[string]string{
+	"python": "python",
+	"node":   "javascript",
+	"nodejs": "javascript",
+	"deno":   "javascript",
+	"bun":    "javascript",
+	"dart":   "dart",
+	"go":     "go",
+	"sh":     "shell",
+	"bash":   "shell",
+	"zsh":    "shell",
+	"ksh":    "shell",
+	"dash":   "shell",
+	"ruby":   "ruby",
+	"perl":   "perl",
+	"php":    "php",
+	"lua":    "lua",
+}
+
+// NewBuiltinRegistry returns a registry holding the built-in plugins
+func NewBuiltinRegistry() *PluginRegistry {
+	registry := NewPluginRegistry()
+	registerBuiltInPlugins(registry)
+	return registry
+}
+
+// DetectLanguage names the language of a file from its extension, falling
+// back to the shebang line in head. It returns "" when neither is known.
+func (r *PluginRegistry) DetectLanguage(filename string, head []byte) string {
+	if plugin := r.GetExtractorByExtension(filepath.Ext(filename)); plugin != nil {
+		return plugin.Name()
+	}
+	return ShebangLanguage(head)
+}
+
+// ShebangLanguage returns the language named by a "#!" line, so
+// "#!/usr/bin/env python3" is "python" and "#!/bin/bash" is "shell"
+func ShebangLanguage(head []byte) string {
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return ""
+	}
+	line, _, _ := bytes.Cut(head[2:], []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// Skip env options such as -S
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+	// python3.12 and python3 are python
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	return interpreterLanguages[strings.ToLower(interpreter)]
+}
+
 // registerBuiltInPlugins registers all built-in extractor plugins
 func registerBuiltInPlugins(registry *PluginRegistry) {
 	// Register Java extractor
@@ -92,4 +158,4 @@ func registerBuiltInPlugins(registry *PluginRegistry) {
 	// Register generic extractor as fallback
 	genericExtractor := plugins.NewGenericExtractor()
 	registry.Register(genericExtractor)
-}
\ No newline at end of file
+}
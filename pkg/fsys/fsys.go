@@ -0,0 +1,118 @@
+// Package fsys is the file system layer the commands read from and write to.
+// FS is the subset of the os package they need, so the operating system, any
+// io/fs file system (archives, git trees, fstest.MapFS fixtures) and
+// in-memory trees can be used interchangeably.
+package fsys
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FS is a read-only file system. Names are native paths for OS and
+// slash-separated paths relative to the root for everything else.
+type FS interface {
+	fs.FS
+	Stat(name string) (fs.FileInfo, error)
+	// Lstat is Stat without following a final symlink
+	Lstat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	ReadFile(name string) ([]byte, error)
+	Readlink(name string) (string, error)
+}
+
+// WriteFS is a file system that can also be modified
+type WriteFS interface {
+	FS
+	MkdirAll(name string, perm fs.FileMode) error
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Rename(oldname, newname string) error
+}
+
+// OS is the operating system's file system
+var OS WriteFS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error)          { return os.Open(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (osFS) Lstat(name string) (fs.FileInfo, error)     { return os.Lstat(name) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (osFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(name) }
+func (osFS) Readlink(name string) (string, error)       { return os.Readlink(name) }
+
+func (osFS) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(name, perm) }
+func (osFS) Rename(oldname, newname string) error         { return os.Rename(oldname, newname) }
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+// Or returns fsys, or OS when fsys is nil
+func Or(fsys FS) FS {
+	if fsys == nil {
+		return OS
+	}
+	return fsys
+}
+
+// OrWrite returns fsys, or OS when fsys is nil
+func OrWrite(fsys WriteFS) WriteFS {
+	if fsys == nil {
+		return OS
+	}
+	return fsys
+}
+
+// IsOS reports whether fsys is the operating system's file system
+func IsOS(fsys FS) bool {
+	return fsys == nil || fsys == OS
+}
+
+// FromFS adapts an io/fs file system. Names may use either separator and a
+// leading "./"; symlinks are only seen when fsys implements Lstat and
+// ReadLink.
+func FromFS(fsys fs.FS) FS {
+	if f, ok := fsys.(FS); ok {
+		return f
+	}
+	return ioFS{fsys}
+}
+
+type ioFS struct {
+	fsys fs.FS
+}
+
+// Clean turns a native or slash-separated relative path into an io/fs name
+func Clean(name string) string {
+	name = path.Clean(filepath.ToSlash(name))
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func (f ioFS) Open(name string) (fs.File, error)          { return f.fsys.Open(Clean(name)) }
+func (f ioFS) Stat(name string) (fs.FileInfo, error)      { return fs.Stat(f.fsys, Clean(name)) }
+func (f ioFS) ReadDir(name string) ([]fs.DirEntry, error) { return fs.ReadDir(f.fsys, Clean(name)) }
+func (f ioFS) ReadFile(name string) ([]byte, error)       { return fs.ReadFile(f.fsys, Clean(name)) }
+
+func (f ioFS) Lstat(name string) (fs.FileInfo, error) {
+	if l, ok := f.fsys.(interface {
+		Lstat(string) (fs.FileInfo, error)
+	}); ok {
+		return l.Lstat(Clean(name))
+	}
+	return f.Stat(name)
+}
+
+func (f ioFS) Readlink(name string) (string, error) {
+	if l, ok := f.fsys.(interface {
+		ReadLink(string) (string, error)
+	}); ok {
+		return l.ReadLink(Clean(name))
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,54 @@
+package fsys
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFromFS(t *testing.T) {
+	f := FromFS(fstest.MapFS{
+		"src/main.go": {Data: []byte("package main\n")},
+	})
+
+	if data, err := f.ReadFile("./src/main.go"); err != nil || string(data) != "package main\n" {
+		t.Errorf("ReadFile: %q, %v", data, err)
+	}
+	entries, err := f.ReadDir(".")
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		t.Errorf("ReadDir: %v, %v", entries, err)
+	}
+	if info, err := f.Lstat("src"); err != nil || !info.IsDir() {
+		t.Errorf("Lstat: %v, %v", info, err)
+	}
+	if _, err := f.Stat("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestMapFS(t *testing.T) {
+	m := MapFS{}
+	if err := m.MkdirAll("out/go", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("out/go/main.go", []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("out", nil, 0644); err == nil {
+		t.Error("Expected an error when overwriting a directory")
+	}
+
+	if err := m.Rename("out", "extracted"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := m.ReadFile("extracted/go/main.go"); err != nil || string(data) != "package main\n" {
+		t.Errorf("ReadFile after rename: %q, %v", data, err)
+	}
+	if _, err := m.Stat("out/go/main.go"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the old name to be gone, got %v", err)
+	}
+	if err := m.Rename("missing", "x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist, got %v", err)
+	}
+}
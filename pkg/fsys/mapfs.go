@@ -0,0 +1,65 @@
+package fsys
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"testing/fstest"
+	"time"
+)
+
+// MapFS is a writable in-memory file system built on fstest.MapFS. Reads go
+// through fstest.MapFS, so parent directories need no entries of their own.
+type MapFS fstest.MapFS
+
+func (m MapFS) view() FS { return ioFS{fstest.MapFS(m)} }
+
+func (m MapFS) Open(name string) (fs.File, error)          { return m.view().Open(name) }
+func (m MapFS) Stat(name string) (fs.FileInfo, error)      { return m.view().Stat(name) }
+func (m MapFS) Lstat(name string) (fs.FileInfo, error)     { return m.view().Lstat(name) }
+func (m MapFS) ReadDir(name string) ([]fs.DirEntry, error) { return m.view().ReadDir(name) }
+func (m MapFS) ReadFile(name string) ([]byte, error)       { return m.view().ReadFile(name) }
+func (m MapFS) Readlink(name string) (string, error)       { return m.view().Readlink(name) }
+
+// MkdirAll adds directory entries for name and its missing parents
+func (m MapFS) MkdirAll(name string, perm fs.FileMode) error {
+	for dir := Clean(name); dir != "."; dir = path.Dir(dir) {
+		if file, ok := m[dir]; ok {
+			if file.Mode.IsDir() {
+				continue
+			}
+			return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+		}
+		m[dir] = &fstest.MapFile{Mode: fs.ModeDir | perm.Perm(), ModTime: time.Now()}
+	}
+	return nil
+}
+
+// WriteFile creates or replaces the file name
+func (m MapFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	name = Clean(name)
+	if file, ok := m[name]; ok && file.Mode.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	m[name] = &fstest.MapFile{Data: append([]byte(nil), data...), Mode: perm.Perm(), ModTime: time.Now()}
+	return nil
+}
+
+// Rename moves a file, or a directory with everything below it
+func (m MapFS) Rename(oldname, newname string) error {
+	oldname, newname = Clean(oldname), Clean(newname)
+	if _, err := m.Stat(oldname); err != nil {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	moved := make(map[string]*fstest.MapFile)
+	for name, file := range m {
+		if name == oldname || strings.HasPrefix(name, oldname+"/") {
+			moved[newname+strings.TrimPrefix(name, oldname)] = file
+			delete(m, name)
+		}
+	}
+	for name, file := range moved {
+		m[name] = file
+	}
+	return nil
+}
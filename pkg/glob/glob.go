@@ -0,0 +1,164 @@
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// HasMeta reports whether s contains any glob metacharacters
+func HasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[{")
+}
+
+// Match reports whether name matches pattern. Both use forward slashes.
+// In addition to the path.Match syntax, "**" matches zero or more whole
+// path segments and "{a,b}" matches any of the comma-separated alternatives.
+func Match(pattern, name string) bool {
+	name = strings.Trim(name, "/")
+	for _, p := range expandBraces(pattern) {
+		if matchSegments(splitPath(p), splitPath(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CouldMatchUnder reports whether some path inside directory dir could match
+// pattern. It is used to avoid pruning directories an include rule may need.
+func CouldMatchUnder(pattern, dir string) bool {
+	dir = strings.Trim(dir, "/")
+	for _, p := range expandBraces(pattern) {
+		if matchPrefix(splitPath(p), splitPath(dir)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that pattern is well formed
+func Validate(pattern string) error {
+	for _, p := range expandBraces(pattern) {
+		for _, seg := range splitPath(p) {
+			if seg == "**" {
+				continue
+			}
+			if _, err := path.Match(seg, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Split separates the static leading directory of pattern from the rest,
+// e.g. "docs/**/*.md" becomes ("docs", "**/*.md"). The base is "." when
+// the pattern starts with a wildcard.
+func Split(pattern string) (base, rest string) {
+	segments := strings.Split(pattern, "/")
+	i := 0
+	for ; i < len(segments)-1; i++ {
+		if HasMeta(segments[i]) {
+			break
+		}
+	}
+	base = strings.Join(segments[:i], "/")
+	if base == "" {
+		if strings.HasPrefix(pattern, "/") {
+			base = "/"
+		} else {
+			base = "."
+		}
+	}
+	return base, strings.Join(segments[i:], "/")
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse consecutive "**" segments
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchPrefix is like matchSegments but succeeds when name runs out first
+func matchPrefix(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if len(name) == 0 || pattern[0] == "**" {
+			return true
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return false
+}
+
+// expandBraces expands "{a,b}" alternatives into separate patterns
+func expandBraces(pattern string) []string {
+	start := strings.IndexByte(pattern, '{')
+	if start < 0 {
+		return []string{pattern}
+	}
+
+	depth := 0
+	end := -1
+	var options []string
+	last := start + 1
+	for i := start; i < len(pattern) && end < 0; i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				options = append(options, pattern[last:i])
+				end = i
+			}
+		case ',':
+			if depth == 1 {
+				options = append(options, pattern[last:i])
+				last = i + 1
+			}
+		}
+	}
+	if end < 0 {
+		// Unbalanced brace, treat literally
+		return []string{pattern}
+	}
+
+	var result []string
+	for _, option := range options {
+		result = append(result, expandBraces(pattern[:start]+option+pattern[end+1:])...)
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/main/main.go", true},
+		{"docs/**/*.md", "docs/readme.md", true},
+		{"docs/**/*.md", "docs/guide/intro.md", true},
+		{"docs/**/*.md", "src/readme.md", false},
+		{"**/vendor/**", "a/vendor/b/c.go", true},
+		{"**/vendor/**", "a/vendors/c.go", false},
+		{"src/**", "src", true},
+		{"*.{js,ts}", "index.ts", true},
+		{"*.{js,ts}", "index.go", false},
+		{"{api,web}/**/*.go", "web/x/y.go", true},
+		{"file?.txt", "file1.txt", true},
+		{"[ab].txt", "c.txt", false},
+	}
+
+	for _, c := range cases {
+		if got := Match(c.pattern, c.name); got != c.match {
+			t.Errorf("Match(%q, %q) = %v, expected %v", c.pattern, c.name, got, c.match)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		pattern, base, rest string
+	}{
+		{"docs/**/*.md", "docs", "**/*.md"},
+		{"**/*.go", ".", "**/*.go"},
+		{"a/b/*.go", "a/b", "*.go"},
+		{"/abs/**", "/abs", "**"},
+	}
+
+	for _, c := range cases {
+		base, rest := Split(c.pattern)
+		if base != c.base || rest != c.rest {
+			t.Errorf("Split(%q) = (%q, %q), expected (%q, %q)", c.pattern, base, rest, c.base, c.rest)
+		}
+	}
+}
+
+func TestCouldMatchUnder(t *testing.T) {
+	cases := []struct {
+		pattern string
+		dir     string
+		match   bool
+	}{
+		{"src/**/*.go", "src", true},
+		{"src/**/*.go", "src/a/b", true},
+		{"src/**/*.go", "docs", false},
+		{"src/*.go", "src/a", false},
+		{"**/*.md", "anything/deep", true},
+		{"{api,web}/*.go", "web", true},
+	}
+
+	for _, c := range cases {
+		if got := CouldMatchUnder(c.pattern, c.dir); got != c.match {
+			t.Errorf("CouldMatchUnder(%q, %q) = %v, expected %v", c.pattern, c.dir, got, c.match)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate("src/**/[a-z].go"); err != nil {
+		t.Errorf("Expected valid pattern, got %v", err)
+	}
+	if err := Validate("src/[.go"); err == nil {
+		t.Error("Expected error for malformed pattern, got nil")
+	}
+}
@@ -0,0 +1,63 @@
+// Package interrupt turns Ctrl-C, SIGTERM and timeouts into context
+// cancellation, so long runs stop cleanly and can report what they finished.
+package interrupt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Exit codes of a run that was stopped early
+const (
+	ExitInterrupted = 130 // 128 + SIGINT, like shells report it
+	ExitTimeout     = 124 // the code of timeout(1)
+)
+
+// Context returns a context that is canceled on SIGINT or SIGTERM and, when
+// timeout is positive, once it has elapsed. The first signal restores the
+// default handling, so a second Ctrl-C exits immediately.
+func Context(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// Canceled reports whether err comes from a canceled or expired context
+func Canceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Reason describes why ctx ended
+func Reason(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "Timed out"
+	}
+	return "Interrupted"
+}
+
+// ExitCode returns the exit status for a run that failed with err: the
+// interrupted or timeout code when err wraps a context error, 1 otherwise
+func ExitCode(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	}
+	return 1
+}
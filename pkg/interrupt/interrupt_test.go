@@ -0,0 +1,39 @@
+package interrupt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestContext_Timeout(t *testing.T) {
+	ctx, stop := Context(10 * time.Millisecond)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the context to time out")
+	}
+	if Reason(ctx) != "Timed out" || ExitCode(ctx.Err()) != ExitTimeout {
+		t.Errorf("Unexpected reason %q or exit code %d", Reason(ctx), ExitCode(ctx.Err()))
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	cases := map[error]int{
+		fmt.Errorf("interrupted after 3 of 5 files: %w", context.Canceled): ExitInterrupted,
+		context.DeadlineExceeded: ExitTimeout,
+		errors.New("boom"):       1,
+	}
+	for err, want := range cases {
+		if got := ExitCode(err); got != want {
+			t.Errorf("ExitCode(%v) = %d, want %d", err, got, want)
+		}
+	}
+	if !Canceled(fmt.Errorf("wrapped: %w", context.Canceled)) || Canceled(errors.New("boom")) {
+		t.Error("Canceled does not recognize context errors")
+	}
+}
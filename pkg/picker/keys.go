@@ -0,0 +1,87 @@
+package picker
+
+import "unicode/utf8"
+
+// keyCode identifies a key that is not a printable rune
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEsc
+	keyBackspace
+	keyCtrlC
+	keyUnknown
+)
+
+// key is a key press read from the terminal
+type key struct {
+	code keyCode
+	r    rune
+}
+
+// sequences maps the escape sequences of common terminals to keys
+var sequences = map[string]keyCode{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[5~": keyPageUp, "[6~": keyPageDown,
+	"[H": keyHome, "[F": keyEnd, "OH": keyHome, "OF": keyEnd,
+	"[1~": keyHome, "[4~": keyEnd, "[7~": keyHome, "[8~": keyEnd,
+}
+
+// parseKeys splits the bytes of one read into key presses. A lone escape
+// is the Esc key.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			n, code := escape(b[1:])
+			keys = append(keys, key{code: code})
+			b = b[1+n:]
+			continue
+		case c == '\r' || c == '\n':
+			keys = append(keys, key{code: keyEnter})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{code: keyBackspace})
+		case c == 0x03:
+			keys = append(keys, key{code: keyCtrlC})
+		case c < 0x20:
+			keys = append(keys, key{code: keyUnknown})
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, key{code: keyRune, r: r})
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// escape returns the length and key of the sequence following an escape
+func escape(b []byte) (int, keyCode) {
+	if len(b) == 0 || (b[0] != '[' && b[0] != 'O') {
+		return 0, keyEsc
+	}
+	// Sequences end with a letter or ~ after optional digits and semicolons
+	for i := 1; i < len(b); i++ {
+		c := b[i]
+		if c >= '0' && c <= '9' || c == ';' {
+			continue
+		}
+		if code, ok := sequences[string(b[:i+1])]; ok {
+			return i + 1, code
+		}
+		return i + 1, keyUnknown
+	}
+	return len(b), keyUnknown
+}
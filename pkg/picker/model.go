@@ -0,0 +1,377 @@
+package picker
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// node is a directory or a file of the tree
+type node struct {
+	name     string
+	parent   *node
+	children []*node
+	file     int // index in model.files, -1 for directories
+	open     bool
+	depth    int
+}
+
+func (n *node) dir() bool {
+	return n.file < 0
+}
+
+// action is what a key asks the picker loop to do
+type action int
+
+const (
+	actionNone action = iota
+	actionAccept
+	actionCancel
+)
+
+// expandAll is the number of files up to which every directory starts open
+const expandAll = 200
+
+// model is the state of the picker, independent of the terminal
+type model struct {
+	title    string
+	files    []File
+	selected []bool
+	root     *node
+	order    []*node // files in tree order
+
+	rows    []*node // visible rows
+	cursor  int
+	offset  int
+	query   string
+	typing  bool // the search line has focus
+	message string
+
+	width, height int
+	previews      map[int][]string
+}
+
+func newModel(title string, files []File) *model {
+	m := &model{
+		title:    title,
+		files:    files,
+		selected: make([]bool, len(files)),
+		root:     &node{file: -1, open: true, depth: -1},
+		previews: make(map[int][]string),
+		width:    80,
+		height:   24,
+	}
+	for i, f := range files {
+		m.selected[i] = true
+		parts := strings.Split(strings.Trim(f.Name, "/"), "/")
+		dir := m.root
+		for _, part := range parts[:len(parts)-1] {
+			dir = dir.child(part)
+		}
+		dir.children = append(dir.children, &node{name: parts[len(parts)-1], parent: dir, file: i, depth: dir.depth + 1})
+	}
+	m.root.sort(len(files) <= expandAll)
+	m.root.walk(func(n *node) {
+		if !n.dir() {
+			m.order = append(m.order, n)
+		}
+	})
+	m.refresh()
+	return m
+}
+
+// child returns the named subdirectory, creating it when needed
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.dir() && c.name == name {
+			return c
+		}
+	}
+	c := &node{name: name, parent: n, file: -1, depth: n.depth + 1}
+	n.children = append(n.children, c)
+	return c
+}
+
+// sort orders directories before files, both by name
+func (n *node) sort(open bool) {
+	sort.SliceStable(n.children, func(i, j int) bool {
+		a, b := n.children[i], n.children[j]
+		if a.dir() != b.dir() {
+			return a.dir()
+		}
+		return a.name < b.name
+	})
+	for _, c := range n.children {
+		if c.dir() {
+			c.open = open
+			c.sort(open)
+		}
+	}
+}
+
+// walk calls fn for every node below n in tree order
+func (n *node) walk(fn func(*node)) {
+	for _, c := range n.children {
+		fn(c)
+		c.walk(fn)
+	}
+}
+
+// refresh recomputes the visible rows: the open part of the tree, or the
+// files matching the search
+func (m *model) refresh() {
+	m.rows = m.rows[:0]
+	if m.query != "" {
+		for _, n := range m.order {
+			if fuzzy(m.query, m.files[n.file].Name) {
+				m.rows = append(m.rows, n)
+			}
+		}
+	} else {
+		var add func(*node)
+		add = func(n *node) {
+			for _, c := range n.children {
+				m.rows = append(m.rows, c)
+				if c.dir() && c.open {
+					add(c)
+				}
+			}
+		}
+		add(m.root)
+	}
+	m.move(0)
+}
+
+// fuzzy reports whether the runes of query appear in s in order, ignoring case
+func fuzzy(query, s string) bool {
+	q := []rune(strings.ToLower(query))
+	for _, r := range strings.ToLower(s) {
+		if len(q) == 0 {
+			break
+		}
+		if r == q[0] {
+			q = q[1:]
+		}
+	}
+	return len(q) == 0
+}
+
+// current returns the node under the cursor, or nil when nothing is shown
+func (m *model) current() *node {
+	if m.cursor < len(m.rows) {
+		return m.rows[m.cursor]
+	}
+	return nil
+}
+
+// move moves the cursor by delta rows and keeps it on screen
+func (m *model) move(delta int) {
+	m.cursor += delta
+	if m.cursor >= len(m.rows) {
+		m.cursor = len(m.rows) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	body := m.bodyHeight()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+body {
+		m.offset = m.cursor - body + 1
+	}
+	if m.offset > len(m.rows)-body {
+		m.offset = len(m.rows) - body
+	}
+	if m.offset < 0 {
+		m.offset = 0
+	}
+}
+
+// filesOf returns the indexes of the files below or at n
+func (m *model) filesOf(n *node) []int {
+	if !n.dir() {
+		return []int{n.file}
+	}
+	var files []int
+	n.walk(func(c *node) {
+		if !c.dir() {
+			files = append(files, c.file)
+		}
+	})
+	return files
+}
+
+// setAll selects files unless they are all selected, then deselects them,
+// and returns whether they ended up selected
+func (m *model) setAll(files []int) bool {
+	all := true
+	for _, i := range files {
+		all = all && m.selected[i]
+	}
+	for _, i := range files {
+		m.selected[i] = !all
+	}
+	return !all
+}
+
+// counts returns the selected and total files below or at n
+func (m *model) counts(n *node) (selected, total int) {
+	for _, i := range m.filesOf(n) {
+		total++
+		if m.selected[i] {
+			selected++
+		}
+	}
+	return selected, total
+}
+
+// totals returns the count, bytes and estimated tokens of the selection
+func (m *model) totals() (files int, size int64, tokens int) {
+	for i, f := range m.files {
+		if m.selected[i] {
+			files++
+			size += f.Size
+			tokens += Tokens(f.Size)
+		}
+	}
+	return files, size, tokens
+}
+
+// Tokens estimates the model tokens of a file of size bytes at about four
+// bytes per token, as coto stats and coto tree do
+func Tokens(size int64) int {
+	return int((size + 3) / 4)
+}
+
+// toggleExtension selects or deselects every file with the extension of
+// the file under the cursor
+func (m *model) toggleExtension() {
+	n := m.current()
+	if n == nil || n.dir() {
+		m.message = "Move to a file to toggle its extension"
+		return
+	}
+	ext := path.Ext(m.files[n.file].Name)
+	var files []int
+	for i, f := range m.files {
+		if path.Ext(f.Name) == ext {
+			files = append(files, i)
+		}
+	}
+	label := ext
+	if ext == "" {
+		label = "extension-less"
+	}
+	verb := "Deselected"
+	if m.setAll(files) {
+		verb = "Selected"
+	}
+	m.message = fmt.Sprintf("%s %d %s files", verb, len(files), label)
+}
+
+// handle applies a key and returns what the loop should do next
+func (m *model) handle(k key) action {
+	m.message = ""
+
+	if m.typing {
+		switch {
+		case k.code == keyEnter:
+			m.typing = false
+		case k.code == keyEsc:
+			m.typing = false
+			m.query = ""
+			m.refresh()
+		case k.code == keyBackspace:
+			if r := []rune(m.query); len(r) > 0 {
+				m.query = string(r[:len(r)-1])
+				m.refresh()
+			}
+		case k.code == keyRune && unicode.IsPrint(k.r):
+			m.query += string(k.r)
+			m.cursor = 0
+			m.refresh()
+		case k.code == keyCtrlC:
+			return actionCancel
+		default:
+			m.navigate(k)
+		}
+		return actionNone
+	}
+
+	switch {
+	case k.code == keyCtrlC, k.code == keyRune && k.r == 'q':
+		return actionCancel
+	case k.code == keyEnter:
+		if files, _, _ := m.totals(); files == 0 {
+			m.message = "Select at least one file, or press q to cancel"
+			return actionNone
+		}
+		return actionAccept
+	case k.code == keyEsc:
+		if m.query != "" {
+			m.query = ""
+			m.refresh()
+		}
+	case k.code == keyRune && k.r == '/':
+		m.typing = true
+	case k.code == keyRune && k.r == ' ':
+		if n := m.current(); n != nil {
+			m.setAll(m.filesOf(n))
+		}
+	case k.code == keyRune && k.r == 'a':
+		var files []int
+		for _, n := range m.rows {
+			if m.query != "" || n.parent == m.root {
+				files = append(files, m.filesOf(n)...)
+			}
+		}
+		m.setAll(files)
+	case k.code == keyRune && k.r == 'e':
+		m.toggleExtension()
+	default:
+		m.navigate(k)
+	}
+	return actionNone
+}
+
+// navigate moves the cursor and opens or closes directories
+func (m *model) navigate(k key) {
+	body := m.bodyHeight()
+	switch {
+	case k.code == keyUp, k.code == keyRune && k.r == 'k':
+		m.move(-1)
+	case k.code == keyDown, k.code == keyRune && k.r == 'j':
+		m.move(1)
+	case k.code == keyPageUp:
+		m.move(-body)
+	case k.code == keyPageDown:
+		m.move(body)
+	case k.code == keyHome, k.code == keyRune && k.r == 'g':
+		m.move(-len(m.rows))
+	case k.code == keyEnd, k.code == keyRune && k.r == 'G':
+		m.move(len(m.rows))
+	case k.code == keyRight, k.code == keyRune && k.r == 'l':
+		if n := m.current(); n != nil && n.dir() && !n.open && m.query == "" {
+			n.open = true
+			m.refresh()
+		}
+	case k.code == keyLeft, k.code == keyRune && k.r == 'h':
+		n := m.current()
+		if n == nil || m.query != "" {
+			return
+		}
+		if n.dir() && n.open {
+			n.open = false
+			m.refresh()
+			return
+		}
+		for i, row := range m.rows {
+			if row == n.parent {
+				m.move(i - m.cursor)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package picker is a full-screen terminal file picker. It shows files as a
+// tree with checkboxes, live size and token totals, fuzzy search and a
+// preview pane, and returns the files that stay selected.
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrCanceled is returned when the picker is left without accepting
+var ErrCanceled = errors.New("file selection canceled")
+
+// File is a file offered by the picker
+type File struct {
+	Path string // returned when the file is selected
+	Name string // slash-separated path shown in the tree
+	Size int64
+}
+
+// Available reports whether in and out are terminals the picker can use
+func Available(in, out *os.File) bool {
+	return term.IsTerminal(int(in.Fd())) && term.IsTerminal(int(out.Fd()))
+}
+
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l" // alternate screen, hidden cursor
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// Run shows the picker on the terminal of in and out. Every file starts
+// selected, and the paths of the selected files are returned in the order
+// of files.
+func Run(title string, files []File, in, out *os.File) ([]string, error) {
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	fmt.Fprint(out, enterScreen)
+	defer fmt.Fprint(out, leaveScreen)
+
+	m := newModel(title, files)
+	buf := make([]byte, 256)
+	for {
+		if width, height, err := term.GetSize(int(out.Fd())); err == nil {
+			m.width, m.height = width, height
+			m.move(0)
+		}
+		draw(out, m.render())
+
+		n, err := in.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			switch m.handle(k) {
+			case actionCancel:
+				return nil, ErrCanceled
+			case actionAccept:
+				return m.selection(), nil
+			}
+		}
+	}
+}
+
+// selection returns the paths of the selected files
+func (m *model) selection() []string {
+	var paths []string
+	for i, f := range m.files {
+		if m.selected[i] {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
+
+// draw writes the lines from the top left corner of the screen. Raw mode
+// needs explicit carriage returns.
+func draw(w io.Writer, lines []string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	io.WriteString(w, b.String())
+}
@@ -0,0 +1,128 @@
+package picker
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func testModel() *model {
+	return newModel("Select files", []File{
+		{Path: "/p/main.go", Name: "main.go", Size: 400},
+		{Path: "/p/cmd/app/app.go", Name: "cmd/app/app.go", Size: 800},
+		{Path: "/p/cmd/app/README.md", Name: "cmd/app/README.md", Size: 100},
+		{Path: "/p/docs/guide.md", Name: "docs/guide.md", Size: 2000},
+	})
+}
+
+func press(m *model, input string) action {
+	var last action
+	for _, k := range parseKeys([]byte(input)) {
+		last = m.handle(k)
+	}
+	return last
+}
+
+func rowNames(m *model) []string {
+	var names []string
+	for _, n := range m.rows {
+		names = append(names, n.name)
+	}
+	return names
+}
+
+func TestModel_Tree(t *testing.T) {
+	m := testModel()
+	if got := strings.Join(rowNames(m), " "); got != "cmd app README.md app.go docs guide.md main.go" {
+		t.Fatalf("Unexpected rows %s", got)
+	}
+
+	// Closing cmd hides its children, and space on it deselects both files
+	press(m, "\x1b[D")
+	if got := strings.Join(rowNames(m), " "); got != "cmd docs guide.md main.go" {
+		t.Errorf("Expected cmd to close, got %s", got)
+	}
+	press(m, " ")
+	if files, size, tokens := m.totals(); files != 2 || size != 2400 || tokens != 600 {
+		t.Errorf("Expected 2 files, 2400 bytes and 600 tokens, got %d, %d and %d", files, size, tokens)
+	}
+	if label := m.label(m.rows[0]); !strings.Contains(label, "[ ] ▸ cmd/  (0/2)") {
+		t.Errorf("Unexpected label %q", label)
+	}
+
+	// Opening it again and selecting one file leaves the directory partial
+	press(m, "\x1b[Cjj ")
+	if label := m.label(m.rows[0]); !strings.Contains(label, "[-] ▾ cmd/  (1/2)") {
+		t.Errorf("Unexpected label %q", label)
+	}
+
+	if got := strings.Join(m.selection(), " "); got != "/p/main.go /p/cmd/app/README.md /p/docs/guide.md" {
+		t.Errorf("Unexpected selection %s", got)
+	}
+}
+
+func TestModel_SearchAndExtension(t *testing.T) {
+	m := testModel()
+	press(m, "/rdme")
+	if got := strings.Join(rowNames(m), " "); got != "README.md" {
+		t.Fatalf("Expected the fuzzy match only, got %s", got)
+	}
+	press(m, "\re")
+	if !strings.Contains(m.message, "Deselected 2 .md files") {
+		t.Errorf("Unexpected message %q", m.message)
+	}
+	if got := strings.Join(m.selection(), " "); got != "/p/main.go /p/cmd/app/app.go" {
+		t.Errorf("Unexpected selection %s", got)
+	}
+
+	// Esc clears the search, a toggles everything
+	press(m, "\x1ba")
+	if len(m.rows) != 7 || len(m.selection()) != 4 {
+		t.Errorf("Expected the full tree with every file selected, got %d rows and %d files", len(m.rows), len(m.selection()))
+	}
+	press(m, "a")
+	if action := press(m, "\r"); action != actionNone || m.message == "" {
+		t.Error("Expected an empty selection to be refused")
+	}
+	if action := press(m, "q"); action != actionCancel {
+		t.Error("Expected q to cancel")
+	}
+}
+
+func TestRender(t *testing.T) {
+	m := testModel()
+	m.width, m.height = 50, 6
+	m.move(0)
+	lines := m.render()
+	if len(lines) != m.height {
+		t.Fatalf("Expected %d lines, got %d", m.height, len(lines))
+	}
+	if !strings.Contains(lines[0], "4/4 files · 3.2 KB · ~825 tokens") {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+	for _, line := range lines[2 : len(lines)-1] {
+		plain := strings.NewReplacer(reverse, "", reset, "").Replace(line)
+		if n := utf8.RuneCountInString(plain); n != m.width {
+			t.Errorf("Expected rows of %d columns, got %d in %q", m.width, n, plain)
+		}
+	}
+
+	// Moving past the body scrolls
+	press(m, "GG")
+	if m.offset != len(m.rows)-m.bodyHeight() {
+		t.Errorf("Expected the last rows to be shown, offset is %d", m.offset)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("a\x1b[A\x1b[6~\x1b\r\x7f\x03é"))
+	want := []key{{keyRune, 'a'}, {keyUp, 0}, {keyPageDown, 0}, {keyEsc, 0}, {keyEnter, 0}, {keyBackspace, 0}, {keyCtrlC, 0}, {keyRune, 'é'}}
+	if len(keys) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, keys)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("Key %d: expected %v, got %v", i, want[i], keys[i])
+		}
+	}
+}
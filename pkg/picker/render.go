@@ -0,0 +1,174 @@
+package picker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bhangun/coto/pkg/combine"
+)
+
+const (
+	reverse = "\x1b[7m"
+	dim     = "\x1b[2m"
+	bold    = "\x1b[1m"
+	reset   = "\x1b[0m"
+
+	// previewBytes is how much of a file the preview pane reads
+	previewBytes = 32 * 1024
+	// minPreviewWidth is the terminal width from which the preview is shown
+	minPreviewWidth = 60
+)
+
+// help lists the keys on the last line
+const help = "↑↓ move  ←→ close/open  space toggle  e extension  a all  / search  enter done  q cancel"
+
+// bodyHeight is the number of rows between the header lines and the help
+func (m *model) bodyHeight() int {
+	if h := m.height - 3; h > 1 {
+		return h
+	}
+	return 1
+}
+
+// render returns the lines of the screen
+func (m *model) render() []string {
+	files, size, tokens := m.totals()
+	lines := []string{bold + fit(fmt.Sprintf(" %s  %d/%d files · %s · ~%s tokens", m.title, files, len(m.files),
+		combine.FormatBytes(size), combine.FormatCount(tokens)), m.width) + reset}
+
+	switch {
+	case m.message != "":
+		lines = append(lines, fit(" "+m.message, m.width))
+	case m.typing:
+		lines = append(lines, fit(" / "+m.query+"█", m.width))
+	case m.query != "":
+		lines = append(lines, fit(fmt.Sprintf(" / %s  (%d matches, esc clears)", m.query, len(m.rows)), m.width))
+	default:
+		lines = append(lines, dim+fit(" / to search, space to toggle, enter when done", m.width)+reset)
+	}
+
+	listWidth, previewWidth := m.width, 0
+	if m.width >= minPreviewWidth {
+		listWidth = m.width / 2
+		previewWidth = m.width - listWidth - 3
+	}
+	preview := m.preview(previewWidth)
+
+	body := m.bodyHeight()
+	for i := 0; i < body; i++ {
+		line := strings.Repeat(" ", listWidth)
+		if row := m.offset + i; row < len(m.rows) {
+			line = fit(m.label(m.rows[row]), listWidth)
+			if row == m.cursor {
+				line = reverse + line + reset
+			}
+		}
+		if previewWidth > 0 {
+			text := ""
+			if i < len(preview) {
+				text = preview[i]
+			}
+			text = fit(text, previewWidth)
+			if i == 0 {
+				text = bold + text + reset
+			}
+			line += " │ " + text
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, dim+fit(" "+help, m.width)+reset)
+}
+
+// label is the text of a row: a checkbox, the name and the size
+func (m *model) label(n *node) string {
+	selected, total := m.counts(n)
+	box := "[-]"
+	switch selected {
+	case 0:
+		box = "[ ]"
+	case total:
+		box = "[x]"
+	}
+
+	if !n.dir() {
+		name := n.name
+		indent := strings.Repeat("  ", n.depth)
+		if m.query != "" {
+			name, indent = m.files[n.file].Name, ""
+		}
+		return fmt.Sprintf(" %s%s %s  %s", indent, box, name, combine.FormatBytes(m.files[n.file].Size))
+	}
+	marker := "▸"
+	if n.open {
+		marker = "▾"
+	}
+	return fmt.Sprintf(" %s%s %s %s/  (%d/%d)", strings.Repeat("  ", n.depth), box, marker, n.name, selected, total)
+}
+
+// preview returns the lines of the preview pane for the row under the
+// cursor, starting with a title
+func (m *model) preview(width int) []string {
+	n := m.current()
+	if width <= 0 || n == nil {
+		return nil
+	}
+	if n.dir() {
+		var size int64
+		files := m.filesOf(n)
+		for _, i := range files {
+			size += m.files[i].Size
+		}
+		return []string{n.name + "/", fmt.Sprintf("%d files, %s", len(files), combine.FormatBytes(size))}
+	}
+
+	lines, ok := m.previews[n.file]
+	if !ok {
+		lines = readPreview(m.files[n.file].Path)
+		m.previews[n.file] = lines
+	}
+	return append([]string{m.files[n.file].Name}, lines...)
+}
+
+// readPreview returns the first lines of the file at path
+func readPreview(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, previewBytes))
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return []string{"(binary file)"}
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Map(func(r rune) rune {
+			if unicode.IsPrint(r) {
+				return r
+			}
+			return -1
+		}, strings.ReplaceAll(line, "\t", "    "))
+	}
+	return lines
+}
+
+// fit truncates or pads s to width runes
+func fit(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		if width <= 0 {
+			return ""
+		}
+		return string([]rune(s)[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,162 @@
+// Package prompt asks questions one line at a time, as the interactive
+// modes of coto do.
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+var (
+	cyan = color.New(color.FgCyan).SprintFunc()
+	red  = color.New(color.FgRed).SprintFunc()
+)
+
+// Prompter reads answers from in and writes questions to out
+type Prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	eof bool
+}
+
+// New returns a Prompter reading from in and writing to out
+func New(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{in: bufio.NewReader(in), out: out}
+}
+
+var (
+	stdio     *Prompter
+	stdioOnce sync.Once
+)
+
+// Stdio returns the Prompter of stdin and stdout. It is shared so that
+// answers piped in are not lost in the buffer of another Prompter.
+func Stdio() *Prompter {
+	stdioOnce.Do(func() {
+		stdio = New(os.Stdin, os.Stdout)
+	})
+	return stdio
+}
+
+// Terminal reports whether stdin and stdout are terminals, so questions
+// can be asked without surprising scripts
+func Terminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// readLine returns the next answer without surrounding spaces
+func (p *Prompter) readLine() string {
+	line, err := p.in.ReadString('\n')
+	if err != nil {
+		p.eof = true
+	}
+	return strings.TrimSpace(line)
+}
+
+// String asks for a value. An empty answer means defaultValue, and answers
+// are asked again until validator accepts them. Once the input ends the
+// default is returned.
+func (p *Prompter) String(question, defaultValue string, validator func(string) error) string {
+	for {
+		fmt.Fprintf(p.out, "%s %s", cyan("?"), question)
+		if defaultValue != "" {
+			fmt.Fprintf(p.out, " (default: %s)", defaultValue)
+		}
+		fmt.Fprint(p.out, ": ")
+
+		input := p.readLine()
+		if input == "" {
+			input = defaultValue
+		}
+		if validator != nil && !p.eof {
+			if err := validator(input); err != nil {
+				fmt.Fprintf(p.out, "%s %s\n", red("✗"), err.Error())
+				continue
+			}
+		}
+		return input
+	}
+}
+
+// Optional asks for a value that may be left empty. A default is cleared
+// by answering -.
+func (p *Prompter) Optional(question, defaultValue string, validator func(string) error) string {
+	if defaultValue == "" {
+		question += " (optional)"
+	} else {
+		question += " (- to clear)"
+	}
+	answer := p.String(question, defaultValue, func(s string) error {
+		if s == "-" || validator == nil {
+			return nil
+		}
+		return validator(s)
+	})
+	if answer == "-" {
+		return ""
+	}
+	return answer
+}
+
+// Done reports whether the input has ended, so that loops of questions
+// can stop
+func (p *Prompter) Done() bool {
+	return p.eof
+}
+
+// Bool asks a yes or no question
+func (p *Prompter) Bool(question string, defaultValue bool) bool {
+	fmt.Fprintf(p.out, "%s %s (Y/n)", cyan("?"), question)
+	if defaultValue {
+		fmt.Fprint(p.out, " [Y]: ")
+	} else {
+		fmt.Fprint(p.out, " [n]: ")
+	}
+
+	input := strings.ToLower(p.readLine())
+	if input == "" {
+		return defaultValue
+	}
+	return input == "y" || input == "yes" || input == "true" || input == "1"
+}
+
+// Select asks for one of options, by number or by name
+func (p *Prompter) Select(question string, options []string, defaultValue string) string {
+	fmt.Fprintf(p.out, "%s %s\n", cyan("?"), question)
+	for i, option := range options {
+		fmt.Fprintf(p.out, "  %d) %s", i+1, option)
+		if option == defaultValue {
+			fmt.Fprint(p.out, " (default)")
+		}
+		fmt.Fprintln(p.out)
+	}
+	fmt.Fprint(p.out, ": ")
+
+	input := p.readLine()
+	if input == "" {
+		return defaultValue
+	}
+
+	// Try to parse as number
+	if num, err := strconv.Atoi(input); err == nil && num >= 1 && num <= len(options) {
+		return options[num-1]
+	}
+
+	// Check if input matches any option
+	for _, option := range options {
+		if strings.EqualFold(option, input) {
+			return option
+		}
+	}
+
+	// Return default if input doesn't match
+	return defaultValue
+}
@@ -0,0 +1,41 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrompter(t *testing.T) {
+	var out bytes.Buffer
+	p := New(strings.NewReader("bad\ngood\n-\n2\n\nno\n"), &out)
+
+	answer := p.String("Name", "", func(s string) error {
+		if s == "bad" {
+			return errors.New("not that one")
+		}
+		return nil
+	})
+	if answer != "good" || !strings.Contains(out.String(), "not that one") {
+		t.Errorf("Expected the invalid answer to be asked again, got %q", answer)
+	}
+	if answer := p.Optional("Prefix", "old_", nil); answer != "" {
+		t.Errorf("Expected - to clear the default, got %q", answer)
+	}
+	if answer := p.Select("Mode", []string{"a", "b"}, "a"); answer != "b" {
+		t.Errorf("Expected the second option, got %q", answer)
+	}
+	if answer := p.Select("Mode", []string{"a", "b"}, "a"); answer != "a" {
+		t.Errorf("Expected the default, got %q", answer)
+	}
+	if p.Bool("Continue", true) || p.Done() {
+		t.Error("Expected no, with input left")
+	}
+
+	// Once the input ends defaults are returned without validation
+	answer = p.String("Name", "x", func(string) error { return errors.New("never valid") })
+	if answer != "x" || !p.Done() {
+		t.Errorf("Expected the default at the end of the input, got %q", answer)
+	}
+}
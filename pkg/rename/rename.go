@@ -0,0 +1,260 @@
+// Package rename rewrites file names in a directory by removing patterns,
+// prefixes and suffixes or by applying a regular expression.
+package rename
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+// Skip reasons reported in Change.Reason and Skipped events
+const (
+	ReasonUnchanged = "no change"
+	ReasonExists    = "target already exists"
+)
+
+// ErrNoRules is returned when no renaming rule is set
+var ErrNoRules = errors.New("at least one renaming rule must be specified")
+
+// PatternError reports a regular expression that does not compile
+type PatternError struct {
+	Pattern string
+	Err     error
+}
+
+func (e *PatternError) Error() string {
+	return fmt.Sprintf("invalid regex pattern: %v", e.Err)
+}
+
+func (e *PatternError) Unwrap() error {
+	return e.Err
+}
+
+// DirError reports a directory that cannot be renamed in
+type DirError struct {
+	Dir string
+}
+
+func (e *DirError) Error() string {
+	return fmt.Sprintf("directory does not exist: %s", e.Dir)
+}
+
+// Rules describe how a file name is rewritten. They apply in order: regex
+// replacement, pattern removal, prefix removal and suffix removal.
+type Rules struct {
+	Regex       *regexp.Regexp
+	Replacement string // empty removes whatever Regex matches
+	Pattern     string // removed anywhere in the name
+	Prefix      string
+	Suffix      string
+}
+
+// NewRules compiles regex, if set, and returns the rules
+func NewRules(pattern, prefix, suffix, regex, replacement string) (Rules, error) {
+	rules := Rules{Replacement: replacement, Pattern: pattern, Prefix: prefix, Suffix: suffix}
+	if regex != "" {
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			return rules, &PatternError{Pattern: regex, Err: err}
+		}
+		rules.Regex = re
+	}
+	return rules, nil
+}
+
+// Empty reports whether the rules leave every name alone
+func (r Rules) Empty() bool {
+	return r.Regex == nil && r.Pattern == "" && r.Prefix == "" && r.Suffix == ""
+}
+
+// Apply returns the new name for filename
+func (r Rules) Apply(filename string) string {
+	result := filename
+	if r.Regex != nil {
+		result = r.Regex.ReplaceAllString(result, r.Replacement)
+	}
+	if r.Pattern != "" {
+		result = strings.ReplaceAll(result, r.Pattern, "")
+	}
+	if r.Prefix != "" {
+		result = strings.TrimPrefix(result, r.Prefix)
+	}
+	if r.Suffix != "" {
+		result = strings.TrimSuffix(result, r.Suffix)
+	}
+	return result
+}
+
+// Options controls a rename run
+type Options struct {
+	Dir       string
+	Rules     Rules
+	Recursive bool         // also rename files in subdirectories
+	Force     bool         // overwrite existing targets
+	DryRun    bool         // report what would be renamed without touching files
+	FS        fsys.WriteFS // holds Dir, nil renames on the operating system
+	OnEvent   event.Handler
+}
+
+// Validate checks that the directory exists and that a rule is set
+func (o Options) Validate() error {
+	if _, err := fsys.OrWrite(o.FS).Stat(o.Dir); errors.Is(err, fs.ErrNotExist) {
+		return &DirError{Dir: o.Dir}
+	}
+	if o.Rules.Empty() {
+		return ErrNoRules
+	}
+	return nil
+}
+
+// Status is the outcome for a single file
+type Status string
+
+const (
+	Renamed   Status = "renamed" // renamed, or would be in a dry run
+	Unchanged Status = "unchanged"
+	Exists    Status = "exists" // skipped because the target exists
+	Failed    Status = "failed"
+)
+
+// Change is the outcome for a single file
+type Change struct {
+	Dir    string
+	Old    string
+	New    string
+	Status Status
+	Err    error
+}
+
+// OldPath returns the path of the file before renaming
+func (c Change) OldPath() string {
+	return filepath.Join(c.Dir, c.Old)
+}
+
+// NewPath returns the path of the file after renaming
+func (c Change) NewPath() string {
+	return filepath.Join(c.Dir, c.New)
+}
+
+// Result lists every file looked at
+type Result struct {
+	Changes []Change
+	Renamed int // files renamed, or that would be in a dry run
+}
+
+// Count returns the number of files with the given status
+func (r *Result) Count(status Status) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Run renames the files of opts.Dir. A file that fails to rename is reported
+// and skipped; the returned error is for problems that stop the run.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts.FS = fsys.OrWrite(opts.FS)
+	result := &Result{}
+	err := eachFile(ctx, opts, func(dir, name string) {
+		change := Change{Dir: dir, Old: name, New: opts.Rules.Apply(name), Status: Renamed}
+		switch {
+		case change.New == name:
+			change.Status = Unchanged
+			opts.OnEvent.Emit(event.Event{Kind: event.Skipped, Path: change.OldPath(), Reason: ReasonUnchanged})
+		case !opts.Force && exists(opts.FS, change.NewPath()):
+			change.Status = Exists
+			opts.OnEvent.Emit(event.Event{Kind: event.Skipped, Path: change.OldPath(),
+				Target: change.NewPath(), Reason: ReasonExists})
+		case !opts.DryRun:
+			if err := opts.FS.Rename(change.OldPath(), change.NewPath()); err != nil {
+				change.Status, change.Err = Failed, err
+				opts.OnEvent.Emit(event.Event{Kind: event.Error, Path: change.OldPath(), Err: err})
+			}
+		}
+		if change.Status == Renamed {
+			result.Renamed++
+			opts.OnEvent.Emit(event.Event{Kind: event.Renamed, Path: change.OldPath(), Target: change.NewPath()})
+		}
+		result.Changes = append(result.Changes, change)
+	})
+	return result, err
+}
+
+// Plan returns the changes Run would make without renaming anything
+func Plan(ctx context.Context, opts Options) ([]Change, error) {
+	opts.DryRun = true
+	opts.OnEvent = nil
+	result, err := Run(ctx, opts)
+	if result == nil {
+		return nil, err
+	}
+	return result.Changes, err
+}
+
+// eachFile calls fn for every file of opts.Dir, and of its subdirectories
+// when opts.Recursive is set
+func eachFile(ctx context.Context, opts Options, fn func(dir, name string)) error {
+	if !opts.Recursive {
+		entries, err := opts.FS.ReadDir(opts.Dir)
+		if err != nil {
+			return fmt.Errorf("failed to read directory: %v", err)
+		}
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !entry.IsDir() {
+				fn(opts.Dir, entry.Name())
+			}
+		}
+		return nil
+	}
+
+	err := walkDir(ctx, opts.FS, opts.Dir, fn)
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error walking directory tree: %v", err)
+	}
+	return err
+}
+
+// walkDir calls fn for every file below dir in lexical order, like
+// filepath.Walk. Entries are listed before any of them is renamed.
+func walkDir(ctx context.Context, src fsys.FS, dir string, fn func(dir, name string)) error {
+	entries, err := src.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if err := walkDir(ctx, src, filepath.Join(dir, entry.Name()), fn); err != nil {
+				return err
+			}
+			continue
+		}
+		fn(dir, entry.Name())
+	}
+	return nil
+}
+
+func exists(src fsys.FS, path string) bool {
+	_, err := src.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,101 @@
+package rename
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bhangun/coto/pkg/fsys"
+)
+
+func TestRulesApply(t *testing.T) {
+	rules, err := NewRules("_draft", "old_", ".bak", `^\d+-`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules.Apply("2024-old_notes_draft.txt.bak"); got != "notes.txt" {
+		t.Errorf("Expected notes.txt, got %s", got)
+	}
+
+	var patternErr *PatternError
+	if _, err := NewRules("", "", "", "[", ""); !errors.As(err, &patternErr) {
+		t.Errorf("Expected a PatternError, got %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"x_a.txt", "x_b.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := Options{Dir: dir, Rules: Rules{Prefix: "x_"}}
+
+	changes, err := Plan(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]Status)
+	for _, c := range changes {
+		statuses[c.Old] = c.Status
+	}
+	expected := map[string]Status{"x_a.txt": Renamed, "x_b.txt": Exists, "b.txt": Unchanged, "c.txt": Unchanged}
+	for name, status := range expected {
+		if statuses[name] != status {
+			t.Errorf("%s: expected %s, got %s", name, status, statuses[name])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "x_a.txt")); err != nil {
+		t.Error("Plan must not rename files")
+	}
+
+	result, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Renamed != 1 || result.Count(Exists) != 1 || result.Count(Unchanged) != 2 {
+		t.Errorf("Expected 1 rename, 1 existing and 2 unchanged files, got %+v", result.Changes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); err != nil {
+		t.Errorf("Expected a.txt: %v", err)
+	}
+
+	if _, err := Run(context.Background(), Options{Dir: dir}); !errors.Is(err, ErrNoRules) {
+		t.Errorf("Expected ErrNoRules, got %v", err)
+	}
+	var dirErr *DirError
+	if _, err := Run(context.Background(), Options{Dir: filepath.Join(dir, "missing"), Rules: opts.Rules}); !errors.As(err, &dirErr) {
+		t.Errorf("Expected a DirError, got %v", err)
+	}
+}
+
+func TestRun_FS(t *testing.T) {
+	m := fsys.MapFS{
+		"docs/draft_a.md":     {Data: []byte("a")},
+		"docs/sub/draft_b.md": {Data: []byte("b")},
+		"docs/sub/c.md":       {Data: []byte("c")},
+		"other/draft_d.md":    {Data: []byte("d")},
+	}
+	opts := Options{Dir: "docs", Rules: Rules{Prefix: "draft_"}, Recursive: true, FS: m}
+
+	result, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Renamed != 2 {
+		t.Errorf("Expected 2 renames, got %d", result.Renamed)
+	}
+	for _, name := range []string{"docs/a.md", "docs/sub/b.md", "docs/sub/c.md", "other/draft_d.md"} {
+		if _, err := m.Stat(name); err != nil {
+			t.Errorf("Expected %s: %v", name, err)
+		}
+	}
+
+	var dirErr *DirError
+	if _, err := Run(context.Background(), Options{Dir: "missing", Rules: opts.Rules, FS: m}); !errors.As(err, &dirErr) {
+		t.Errorf("Expected a DirError, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+// Package runlog reports what a command did as JSON lines, one object per
+// event and a final summary, and maps the outcome of a run to a stable exit
+// code so scripts do not have to scrape the terminal output.
+package runlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/interrupt"
+)
+
+// Exit codes of every command. A canceled run exits with
+// interrupt.ExitInterrupted or interrupt.ExitTimeout.
+const (
+	ExitOK      = 0 // everything was done
+	ExitFailure = 1 // the run stopped on an error
+	ExitUsage   = 2 // invalid flags, arguments or configuration
+	ExitPartial = 3 // the run finished but some files failed
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// UsageError reports invalid flags, arguments or configuration
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// Usage marks err as a usage error; nil stays nil
+func Usage(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &UsageError{Err: err}
+}
+
+// PartialError reports a run that finished although some files failed
+type PartialError struct {
+	Failed int
+}
+
+func (e *PartialError) Error() string {
+	if e.Failed == 1 {
+		return "1 file failed"
+	}
+	return fmt.Sprintf("%d files failed", e.Failed)
+}
+
+// Partial returns a PartialError for failed files, nil when none failed
+func Partial(failed int) error {
+	if failed == 0 {
+		return nil
+	}
+	return &PartialError{Failed: failed}
+}
+
+// ExitCode returns the exit status of a run that ended with err
+func ExitCode(err error) int {
+	var usageErr *UsageError
+	var partialErr *PartialError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &usageErr):
+		return ExitUsage
+	case errors.As(err, &partialErr):
+		return ExitPartial
+	}
+	return interrupt.ExitCode(err)
+}
+
+// Status names the outcome of a run in the summary record
+func Status(err error) string {
+	switch ExitCode(err) {
+	case ExitOK:
+		return "success"
+	case ExitUsage:
+		return "usage"
+	case ExitPartial:
+		return "partial"
+	case interrupt.ExitInterrupted:
+		return "interrupted"
+	case interrupt.ExitTimeout:
+		return "timeout"
+	}
+	return "failed"
+}
+
+// Record is a single line of the log
+type Record struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Event   string    `json:"event"`
+	Path    string    `json:"path,omitempty"`
+	Dir     bool      `json:"dir,omitempty"`
+	Target  string    `json:"target,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	Done    int       `json:"done,omitempty"`
+	Total   int       `json:"total,omitempty"`
+
+	// Set on the summary only
+	Status   string `json:"status,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Stats    any    `json:"stats,omitempty"`
+}
+
+// Summary is the event name of the last record of a run
+const Summary = "summary"
+
+// Logger writes records as JSON lines. A nil Logger discards everything, so
+// commands call it without checking whether JSON logging is enabled.
+type Logger struct {
+	command string
+	mu      sync.Mutex
+	enc     *json.Encoder
+	file    *os.File // closed by Close, nil for stderr
+	stderr  bool
+}
+
+// New returns a Logger writing the records of command to w
+func New(w io.Writer, command string) *Logger {
+	return &Logger{command: command, enc: json.NewEncoder(w)}
+}
+
+// Open returns the Logger for a -log-format and -log-file pair: nil for the
+// text format, else one writing to path, or to stderr when path is empty
+func Open(command, format, path string) (*Logger, error) {
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return nil, nil
+	case FormatJSON:
+	default:
+		return nil, Usage(fmt.Errorf("unknown log format %q (use text or json)", format))
+	}
+
+	if path == "" {
+		l := New(os.Stderr, command)
+		l.stderr = true
+		return l, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+	l := New(file, command)
+	l.file = file
+	return l, nil
+}
+
+// Stderr reports whether the records go to stderr, which then belongs to
+// the log and should not receive plain text messages
+func (l *Logger) Stderr() bool {
+	return l != nil && l.stderr
+}
+
+// Handler returns a handler that logs every event and passes it on to next
+func (l *Logger) Handler(next event.Handler) event.Handler {
+	if l == nil {
+		return next
+	}
+	return func(e event.Event) {
+		r := Record{Event: string(e.Kind), Path: e.Path, Dir: e.Dir, Target: e.Target,
+			Reason: e.Reason, Size: e.Size, Done: e.Done, Total: e.Total}
+		if e.Err != nil {
+			r.Error = e.Err.Error()
+		}
+		l.write(r)
+		next.Emit(e)
+	}
+}
+
+// Finish writes the summary of a run that ended with err and returns err.
+// stats is encoded as is and should carry JSON tags. When the log goes to
+// stderr the error is marked so that Logged reports it.
+func (l *Logger) Finish(err error, stats any) error {
+	if l == nil {
+		return err
+	}
+	code := ExitCode(err)
+	r := Record{Event: Summary, Status: Status(err), ExitCode: &code, Stats: stats}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	l.write(r)
+	if err != nil && l.stderr {
+		return &loggedError{err}
+	}
+	return err
+}
+
+// loggedError is an error that was already written to the log on stderr
+type loggedError struct {
+	error
+}
+
+func (e *loggedError) Unwrap() error {
+	return e.error
+}
+
+// Logged reports whether err was written to a log on stderr, where printing
+// it again would break the JSON lines
+func Logged(err error) bool {
+	var logged *loggedError
+	return errors.As(err, &logged)
+}
+
+// Close closes the log file
+func (l *Logger) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+func (l *Logger) write(r Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r.Time = time.Now().UTC()
+	r.Command = l.command
+	// A log that cannot be written must not fail the run it describes
+	_ = l.enc.Encode(r)
+}
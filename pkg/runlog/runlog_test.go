@@ -0,0 +1,71 @@
+package runlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bhangun/coto/pkg/event"
+	"github.com/bhangun/coto/pkg/interrupt"
+)
+
+func TestExitCode(t *testing.T) {
+	cases := map[error]int{
+		nil:                      ExitOK,
+		errors.New("boom"):       ExitFailure,
+		Usage(errors.New("bad")): ExitUsage,
+		Partial(2):               ExitPartial,
+		context.DeadlineExceeded: interrupt.ExitTimeout,
+		fmt.Errorf("wrapped: %w", Usage(errors.New("bad"))): ExitUsage,
+	}
+	for err, want := range cases {
+		if got := ExitCode(err); got != want {
+			t.Errorf("ExitCode(%v) = %d, want %d", err, got, want)
+		}
+	}
+	if Partial(0) != nil || Usage(nil) != nil {
+		t.Error("Expected nil for no failures")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, "combine")
+	var forwarded int
+	handler := log.Handler(func(event.Event) { forwarded++ })
+	handler(event.Event{Kind: event.Skipped, Path: "a.md", Reason: "extension"})
+	handler(event.Event{Kind: event.Error, Path: "b.go", Err: errors.New("denied")})
+	if err := log.Finish(Partial(1), map[string]int{"files_processed": 3}); ExitCode(err) != ExitPartial || Logged(err) {
+		t.Errorf("Expected the partial error to be returned unmarked, got %v", err)
+	}
+
+	var records []Record
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r Record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if forwarded != 2 || len(records) != 3 {
+		t.Fatalf("Expected 2 forwarded events and 3 records, got %d and %+v", forwarded, records)
+	}
+	if r := records[0]; r.Command != "combine" || r.Event != "skipped" || r.Reason != "extension" {
+		t.Errorf("Unexpected event record %+v", r)
+	}
+	if records[1].Error != "denied" {
+		t.Errorf("Expected the error message, got %+v", records[1])
+	}
+	if r := records[2]; r.Event != Summary || r.Status != "partial" || r.ExitCode == nil || *r.ExitCode != ExitPartial {
+		t.Errorf("Unexpected summary %+v", r)
+	}
+
+	var none *Logger
+	if none.Handler(nil) != nil || none.Finish(nil, nil) != nil || none.Close() != nil {
+		t.Error("A nil Logger must do nothing")
+	}
+}
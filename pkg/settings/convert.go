@@ -0,0 +1,121 @@
+package settings
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// convert turns a value read from a file, the environment or a flag into
+// the type t. Strings are parsed, so environment variables work for every
+// type, and lists may be written as comma-separated strings.
+func convert(value any, t reflect.Type) (any, error) {
+	if t == durationType {
+		switch v := value.(type) {
+		case time.Duration:
+			return v, nil
+		case string:
+			return time.ParseDuration(v)
+		}
+		return nil, fmt.Errorf("expected a duration such as \"90s\", got %v", value)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case bool, int, int64, uint64, float64:
+			return fmt.Sprint(v), nil
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	case reflect.Int, reflect.Int64:
+		n, err := toInt(value)
+		if err != nil {
+			return nil, err
+		}
+		if t.Kind() == reflect.Int {
+			return int(n), nil
+		}
+		return n, nil
+	case reflect.Float64:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int, int64:
+			n, _ := toInt(v)
+			return float64(n), nil
+		case string:
+			return strconv.ParseFloat(v, 64)
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.String {
+			break
+		}
+		switch v := value.(type) {
+		case []string:
+			return v, nil
+		case string:
+			if v == "" {
+				return []string{}, nil
+			}
+			return strings.Split(v, ","), nil
+		case []any:
+			list := make([]string, len(v))
+			for i, item := range v {
+				s, err := convert(item, reflect.TypeOf(""))
+				if err != nil {
+					return nil, err
+				}
+				list[i] = s.(string)
+			}
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("expected a %s, got %v", typeName(t), value)
+}
+
+func toInt(value any) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("%d is too large", v)
+		}
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("expected a whole number, got %v", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("expected a number, got %v", value)
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice:
+		return "list"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "number"
+	}
+	return t.Kind().String()
+}
@@ -0,0 +1,281 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFiles are the names of a project file, looked for in this order
+var ProjectFiles = []string{".coto.json", ".coto.yaml", ".coto.yml", ".coto.toml"}
+
+// userFiles are the names of the user file in the user directory
+var userFiles = []string{"config", "config.json", "config.yaml", "config.yml", "config.toml"}
+
+// sections are top level keys that are not settings of combine: the
+// sections of the other commands and the profiles
+var sections = map[string]bool{"extract": true, "rename": true, "profiles": true}
+
+// EnvPrefix starts the name of every environment variable read
+const EnvPrefix = "COTO_"
+
+// Loader reads the layers of one command
+type Loader struct {
+	Section string // "" for combine, else "extract" or "rename"
+	Schema  Schema
+	Dir     string // where the search for a project file starts, "" for the working directory
+	File    string // file given with -config, read after the project file
+	Profile string // profile applied over the files, "" for none
+
+	// UserDir holds the user file, "" for $XDG_CONFIG_HOME/coto or ~/.config/coto
+	UserDir string
+	// Environ lists the environment as KEY=value, nil for os.Environ
+	Environ []string
+}
+
+// Load merges every layer. Defaults are the values of the bound flags that
+// were not set, and flags given on the command line override everything.
+func (l Loader) Load(fs *flag.FlagSet, bindings []Binding) (*Settings, error) {
+	s := newSettings(l.Schema)
+	set := setFlags(fs)
+
+	for _, b := range bindings {
+		if _, ok := s.values[b.Key]; ok || set[b.Flag] {
+			continue
+		}
+		if err := s.set(b.Key, fs.Lookup(b.Flag).Value.(flag.Getter).Get(), Default, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := l.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		values, err := l.section(f.values, f.path)
+		if err == nil {
+			err = s.setAll(values, f.source, f.path)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if l.Profile != "" {
+		profiles, err := parseProfiles(files)
+		if err != nil {
+			return nil, err
+		}
+		chain, err := profiles.resolve(l.Profile)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range chain {
+			values, err := l.section(p.values, p.Origin)
+			if err == nil {
+				err = s.setAll(values, ProfileSource, p.Name)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+			}
+		}
+	}
+
+	for _, v := range l.env() {
+		if err := s.set(v.Key, v.Value, Env, v.Origin); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, b := range bindings {
+		if set[b.Flag] {
+			if err := s.set(b.Key, fs.Lookup(b.Flag).Value.(flag.Getter).Get(), Flag, "-"+b.Flag); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// file is a parsed configuration file
+type file struct {
+	path   string
+	source Source
+	values map[string]any
+}
+
+// files reads the user file, the project file and the -config file, in
+// that order
+func (l Loader) files() ([]file, error) {
+	var files []file
+
+	userDir, err := l.userDir()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range userFiles {
+		path := filepath.Join(userDir, name)
+		values, err := ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{path, User, values})
+		break
+	}
+
+	if path, err := FindProject(l.Dir); err != nil {
+		return nil, err
+	} else if path != "" {
+		values, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{path, Project, values})
+	}
+
+	if l.File != "" {
+		values, err := ReadFile(l.File)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("config file not found: %s", l.File)
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{l.File, File, values})
+	}
+	return files, nil
+}
+
+// FindProject returns the nearest project file in dir or one of its parents,
+// or "" if there is none
+func FindProject(dir string) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range ProjectFiles {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// UserDir returns the directory of the user file
+func UserDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "coto"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "coto"), nil
+}
+
+func (l Loader) userDir() (string, error) {
+	if l.UserDir != "" {
+		return l.UserDir, nil
+	}
+	return UserDir()
+}
+
+// section returns the keys of the loader's command
+func (l Loader) section(all map[string]any, path string) (map[string]any, error) {
+	if l.Section == "" {
+		values := make(map[string]any, len(all))
+		for key, value := range all {
+			if !sections[key] {
+				values[key] = value
+			}
+		}
+		return values, nil
+	}
+	switch values := all[l.Section].(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		return values, nil
+	}
+	return nil, fmt.Errorf("%s: %s must be a table of settings", path, l.Section)
+}
+
+// env returns the values of the loader's environment variables, with the
+// variable as origin
+func (l Loader) env() []Value {
+	environ := l.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	prefix := EnvPrefix
+	if l.Section != "" {
+		prefix += strings.ToUpper(l.Section) + "_"
+	}
+	var values []Value
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		key := strings.ToLower(strings.TrimPrefix(name, prefix))
+		if _, known := l.Schema[key]; known {
+			values = append(values, Value{Key: key, Value: value, Origin: name})
+		}
+	}
+	return values
+}
+
+// ReadFile parses a JSON, YAML or TOML file, chosen by its extension. Other
+// files are read as JSON when they start with "{", else as TOML and then as
+// YAML.
+func ReadFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			err = json.Unmarshal(data, &values)
+		} else if err = toml.Unmarshal(data, &values); err != nil {
+			values = make(map[string]any)
+			if yaml.Unmarshal(data, &values) == nil {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
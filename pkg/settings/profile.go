@@ -0,0 +1,106 @@
+package settings
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProfilesKey is the top level key holding the profiles of a file
+const ProfilesKey = "profiles"
+
+// Profile is a named set of settings that may extend another profile. A
+// profile defined again in a later file replaces the earlier one.
+type Profile struct {
+	Name        string
+	Extends     string
+	Description string
+	Source      Source // layer of the file that defines the profile
+	Origin      string // path of that file
+
+	values map[string]any
+}
+
+// profiles maps names to profiles
+type profiles map[string]Profile
+
+// Profiles returns the profiles of the user, project and -config files
+// sorted by name
+func (l Loader) Profiles() ([]Profile, error) {
+	files, err := l.files()
+	if err != nil {
+		return nil, err
+	}
+	found, err := parseProfiles(files)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Profile, 0, len(found))
+	for _, p := range found {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// parseProfiles collects the profiles of files, later files winning
+func parseProfiles(files []file) (profiles, error) {
+	found := make(profiles)
+	for _, f := range files {
+		table, ok := f.values[ProfilesKey]
+		if !ok {
+			continue
+		}
+		all, ok := table.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s must be a table of profiles", f.path, ProfilesKey)
+		}
+		for name, v := range all {
+			values, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: profile %s must be a table of settings", f.path, name)
+			}
+			p := Profile{Name: name, Source: f.source, Origin: f.path, values: make(map[string]any, len(values))}
+			for key, value := range values {
+				switch key {
+				case "extends":
+					p.Extends = fmt.Sprint(value)
+				case "description":
+					p.Description = fmt.Sprint(value)
+				default:
+					p.values[key] = value
+				}
+			}
+			found[name] = p
+		}
+	}
+	return found, nil
+}
+
+// resolve returns the profile name preceded by the profiles it extends,
+// the most basic first
+func (found profiles) resolve(name string) ([]Profile, error) {
+	var chain []Profile
+	seen := make(map[string]bool)
+	for name != "" {
+		if seen[name] {
+			names := make([]string, 0, len(chain)+1)
+			for i := len(chain) - 1; i >= 0; i-- {
+				names = append(names, chain[i].Name)
+			}
+			return nil, fmt.Errorf("profile %s extends itself: %s", name, strings.Join(append(names, name), " → "))
+		}
+		seen[name] = true
+
+		p, ok := found[name]
+		if !ok {
+			if len(chain) > 0 {
+				return nil, fmt.Errorf("profile %s extends unknown profile %q", chain[0].Name, name)
+			}
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+		chain = append([]Profile{p}, chain...)
+		name = p.Extends
+	}
+	return chain, nil
+}
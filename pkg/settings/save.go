@@ -0,0 +1,87 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Save writes values into the JSON, YAML or TOML file at path, creating it
+// when needed. Without a profile the values are set at the top level,
+// otherwise they replace the named profile. Other keys of the file are kept,
+// but the file is rewritten, so its comments are lost.
+func Save(path, profile string, values map[string]any) error {
+	if err := CanSave(path); err != nil {
+		return err
+	}
+
+	all, err := ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		all, err = make(map[string]any), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	plain := make(map[string]any, len(values))
+	for key, value := range values {
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		plain[key] = value
+	}
+	if profile == "" {
+		for key, value := range plain {
+			all[key] = value
+		}
+	} else {
+		profiles, ok := all[ProfilesKey].(map[string]any)
+		if !ok {
+			if _, exists := all[ProfilesKey]; exists {
+				return fmt.Errorf("%s: %s must be a table of profiles", path, ProfilesKey)
+			}
+			profiles = make(map[string]any)
+			all[ProfilesKey] = profiles
+		}
+		profiles[profile] = plain
+	}
+
+	var data []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		data, err = json.MarshalIndent(all, "", "  ")
+		data = append(data, '\n')
+	case ".yaml", ".yml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err = enc.Encode(all)
+		data = buf.Bytes()
+	case ".toml":
+		var buf bytes.Buffer
+		err = toml.NewEncoder(&buf).Encode(all)
+		data = buf.Bytes()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// CanSave reports whether Save can write the format of path
+func CanSave(path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml", ".toml":
+		return nil
+	}
+	return fmt.Errorf("cannot write %s: use a .json, .yaml or .toml file", path)
+}
@@ -0,0 +1,229 @@
+// Package settings merges the configuration of a command from several
+// layers and remembers where each value came from. From lowest to highest
+// precedence the layers are the built-in defaults, the user file in
+// ~/.config/coto, the nearest project .coto.{json,yaml,toml} file, a file
+// given with -config, the selected profile, COTO_* environment variables
+// and command line flags.
+//
+// Keys are the snake_case names of the settings. The combine command uses
+// the top level of a file, extract and rename read their own section:
+//
+//	extensions: [.go, .md]
+//	exclude_generated: true
+//	extract:
+//	  output: extracted
+//	rename:
+//	  recursive: true
+//
+// Named profiles hold settings in the same shape and may extend another
+// profile:
+//
+//	profiles:
+//	  backend:
+//	    description: Go services
+//	    input_dirs: [server]
+//	  review:
+//	    extends: backend
+//	    newest: 20
+package settings
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Source is the layer a value came from
+type Source string
+
+// Layers from lowest to highest precedence
+const (
+	Default       Source = "default"
+	User          Source = "user"
+	Project       Source = "project"
+	File          Source = "file"    // -config
+	ProfileSource Source = "profile" // -profile
+	Env           Source = "env"
+	Flag          Source = "flag"
+)
+
+// Value is the resolved value of a key
+type Value struct {
+	Key    string
+	Value  any
+	Source Source
+	Origin string // file, environment variable or flag that set the value
+}
+
+// Schema maps every key to the Go type of its value
+type Schema map[string]reflect.Type
+
+// StructSchema returns the keys of the JSON tags of the struct v
+func StructSchema(v any) Schema {
+	schema := make(Schema)
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema[name] = field.Type
+	}
+	return schema
+}
+
+// Binding ties a command line flag to a key
+type Binding struct {
+	Flag string
+	Key  string
+}
+
+// FlagBindings binds every flag of fs except skip to a key of the same name,
+// with dashes turned into underscores, and returns them with their schema
+func FlagBindings(fs *flag.FlagSet, skip ...string) ([]Binding, Schema) {
+	var bindings []Binding
+	schema := make(Schema)
+	fs.VisitAll(func(f *flag.Flag) {
+		for _, name := range skip {
+			if f.Name == name {
+				return
+			}
+		}
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			return
+		}
+		key := strings.ReplaceAll(f.Name, "-", "_")
+		bindings = append(bindings, Binding{Flag: f.Name, Key: key})
+		schema[key] = reflect.TypeOf(getter.Get())
+	})
+	return bindings, schema
+}
+
+// Settings is the merged configuration of a command
+type Settings struct {
+	schema Schema
+	values map[string]Value
+}
+
+func newSettings(schema Schema) *Settings {
+	return &Settings{schema: schema, values: make(map[string]Value)}
+}
+
+// set converts value to the type of key and records it with its source
+func (s *Settings) set(key string, value any, source Source, origin string) error {
+	t, ok := s.schema[key]
+	if !ok {
+		return fmt.Errorf("%s: unknown setting %q", origin, key)
+	}
+	converted, err := convert(value, t)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", origin, key, err)
+	}
+	s.values[key] = Value{Key: key, Value: converted, Source: source, Origin: origin}
+	return nil
+}
+
+// setAll records every value of a layer, in key order so errors are stable
+func (s *Settings) setAll(values map[string]any, source Source, origin string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := s.set(key, values[key], source, origin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the value of key
+func (s *Settings) Get(key string) (Value, bool) {
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Values returns every value sorted by key
+func (s *Settings) Values() []Value {
+	values := make([]Value, 0, len(s.values))
+	for _, v := range s.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values
+}
+
+// Decode stores the values in the struct pointed to by v, matching keys
+// with its JSON tags
+func (s *Settings) Decode(v any) error {
+	values := make(map[string]any, len(s.values))
+	for key, value := range s.values {
+		values[key] = value.Value
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Apply sets every flag of bindings that was not given on the command line
+// to its configured value, so that commands can keep reading their flags
+func (s *Settings) Apply(fs *flag.FlagSet, bindings []Binding) error {
+	set := setFlags(fs)
+	for _, b := range bindings {
+		v, ok := s.values[b.Key]
+		if !ok || set[b.Flag] || v.Source == Default {
+			continue
+		}
+		if err := fs.Set(b.Flag, Format(v.Value)); err != nil {
+			return fmt.Errorf("%s: %s: %w", v.Origin, b.Key, err)
+		}
+	}
+	return nil
+}
+
+// Fprint writes a table of every value and where it came from
+func (s *Settings) Fprint(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, v := range s.Values() {
+		source := string(v.Source)
+		if v.Origin != "" {
+			source += " (" + v.Origin + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", v.Key, Format(v.Value), source)
+	}
+	return tw.Flush()
+}
+
+// Format returns value as it would be written on the command line
+func Format(value any) string {
+	if list, ok := value.([]string); ok {
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// setFlags returns the flags given on the command line
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
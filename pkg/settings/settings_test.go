@@ -0,0 +1,227 @@
+package settings
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type options struct {
+	Output     string   `json:"output_file"`
+	Extensions []string `json:"extensions"`
+	Parallel   int      `json:"parallel"`
+	MaxSize    int64    `json:"max_file_size"`
+	DryRun     bool     `json:"dry_run"`
+	Verbose    bool     `json:"verbose"`
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_Layers(t *testing.T) {
+	root := t.TempDir()
+	userDir := filepath.Join(root, "home")
+	writeFile(t, filepath.Join(userDir, "config"), "parallel = 2\nverbose = true\noutput_file = \"user.txt\"\n")
+	writeFile(t, filepath.Join(root, "proj", ".coto.yaml"), "output_file: project.txt\nextensions: [.go, .md]\nextract:\n  output: out\n")
+	writeFile(t, filepath.Join(root, "explicit.json"), `{"max_file_size": 1000}`)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("output", "combined.txt", "")
+	fs.String("ext", "", "")
+	fs.Int("parallel", 1, "")
+	fs.Bool("dry-run", false, "")
+	fs.Bool("verbose", false, "")
+	if err := fs.Parse([]string{"-dry-run"}); err != nil {
+		t.Fatal(err)
+	}
+	bindings := []Binding{
+		{Flag: "output", Key: "output_file"}, {Flag: "ext", Key: "extensions"},
+		{Flag: "parallel", Key: "parallel"}, {Flag: "dry-run", Key: "dry_run"}, {Flag: "verbose", Key: "verbose"},
+	}
+
+	loader := Loader{
+		Schema:  StructSchema(options{}),
+		Dir:     filepath.Join(root, "proj", "src", "deep"),
+		File:    filepath.Join(root, "explicit.json"),
+		UserDir: userDir,
+		Environ: []string{"COTO_PARALLEL=8", "COTO_EXTRACT_OUTPUT=ignored", "COTO_UNKNOWN=1"},
+	}
+	s, err := loader.Load(fs, bindings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts options
+	if err := s.Decode(&opts); err != nil {
+		t.Fatal(err)
+	}
+	want := options{Output: "project.txt", Extensions: []string{".go", ".md"}, Parallel: 8, MaxSize: 1000, DryRun: true, Verbose: true}
+	if strings.Join(opts.Extensions, ",") != ".go,.md" || opts.Output != want.Output || opts.Parallel != want.Parallel ||
+		opts.MaxSize != want.MaxSize || opts.DryRun != want.DryRun || opts.Verbose != want.Verbose {
+		t.Errorf("Expected %+v, got %+v", want, opts)
+	}
+
+	sources := map[string]Source{"output_file": Project, "extensions": Project, "parallel": Env,
+		"max_file_size": File, "dry_run": Flag, "verbose": User}
+	for key, source := range sources {
+		if v, _ := s.Get(key); v.Source != source {
+			t.Errorf("%s: expected source %s, got %+v", key, source, v)
+		}
+	}
+	if v, _ := s.Get("parallel"); v.Origin != "COTO_PARALLEL" {
+		t.Errorf("Expected the variable as origin, got %q", v.Origin)
+	}
+
+	writeFile(t, filepath.Join(root, "proj", ".coto.yaml"), "output: x\n")
+	if _, err := loader.Load(fs, bindings); err == nil || !strings.Contains(err.Error(), `unknown setting "output"`) {
+		t.Errorf("Expected an unknown setting error, got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".coto.toml"), "parallel = 3\n\n[extract]\noutput = \"blocks\"\ntimeout = \"90s\"\n")
+
+	var output string
+	var timeout time.Duration
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	fs.StringVar(&output, "output", "extracted", "")
+	fs.DurationVar(&timeout, "timeout", 0, "")
+	fs.Bool("help", false, "")
+	if err := fs.Parse([]string{"-output", "mine"}); err != nil {
+		t.Fatal(err)
+	}
+
+	bindings, schema := FlagBindings(fs, "help")
+	loader := Loader{Section: "extract", Schema: schema, Dir: root, UserDir: filepath.Join(root, "none"), Environ: []string{}}
+	s, err := loader.Load(fs, bindings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Apply(fs, bindings); err != nil {
+		t.Fatal(err)
+	}
+	if output != "mine" || timeout != 90*time.Second {
+		t.Errorf("Expected the flag to win and the project timeout, got %q and %v", output, timeout)
+	}
+	if _, ok := s.Get("help"); ok {
+		t.Error("Skipped flags must not be settings")
+	}
+}
+
+func TestLoad_Profiles(t *testing.T) {
+	root := t.TempDir()
+	userDir := filepath.Join(root, "home")
+	writeFile(t, filepath.Join(userDir, "config.toml"), "[profiles.base]\ndescription = \"Shared\"\nparallel = 2\nverbose = true\n")
+	writeFile(t, filepath.Join(root, ".coto.json"), `{
+		"output_file": "project.txt",
+		"profiles": {
+			"backend": {"extends": "base", "extensions": ".go,.sql", "parallel": 4},
+			"a": {"extends": "b"},
+			"b": {"extends": "a"},
+			"broken": {"extends": "missing"}
+		}
+	}`)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("parallel", 1, "")
+	if err := fs.Parse([]string{"-parallel", "6"}); err != nil {
+		t.Fatal(err)
+	}
+	bindings := []Binding{{Flag: "parallel", Key: "parallel"}}
+	loader := Loader{Schema: StructSchema(options{}), Dir: root, UserDir: userDir, Environ: []string{}}
+
+	profiles, err := loader.Profiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Name+":"+p.Extends+":"+string(p.Source))
+	}
+	if got := strings.Join(names, " "); got != "a:b:project b:a:project backend:base:project base::user broken:missing:project" {
+		t.Errorf("Unexpected profiles %s", got)
+	}
+
+	loader.Profile = "backend"
+	s, err := loader.Load(fs, bindings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		key    string
+		source Source
+		origin string
+	}{
+		{"output_file", Project, filepath.Join(root, ".coto.json")},
+		{"extensions", ProfileSource, "backend"},
+		{"verbose", ProfileSource, "base"},
+		{"parallel", Flag, "-parallel"},
+	}
+	for _, c := range checks {
+		if v, _ := s.Get(c.key); v.Source != c.source || v.Origin != c.origin {
+			t.Errorf("%s: expected %s (%s), got %+v", c.key, c.source, c.origin, v)
+		}
+	}
+
+	errors := map[string]string{
+		"a":       "profile a extends itself: a → b → a",
+		"broken":  `profile broken extends unknown profile "missing"`,
+		"unknown": `unknown profile "unknown"`,
+	}
+	for name, want := range errors {
+		loader.Profile = name
+		if _, err := loader.Load(fs, bindings); err == nil || err.Error() != want {
+			t.Errorf("%s: expected %q, got %v", name, want, err)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{".coto.json", ".coto.yaml", ".coto.toml"} {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, map[string]string{
+			".coto.json": `{"verbose": true, "profiles": {"old": {"parallel": 2}}}`,
+			".coto.yaml": "verbose: true\nprofiles:\n  old:\n    parallel: 2\n",
+			".coto.toml": "verbose = true\n[profiles.old]\nparallel = 2\n",
+		}[name])
+
+		if err := Save(path, "", map[string]any{"extensions": []string{".go"}, "parallel": 4}); err != nil {
+			t.Fatal(err)
+		}
+		if err := Save(path, "team", map[string]any{"output_file": "team.txt"}); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := Loader{Schema: StructSchema(options{}), Dir: dir, File: path, UserDir: filepath.Join(dir, "none"), Environ: []string{}, Profile: "team"}
+		s, err := loader.Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var opts options
+		if err := s.Decode(&opts); err != nil {
+			t.Fatal(err)
+		}
+		if !opts.Verbose || opts.Parallel != 4 || strings.Join(opts.Extensions, ",") != ".go" || opts.Output != "team.txt" {
+			t.Errorf("%s: unexpected settings %+v", name, opts)
+		}
+		if profiles, _ := loader.Profiles(); len(profiles) != 2 {
+			t.Errorf("%s: expected the old and the saved profile, got %+v", name, profiles)
+		}
+	}
+
+	if err := Save(filepath.Join(dir, "config"), "", nil); err == nil {
+		t.Error("Expected an error for a file without a known extension")
+	}
+}
@@ -0,0 +1,194 @@
+// Package signing creates and checks detached ed25519 signatures over
+// bundles using only the standard library.
+//
+// Signatures use Ed25519ph (ed25519 over a SHA-512 digest) so bundles can be
+// signed while they are streamed to disk. The signed bytes are the
+// uncompressed bundle without any embedded signature trailer.
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"regexp"
+)
+
+// SignatureExtension is appended to a bundle path for detached signature files
+const SignatureExtension = ".sig"
+
+const (
+	pemPrivateKey = "PRIVATE KEY"
+	pemPublicKey  = "PUBLIC KEY"
+	pemSignature  = "COTO SIGNATURE"
+)
+
+// ErrInvalidSignature is returned when a signature does not match the data
+var ErrInvalidSignature = errors.New("signature does not match the bundle")
+
+// trailer is an embedded signature, placed on the last line of a bundle
+var trailer = regexp.MustCompile(`\n<!-- coto-signature key=([0-9a-f]+) sig=([A-Za-z0-9+/=]+) -->\n?$`)
+
+var options = &ed25519.Options{Hash: crypto.SHA512}
+
+// Signature is a signature together with the id of the key that made it
+type Signature struct {
+	KeyID string
+	Sig   []byte
+}
+
+// KeyID returns a short fingerprint of a public key
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenerateKey creates a new ed25519 key pair
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// WriteKeyPair stores priv at path and pub at path+".pub" as PEM files
+func WriteKeyPair(path string, pub ed25519.PublicKey, priv ed25519.PrivateKey) error {
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: pemPrivateKey, Bytes: privDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(path+".pub", pem.EncodeToMemory(&pem.Block{Type: pemPublicKey, Bytes: pubDER}), 0644)
+}
+
+// LoadPrivateKey reads a PEM encoded ed25519 private key
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path, pemPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 private key", path)
+	}
+	return priv, nil
+}
+
+// LoadPublicKey reads a PEM encoded ed25519 public key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path, pemPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 public key", path)
+	}
+	return pub, nil
+}
+
+func readPEM(path, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: no %s PEM block found", path, blockType)
+	}
+	return block, nil
+}
+
+// Signer hashes everything written to it and signs the digest
+type Signer struct {
+	key  ed25519.PrivateKey
+	hash hash.Hash
+}
+
+// NewSigner returns a Signer for priv
+func NewSigner(priv ed25519.PrivateKey) *Signer {
+	return &Signer{key: priv, hash: sha512.New()}
+}
+
+func (s *Signer) Write(p []byte) (int, error) {
+	return s.hash.Write(p)
+}
+
+// Sign signs the bytes written so far
+func (s *Signer) Sign() (Signature, error) {
+	sig, err := s.key.Sign(nil, s.hash.Sum(nil), options)
+	if err != nil {
+		return Signature{}, err
+	}
+	return Signature{KeyID: KeyID(s.key.Public().(ed25519.PublicKey)), Sig: sig}, nil
+}
+
+// Verify checks sig over data with pub
+func Verify(pub ed25519.PublicKey, data []byte, sig Signature) error {
+	if sig.KeyID != "" && sig.KeyID != KeyID(pub) {
+		return fmt.Errorf("bundle was signed by key %s, not %s", sig.KeyID, KeyID(pub))
+	}
+	digest := sha512.Sum512(data)
+	if err := ed25519.VerifyWithOptions(pub, digest[:], sig.Sig, options); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Trailer formats sig as the last line appended to a signed bundle
+func Trailer(sig Signature) string {
+	return fmt.Sprintf("\n<!-- coto-signature key=%s sig=%s -->\n", sig.KeyID, base64.StdEncoding.EncodeToString(sig.Sig))
+}
+
+// SplitTrailer separates an embedded signature from the signed bytes
+func SplitTrailer(data []byte) ([]byte, *Signature) {
+	loc := trailer.FindSubmatchIndex(data)
+	if loc == nil {
+		return data, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(data[loc[4]:loc[5]]))
+	if err != nil {
+		return data, nil
+	}
+	return data[:loc[0]], &Signature{KeyID: string(data[loc[2]:loc[3]]), Sig: sig}
+}
+
+// EncodeSignatureFile formats sig for a detached .sig file
+func EncodeSignatureFile(sig Signature) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    pemSignature,
+		Headers: map[string]string{"Algorithm": "Ed25519ph", "Key-Id": sig.KeyID},
+		Bytes:   sig.Sig,
+	})
+}
+
+// DecodeSignatureFile parses a detached .sig file
+func DecodeSignatureFile(data []byte) (Signature, error) {
+	block, _ := pem.Decode(bytes.TrimSpace(data))
+	if block == nil || block.Type != pemSignature {
+		return Signature{}, fmt.Errorf("no %s PEM block found", pemSignature)
+	}
+	return Signature{KeyID: block.Headers["Key-Id"], Sig: block.Bytes}, nil
+}
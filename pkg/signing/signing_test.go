@@ -0,0 +1,83 @@
+package signing
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("Coto Output\nGenerated: now\n")
+
+	signer := NewSigner(priv)
+	signer.Write(data[:5])
+	signer.Write(data[5:])
+	sig, err := signer.Sign()
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	if err := Verify(pub, data, sig); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := Verify(pub, append(data, 'x'), sig); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for altered data, got %v", err)
+	}
+
+	otherPub, _, _ := GenerateKey()
+	if err := Verify(otherPub, data, sig); err == nil {
+		t.Error("Expected error for a different key, got nil")
+	}
+}
+
+func TestTrailer(t *testing.T) {
+	_, priv, _ := GenerateKey()
+	data := []byte("# Coto Output\n\ncontent\n")
+	signer := NewSigner(priv)
+	signer.Write(data)
+	sig, _ := signer.Sign()
+
+	signed := append(append([]byte{}, data...), Trailer(sig)...)
+	body, found := SplitTrailer(signed)
+	if found == nil || string(body) != string(data) || found.KeyID != sig.KeyID {
+		t.Fatalf("Expected trailer to split off, got %q %+v", body, found)
+	}
+
+	if _, found := SplitTrailer(data); found != nil {
+		t.Error("Expected no trailer in unsigned data")
+	}
+}
+
+func TestSignatureFileAndKeys(t *testing.T) {
+	pub, priv, _ := GenerateKey()
+	path := filepath.Join(t.TempDir(), "key")
+	if err := WriteKeyPair(path, pub, priv); err != nil {
+		t.Fatalf("WriteKeyPair failed: %v", err)
+	}
+
+	loadedPriv, err := LoadPrivateKey(path)
+	if err != nil || !loadedPriv.Equal(priv) {
+		t.Fatalf("LoadPrivateKey failed: %v", err)
+	}
+	loadedPub, err := LoadPublicKey(path + ".pub")
+	if err != nil || !loadedPub.Equal(pub) {
+		t.Fatalf("LoadPublicKey failed: %v", err)
+	}
+	if _, err := LoadPublicKey(path); err == nil {
+		t.Error("Expected error loading a private key as public key")
+	}
+
+	signer := NewSigner(priv)
+	signer.Write([]byte("bundle"))
+	sig, _ := signer.Sign()
+	decoded, err := DecodeSignatureFile(EncodeSignatureFile(sig))
+	if err != nil {
+		t.Fatalf("DecodeSignatureFile failed: %v", err)
+	}
+	if err := Verify(loadedPub, []byte("bundle"), decoded); err != nil {
+		t.Errorf("Expected decoded signature to verify, got %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+// Package textdiff computes line differences with the Myers algorithm and
+// groups them into unified diff hunks.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NoNewline marks a last line without a trailing newline, as in diff(1)
+const NoNewline = `\ No newline at end of file`
+
+// Op is the kind of an edit
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is one line of the edit script. Old and New are the 0-based line
+// positions in each input; for inserts Old is where the line goes and for
+// deletes New is.
+type Edit struct {
+	Op   Op
+	Old  int
+	New  int
+	Text string // the line including its newline, if any
+}
+
+// Hunk is a group of changes with surrounding context
+type Hunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"lines"` // prefixed with ' ', '-' or '+'
+}
+
+// Header returns the "@@ -a,b +c,d @@" line of the hunk
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", span(h.OldStart, h.OldLines), span(h.NewStart, h.NewLines))
+}
+
+func span(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// SplitLines splits s after each newline, so the last line keeps track of
+// whether the text ends with one
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns the shortest edit script turning a into b
+func Lines(a, b []string) []Edit {
+	// Common prefix and suffix need no search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, Edit{Op: Equal, Old: i, New: i, Text: a[i]})
+	}
+	for _, e := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		e.Old += prefix
+		e.New += prefix
+		edits = append(edits, e)
+	}
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, Edit{Op: Equal, Old: len(a) - i, New: len(b) - i, Text: a[len(a)-i]})
+	}
+	return edits
+}
+
+// myers implements "An O(ND) Difference Algorithm and Its Variations".
+// Only the diagonals reachable after d steps are kept per step, so the trace
+// needs O(D²) memory.
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(a, b []string, trace [][]int) []Edit {
+	x, y := len(a), len(b)
+	var reversed []Edit
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d] // diagonals -d..d before step d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[prevK+d]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Edit{Op: Equal, Old: x, New: y, Text: a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, Edit{Op: Insert, Old: x, New: prevY, Text: b[prevY]})
+			} else {
+				reversed = append(reversed, Edit{Op: Delete, Old: prevX, New: y, Text: a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	edits := make([]Edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+	return edits
+}
+
+// Hunks groups an edit script into hunks with up to context unchanged
+// lines around each change. Changes closer than 2*context lines share a hunk.
+func Hunks(edits []Edit, context int) []Hunk {
+	if context < 0 {
+		context = 0
+	}
+	var hunks []Hunk
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+
+		// Extend the hunk while the next change is within reach
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].Op != Equal {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		stop := end + context + 1
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		hunks = append(hunks, makeHunk(edits[start:stop]))
+		i = stop
+	}
+	return hunks
+}
+
+func makeHunk(edits []Edit) Hunk {
+	h := Hunk{OldStart: edits[0].Old + 1, NewStart: edits[0].New + 1}
+	for _, e := range edits {
+		prefix := " "
+		switch e.Op {
+		case Equal:
+			h.OldLines++
+			h.NewLines++
+		case Delete:
+			h.OldLines++
+			prefix = "-"
+		case Insert:
+			h.NewLines++
+			prefix = "+"
+		}
+		h.Lines = append(h.Lines, prefix+strings.TrimSuffix(e.Text, "\n"))
+		if !strings.HasSuffix(e.Text, "\n") {
+			h.Lines = append(h.Lines, NoNewline)
+		}
+	}
+	// An empty side is numbered from the line before it
+	if h.OldLines == 0 {
+		h.OldStart--
+	}
+	if h.NewLines == 0 {
+		h.NewStart--
+	}
+	return h
+}
+
+// Unified returns the unified diff of two texts, or "" when they are equal
+func Unified(oldName, newName, a, b string, context int) string {
+	hunks := Hunks(Lines(SplitLines(a), SplitLines(b)), context)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		sb.WriteString(h.Header())
+		sb.WriteByte('\n')
+		for _, line := range h.Lines {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// Count returns the number of deleted and inserted lines of an edit script
+func Count(edits []Edit) (deleted, inserted int) {
+	for _, e := range edits {
+		switch e.Op {
+		case Delete:
+			deleted++
+		case Insert:
+			inserted++
+		}
+	}
+	return deleted, inserted
+}
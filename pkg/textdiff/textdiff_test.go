@@ -0,0 +1,111 @@
+package textdiff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven"
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+\ No newline at end of file
+`
+	if got := Unified("a", "b", a, b, 3); got != want {
+		t.Errorf("Unified() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := Unified("a", "b", a, a, 3); got != "" {
+		t.Errorf("Expected no diff for equal texts, got\n%s", got)
+	}
+}
+
+func TestUnifiedEmptySides(t *testing.T) {
+	added := Unified("/dev/null", "b", "", "x\ny\n", 3)
+	if !strings.Contains(added, "@@ -0,0 +1,2 @@\n+x\n+y\n") {
+		t.Errorf("Unexpected diff for an added file:\n%s", added)
+	}
+	removed := Unified("a", "/dev/null", "x\n", "", 3)
+	if !strings.Contains(removed, "@@ -1 +0,0 @@\n-x\n") {
+		t.Errorf("Unexpected diff for a removed file:\n%s", removed)
+	}
+}
+
+func TestHunksMergeNearbyChanges(t *testing.T) {
+	a := SplitLines("a\nb\nc\nd\ne\nf\ng\nh\n")
+	b := SplitLines("A\nb\nc\nd\ne\nf\ng\nH\n")
+	if got := len(Hunks(Lines(a, b), 3)); got != 1 {
+		t.Errorf("Expected changes 6 lines apart to share a hunk with context 3, got %d hunks", got)
+	}
+	if got := len(Hunks(Lines(a, b), 2)); got != 2 {
+		t.Errorf("Expected two hunks with context 2, got %d", got)
+	}
+}
+
+// lcs is the reference length of the longest common subsequence
+func lcs(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestLinesIsMinimalAndApplies(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		edits := Lines(a, b)
+
+		var oldSide, newSide []string
+		for _, e := range edits {
+			if e.Op != Insert {
+				oldSide = append(oldSide, e.Text)
+			}
+			if e.Op != Delete {
+				newSide = append(newSide, e.Text)
+			}
+		}
+		if strings.Join(oldSide, "|") != strings.Join(a, "|") || strings.Join(newSide, "|") != strings.Join(b, "|") {
+			t.Fatalf("edit script does not reproduce the inputs for %q -> %q", a, b)
+		}
+
+		deleted, inserted := Count(edits)
+		if want := len(a) + len(b) - 2*lcs(a, b); deleted+inserted != want {
+			t.Fatalf("edit script for %q -> %q has %d changes, minimal is %d", a, b, deleted+inserted, want)
+		}
+	}
+}